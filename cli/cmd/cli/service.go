@@ -2,9 +2,9 @@ package cli
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/app"
-	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
 	gl "github.com/kubex-ecosystem/lookatni-file-markers/internal/module/logger"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/vscode"
 	"github.com/spf13/cobra"
@@ -15,8 +15,22 @@ func ServiceCmdList() []*cobra.Command {
 		extractCommand(),
 		validateCommand(),
 		generateCommand(),
+		updateCommand(),
+		diffCommand(),
+		mergeCommand(),
+		convertCommand(),
+		detectCommand(),
+		exportCommand(),
+		importCommand(),
+		addCommand(),
+		rmCommand(),
+		mvCommand(),
+		replaceCommand(),
+		listCommand(),
+		statsCommand(),
 		transpileCommand(),
 		presetsCommand(),
+		pruneBasesCommand(),
 		vscodeCommand(),
 		refactorCommand(),
 	}
@@ -24,7 +38,8 @@ func ServiceCmdList() []*cobra.Command {
 
 // extractCommand handles file extraction from marked files.
 func extractCommand() *cobra.Command {
-	var overwrite, createDirs, dryRun bool
+	var overwrite, createDirs, dryRun, mergeLocal, mirrorOut bool
+	var scope, profile string
 	var debug bool
 
 	var extractCmd = &cobra.Command{
@@ -61,6 +76,18 @@ func extractCommand() *cobra.Command {
 			if dryRun {
 				options = append(options, "--dry-run")
 			}
+			if mergeLocal {
+				options = append(options, "--merge")
+			}
+			if mirrorOut {
+				options = append(options, "--mirror")
+			}
+			if scope != "" {
+				options = append(options, "--scope", scope)
+			}
+			if profile != "" {
+				options = append(options, "--profile", profile)
+			}
 
 			return cliApp.Run(options)
 		},
@@ -69,6 +96,10 @@ func extractCommand() *cobra.Command {
 	extractCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing files")
 	extractCmd.Flags().BoolVar(&createDirs, "create-dirs", true, "Create directories as needed")
 	extractCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without doing it")
+	extractCmd.Flags().BoolVar(&mergeLocal, "merge", false, "Three-way merge into locally modified files, writing conflict markers instead of overwriting")
+	extractCmd.Flags().BoolVar(&mirrorOut, "mirror", false, "Delete files the archive no longer contains (ignored and untracked files are kept)")
+	extractCmd.Flags().StringVar(&scope, "scope", "", "Limit --mirror deletions to this subpath of the output directory")
+	extractCmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from .lookatni.yaml or the user config")
 	extractCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
 
 	return extractCmd
@@ -78,6 +109,7 @@ func extractCommand() *cobra.Command {
 func validateCommand() *cobra.Command {
     var debug bool
     var strict bool
+    var profile string
 
 	var validateCmd = &cobra.Command{
 		Use:   "validate <marked-file>",
@@ -101,12 +133,16 @@ func validateCommand() *cobra.Command {
             if strict {
                 opts = append(opts, "--strict")
             }
+            if profile != "" {
+                opts = append(opts, "--profile", profile)
+            }
             return cliApp.Run(opts)
         },
     }
 
     validateCmd.Flags().BoolP("debug", "D", false, "Enable debug logging")
     validateCmd.Flags().BoolVar(&strict, "strict", false, "Enable strict validation (flag malformed marker-like lines)")
+    validateCmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from .lookatni.yaml or the user config")
 
     return validateCmd
 }
@@ -115,13 +151,20 @@ func validateCommand() *cobra.Command {
 func generateCommand() *cobra.Command {
 	var excludePatterns []string
 	var markerPreset, markerStart, markerEnd, markerPattern string
+	var armor, watchMode, gitMode, recordBase bool
+	var gitRev, since, changedFrom string
+	var context int
+	var maxTokens, tokenBudget int
+	var maxBytes int64
+	var priority []string
+	var tokenizer, profile, format, sfxFile string
 	var debug bool
 
 	var generateCmd = &cobra.Command{
-		Use:   "generate <source-dir> <output-file>",
+		Use:   "generate <source-dir> [output-file]",
 		Short: "Consolidate directory INTO marked file",
-		Long:  "Generate a LookAtni marked file from a directory structure, consolidating all files.",
-		Args:  cobra.ExactArgs(2),
+		Long:  "Generate a LookAtni marked file from a directory structure, consolidating all files. The output file may be omitted when .lookatni.yaml names one or --sfx is given.",
+		Args:  cobra.RangeArgs(1, 2),
 		Annotations: GetDescriptions([]string{
 			"Consolidate directory structure into a single marked file",
 			"Consolidate directory INTO marked file",
@@ -130,20 +173,13 @@ func generateCommand() *cobra.Command {
 			if debug {
 				gl.SetDebug(true)
 			}
-			sourceDir := args[0]
-			outputFile := args[1]
-
 			// Initialize app
 			cliApp := app.New(nil)
 
 			// Build options
-			options := []string{
-				"generate",
-				sourceDir,
-				outputFile,
-			}
+			options := append([]string{"generate"}, args...)
 
-			// Add exclude patterns
+			// Add exclude patterns; without any the config or built-in defaults apply
 			for _, pattern := range excludePatterns {
 				options = append(options, "--exclude", pattern)
 			}
@@ -161,21 +197,486 @@ func generateCommand() *cobra.Command {
             if markerPattern != "" {
                 options = append(options, "--marker-pattern", markerPattern)
             }
+			if armor {
+				options = append(options, "--armor")
+			}
+			if watchMode {
+				options = append(options, "--watch")
+			}
+			if gitMode {
+				options = append(options, "--git")
+			}
+			if gitRev != "" {
+				options = append(options, "--git-rev", gitRev)
+			}
+			if since != "" {
+				options = append(options, "--since", since)
+			}
+			if changedFrom != "" {
+				options = append(options, "--changed-from", changedFrom)
+			}
+			if cmd.Flags().Changed("context") {
+				options = append(options, "--context", strconv.Itoa(context))
+			}
+			if maxTokens > 0 {
+				options = append(options, "--max-tokens", strconv.Itoa(maxTokens))
+			}
+			if maxBytes > 0 {
+				options = append(options, "--max-bytes", strconv.FormatInt(maxBytes, 10))
+			}
+			if tokenBudget > 0 {
+				options = append(options, "--token-budget", strconv.Itoa(tokenBudget))
+			}
+			for _, glob := range priority {
+				options = append(options, "--priority", glob)
+			}
+			if tokenizer != "" {
+				options = append(options, "--tokenizer", tokenizer)
+			}
+			if profile != "" {
+				options = append(options, "--profile", profile)
+			}
+			if format != "" {
+				options = append(options, "--format", format)
+			}
+			if sfxFile != "" {
+				options = append(options, "--sfx", sfxFile)
+			}
+			if recordBase {
+				options = append(options, "--record-base")
+			}
 
 			return cliApp.Run(options)
 		},
 	}
 
-	generateCmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "x", []string{"*.log", "node_modules", ".git"}, "Exclude files matching pattern")
-	generateCmd.Flags().StringVarP(&markerPreset, "marker-preset", "m", "", "Use predefined marker format (html, markdown, code, visual)")
+	generateCmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "x", nil, "Exclude files matching pattern (default: the config excludes, else VCS, build and log files)")
+	generateCmd.Flags().StringVarP(&markerPreset, "marker-preset", "m", "", "Use predefined marker format (html, markdown, fenced, code, lang, visual, txtar)")
 	generateCmd.Flags().StringVarP(&markerStart, "marker-start", "s", "", "Custom marker start pattern")
 	generateCmd.Flags().StringVarP(&markerEnd, "marker-end", "e", "", "Custom marker end pattern")
-	generateCmd.Flags().StringVarP(&markerPattern, "marker-pattern", "p", "", "Custom marker pattern with {filename} placeholder")
+	generateCmd.Flags().StringVarP(&markerPattern, "marker-pattern", "p", "", "Custom marker pattern with {filename} and optional {index}, {size}, {lang}, {mode}, {sha256} placeholders")
+	generateCmd.Flags().BoolVar(&armor, "armor", false, "Wrap output in printable, checksummed ASCII armor")
+	generateCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Watch the source directory and rebuild incrementally on changes")
+	generateCmd.Flags().BoolVar(&gitMode, "git", false, "Archive exactly the files tracked by git (honours export-ignore)")
+	generateCmd.Flags().StringVar(&gitRev, "git-rev", "", "Archive the tree of a git commit instead of the working tree")
+	generateCmd.Flags().StringVar(&since, "since", "", "Archive only files changed since a git ref, listing deleted paths")
+	generateCmd.Flags().StringVar(&changedFrom, "changed-from", "", "Archive only files changed relative to an older copy of the source directory")
+	generateCmd.Flags().IntVar(&context, "context", 0, "With --since/--changed-from, store large modified files as diff hunks with N context lines")
+	generateCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Split output into numbered chunks of at most N tokens")
+	generateCmd.Flags().Int64Var(&maxBytes, "max-bytes", 0, "Split output into numbered chunks of at most N bytes")
+	generateCmd.Flags().IntVar(&tokenBudget, "token-budget", 0, "Keep the archive under N tokens, dropping low-priority files")
+	generateCmd.Flags().StringSliceVar(&priority, "priority", nil, "Globs admitted first under --token-budget")
+	generateCmd.Flags().StringVar(&tokenizer, "tokenizer", "", "Token estimator: cl100k (default) or chars4")
+	generateCmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from .lookatni.yaml or the user config")
+	generateCmd.Flags().StringVar(&format, "format", "", "Write xml documents, a json array or jsonl instead of marker lines")
+	generateCmd.Flags().StringVar(&sfxFile, "sfx", "", "Write a self-extracting POSIX sh script to this file instead of an archive")
+	generateCmd.Flags().BoolVar(&recordBase, "record-base", false, "Record file contents as bases for later extract --merge")
 	generateCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
 
 	return generateCmd
 }
 
+// updateCommand incrementally refreshes an existing archive from a directory.
+func updateCommand() *cobra.Command {
+	var excludePatterns []string
+	var profile string
+	var debug bool
+
+	short := "Incrementally update an archive from a directory"
+	long := "Rewrite only the changed, added or removed entries of an existing archive, using an mtime+size+hash cache stored next to it. Unchanged entries stay byte-identical."
+
+	var updateCmd = &cobra.Command{
+		Use:   "update <archive> <source-dir>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(2),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := []string{"update", args[0], args[1]}
+			for _, pattern := range excludePatterns {
+				options = append(options, "--exclude", pattern)
+			}
+			if profile != "" {
+				options = append(options, "--profile", profile)
+			}
+
+			return cliApp.Run(options)
+		},
+	}
+
+	updateCmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "x", nil, "Exclude files matching pattern")
+	updateCmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from .lookatni.yaml or the user config")
+	updateCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return updateCmd
+}
+
+// diffCommand compares an archive with another archive or a directory.
+func diffCommand() *cobra.Command {
+	var excludePatterns []string
+	var profile string
+	var stat, nameOnly, asJSON bool
+	var debug bool
+
+	short := "Show changes between archives or an archive and a directory"
+	long := "Report added, removed, modified and renamed files between two archives, or between an archive and a directory, with unified diffs for text entries."
+
+	var diffCmd = &cobra.Command{
+		Use:   "diff <archive|dir> <archive|dir>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(2),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := []string{"diff", args[0], args[1]}
+			for _, pattern := range excludePatterns {
+				options = append(options, "--exclude", pattern)
+			}
+			if stat {
+				options = append(options, "--stat")
+			}
+			if nameOnly {
+				options = append(options, "--name-only")
+			}
+			if asJSON {
+				options = append(options, "--json")
+			}
+			if profile != "" {
+				options = append(options, "--profile", profile)
+			}
+
+			return cliApp.Run(options)
+		},
+	}
+
+	diffCmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "x", nil, "Exclude directory files matching pattern")
+	diffCmd.Flags().BoolVar(&stat, "stat", false, "Show per-file line counts only")
+	diffCmd.Flags().BoolVar(&nameOnly, "name-only", false, "Show changed paths only")
+	diffCmd.Flags().BoolVar(&asJSON, "json", false, "Emit the result as JSON")
+	diffCmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from .lookatni.yaml or the user config")
+	diffCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return diffCmd
+}
+
+// mergeCommand combines several archives into one.
+func mergeCommand() *cobra.Command {
+	var output, onConflict string
+	var prefixes []string
+	var debug bool
+
+	short := "Combine several archives into one"
+	long := "Merge archives into a single archive, optionally placing each input under a path prefix. Conflicting paths follow the chosen policy and the PROJECT_INFO metadata of all inputs is combined."
+
+	var mergeCmd = &cobra.Command{
+		Use:   "merge <archive> <archive>... -o <output>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.MinimumNArgs(2),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := append([]string{"merge"}, args...)
+			options = append(options, "--output", output)
+			for _, prefix := range prefixes {
+				options = append(options, "--prefix", prefix)
+			}
+			if onConflict != "" {
+				options = append(options, "--on-conflict", onConflict)
+			}
+
+			return cliApp.Run(options)
+		},
+	}
+
+	mergeCmd.Flags().StringVarP(&output, "output", "o", "", "Combined archive to write")
+	mergeCmd.Flags().StringArrayVar(&prefixes, "prefix", nil, "Path prefix for one input, as archive=path/ (repeatable)")
+	mergeCmd.Flags().StringVar(&onConflict, "on-conflict", "fail", "Conflict policy: first-wins, last-wins, fail or keep-both-renamed")
+	mergeCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+	_ = mergeCmd.MarkFlagRequired("output")
+
+	return mergeCmd
+}
+
+// convertCommand rewrites an archive in another marker dialect.
+func convertCommand() *cobra.Command {
+	var to, from string
+	var debug bool
+
+	short := "Convert an archive to another marker dialect or document format"
+	long := "Parse an archive with adaptive marker detection and re-emit its entries and frontmatter in the dialect of a marker preset, or as xml, json or jsonl documents. Refuses when file content would be read as a marker in the target dialect."
+
+	var convertCmd = &cobra.Command{
+		Use:   "convert <input> <output> --to <preset|xml|json|jsonl> [--from <preset|xml|json|jsonl>]",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(2),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := []string{"convert", args[0], args[1], "--to", to}
+			if from != "" {
+				options = append(options, "--from", from)
+			}
+			return cliApp.Run(options)
+		},
+	}
+
+	convertCmd.Flags().StringVar(&to, "to", "", "Target marker preset (default, html, markdown, fenced, code, lang, visual, txtar) or document format (xml, json, jsonl)")
+	convertCmd.Flags().StringVar(&from, "from", "", "Read the input in this preset or document format instead of detecting it")
+	convertCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+	_ = convertCmd.MarkFlagRequired("to")
+
+	return convertCmd
+}
+
+// detectCommand reports the marker dialect of an archive.
+func detectCommand() *cobra.Command {
+	var asJSON bool
+	var debug bool
+
+	short := "Detect the marker dialect of an archive"
+	long := "Report the marker dialect of an archive: from its frontmatter when present, otherwise by scoring every preset's marker syntax against the content, with a confidence value."
+
+	var detectCmd = &cobra.Command{
+		Use:   "detect <archive>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(1),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := []string{"detect", args[0]}
+			if asJSON {
+				options = append(options, "--json")
+			}
+			return cliApp.Run(options)
+		},
+	}
+
+	detectCmd.Flags().BoolVar(&asJSON, "json", false, "Emit the result as JSON")
+	detectCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return detectCmd
+}
+
+// addCommand adds or updates archive entries in place.
+func addCommand() *cobra.Command {
+	var as string
+	var debug bool
+
+	short := "Add or update entries in an archive"
+	long := "Add files, or every file below a directory, to an existing archive. Existing entries are replaced in place; the dialect, frontmatter and order of untouched entries are preserved."
+
+	var addCmd = &cobra.Command{
+		Use:   "add <archive> <file|dir>...",
+		Short: short,
+		Long:  long,
+		Args:  cobra.MinimumNArgs(2),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := append([]string{"add"}, args...)
+			if as != "" {
+				options = append(options, "--as", as)
+			}
+
+			return cliApp.Run(options)
+		},
+	}
+
+	addCmd.Flags().StringVar(&as, "as", "", "Entry name for a single added file")
+	addCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return addCmd
+}
+
+// rmCommand removes archive entries in place.
+func rmCommand() *cobra.Command {
+	return editCommand("rm", "rm <archive> <path>...", cobra.MinimumNArgs(2),
+		"Remove entries from an archive",
+		"Remove entries, or whole directories of entries, from an existing archive without touching the others.")
+}
+
+// mvCommand renames archive entries in place.
+func mvCommand() *cobra.Command {
+	return editCommand("mv", "mv <archive> <old-path> <new-path>", cobra.ExactArgs(3),
+		"Rename an entry in an archive",
+		"Rename an entry, or a directory of entries, inside an existing archive. Entries keep their position and content.")
+}
+
+// exportCommand writes archive files to a tar or zip file.
+func exportCommand() *cobra.Command {
+	return editCommand("export", "export <archive> <output.tar|output.tar.gz|output.zip>", cobra.ExactArgs(2),
+		"Export archive files to tar or zip",
+		"Stream the files of an archive or chunk set into a tar, tar.gz or zip file, carrying recorded modes and modification times.")
+}
+
+// importCommand builds an archive from a tar or zip file.
+func importCommand() *cobra.Command {
+	return editCommand("import", "import <input.tar|input.tar.gz|input.zip> <archive>", cobra.ExactArgs(2),
+		"Import a tar or zip file as an archive",
+		"Stream the regular files of a tar, tar.gz or zip file into a new archive, recording their modes and modification times in PROJECT_INFO.")
+}
+
+// replaceCommand replaces an archive entry with standard input.
+func replaceCommand() *cobra.Command {
+	return editCommand("replace", "replace <archive> <path> < new-content", cobra.ExactArgs(2),
+		"Replace an entry with standard input",
+		"Replace the content of an existing archive entry with standard input, keeping its position in the archive.")
+}
+
+// editCommand builds commands that only pass their positional arguments through.
+func editCommand(name, use string, args cobra.PositionalArgs, short, long string) *cobra.Command {
+	var debug bool
+
+	var editCmd = &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  long,
+		Args:  args,
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			return cliApp.Run(append([]string{name}, args...))
+		},
+	}
+
+	editCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return editCmd
+}
+
+// listCommand lists archive or directory files with token estimates.
+func listCommand() *cobra.Command {
+	return tokenReportCommand("list",
+		"List files with their size and token estimate",
+		"List every file of an archive, chunk set or directory with its bytes, lines and estimated LLM tokens.")
+}
+
+// statsCommand summarizes the token cost of an archive or directory.
+func statsCommand() *cobra.Command {
+	return tokenReportCommand("stats",
+		"Summarize files, bytes, lines and tokens",
+		"Summarize an archive, chunk set or directory: totals, a per-extension breakdown and the largest files by estimated LLM tokens.")
+}
+
+// tokenReportCommand builds the list and stats commands, which share their flags.
+func tokenReportCommand(name, short, long string) *cobra.Command {
+	var excludePatterns []string
+	var tokenizer, profile string
+	var asJSON bool
+	var debug bool
+
+	var reportCmd = &cobra.Command{
+		Use:   name + " <archive|dir>",
+		Short: short,
+		Long:  long,
+		Args:  cobra.ExactArgs(1),
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := []string{name, args[0]}
+			for _, pattern := range excludePatterns {
+				options = append(options, "--exclude", pattern)
+			}
+			if tokenizer != "" {
+				options = append(options, "--tokenizer", tokenizer)
+			}
+			if asJSON {
+				options = append(options, "--json")
+			}
+			if profile != "" {
+				options = append(options, "--profile", profile)
+			}
+
+			return cliApp.Run(options)
+		},
+	}
+
+	reportCmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "x", nil, "Exclude directory files matching pattern")
+	reportCmd.Flags().StringVar(&tokenizer, "tokenizer", "", "Token estimator: cl100k (default) or chars4")
+	reportCmd.Flags().BoolVar(&asJSON, "json", false, "Emit the result as JSON")
+	reportCmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from .lookatni.yaml or the user config")
+	reportCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return reportCmd
+}
+
 // transpileCommand handles Markdown to HTML transpilation.
 func transpileCommand() *cobra.Command {
 	var debug bool
@@ -213,10 +714,11 @@ func transpileCommand() *cobra.Command {
 
 // presetsCommand lists available marker presets.
 func presetsCommand() *cobra.Command {
+	var asJSON bool
 	var debug bool
 
 	short := "List available marker presets"
-	long := "Display all available marker presets with examples and descriptions."
+	long := "Display the built-in marker presets and those defined in the user config or .lookatni.yaml, with examples and the file defining each, followed by the configured profiles."
 
 	var presetsCmd = &cobra.Command{
 		Use:   "presets",
@@ -231,30 +733,65 @@ func presetsCommand() *cobra.Command {
 			if debug {
 				gl.SetDebug(true)
 			}
-			// fmt.Println("🎨 Available Marker Presets:")
-			gl.Log("info", "Available Marker Presets:")
 
-			presets := metadata.GetPresetConfigs()
-			for name, preset := range presets {
-				gl.Log("info", "  %s: %s", name, preset.Name)
-				gl.Log("info", "    %s", preset.Description)
+			// Initialize app
+			cliApp := app.New(nil)
 
-				// Show example
-				example := preset.Config.FormatMarker("example.go")
-				if example != "" {
-					gl.Log("info", "    Example: %s", example)
-				}
-				gl.Log("info", "")
+			options := []string{"presets"}
+			if asJSON {
+				options = append(options, "--json")
 			}
-			return nil
+
+			return cliApp.Run(options)
 		},
 	}
 
+	presetsCmd.Flags().BoolVar(&asJSON, "json", false, "Emit the result as JSON")
 	presetsCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
 
 	return presetsCmd
 }
 
+// pruneBasesCommand removes old merge bases from the base store.
+func pruneBasesCommand() *cobra.Command {
+	var olderThan int
+	var debug bool
+
+	short := "Remove old merge bases"
+	long := "Remove the file contents recorded by generate --record-base from the base store once they are older than --older-than days (90 by default); 0 empties the store."
+
+	var pruneCmd = &cobra.Command{
+		Use:   "prune-bases",
+		Short: short,
+		Long:  long,
+		Args:  cobra.NoArgs,
+		Annotations: GetDescriptions([]string{
+			long,
+			short,
+		}, os.Getenv("LOOKATNI_HIDEBANNER") == "true"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debug {
+				gl.SetDebug(true)
+			}
+
+			// Initialize app
+			cliApp := app.New(nil)
+
+			options := []string{"prune-bases"}
+			if cmd.Flags().Changed("older-than") {
+				options = append(options, "--older-than", strconv.Itoa(olderThan))
+			}
+
+			return cliApp.Run(options)
+		},
+	}
+
+	pruneCmd.Flags().IntVar(&olderThan, "older-than", 90, "Remove bases recorded more than N days ago")
+	pruneCmd.Flags().BoolVarP(&debug, "debug", "D", false, "Enable debug logging")
+
+	return pruneCmd
+}
+
 // vscodeCommand starts the VS Code integration server.
 func vscodeCommand() *cobra.Command {
 	var port int
@@ -2,30 +2,57 @@
 package app
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	l "github.com/kubex-ecosystem/logz"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/adaptive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/changeset"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/config"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/diff"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/exchange"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/formats"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/gitsrc"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/integration"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/merge"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/mirror"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/module/logger"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/sfx"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/transpiler"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/watch"
 )
 
 //go:embed templates/*
 var templatesFS embed.FS
 
+// defaultExcludePatterns are applied when walking directories without explicit excludes.
+var defaultExcludePatterns = []string{
+	"*.git*", "node_modules", "dist", "build", "*.log", "*.tmp",
+}
+
 // App represents the main CLI application.
 type App struct {
 	logger            logger.GLog[l.Logger] // Is already a interface, so, a pointer...
 	parser            *parser.MarkerParser
+	reader            *adaptive.AdaptiveParser
 	transpiler        *transpiler.Transpiler
 	gromptIntegration *integration.GromptIntegration
+	config            *config.Config
+	settings          config.Settings // config defaults with --profile applied
 }
 
 func init() {
@@ -52,6 +79,7 @@ func New(log logger.GLog[l.Logger]) *App {
 	return &App{
 		logger:            log,
 		parser:            parser.New(),
+		reader:            adaptive.New(),
 		transpiler:        transpiler.New(string(htmlTemplate)),
 		gromptIntegration: integration.NewGromptIntegration(),
 	}
@@ -59,6 +87,10 @@ func New(log logger.GLog[l.Logger]) *App {
 
 // Run executes the CLI application with the given arguments.
 func (a *App) Run(args []string) error {
+	args, err := a.configure(args)
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
 		return a.showHelp()
 	}
@@ -71,8 +103,38 @@ func (a *App) Run(args []string) error {
 		return a.validateCommand(args[1:])
 	case "generate":
 		return a.generateCommand(args[1:])
+	case "update":
+		return a.updateCommand(args[1:])
+	case "diff":
+		return a.diffCommand(args[1:])
+	case "merge":
+		return a.mergeCommand(args[1:])
+	case "convert":
+		return a.convertCommand(args[1:])
+	case "detect":
+		return a.detectCommand(args[1:])
+	case "export":
+		return a.exportCommand(args[1:])
+	case "import":
+		return a.importCommand(args[1:])
+	case "add":
+		return a.addCommand(args[1:])
+	case "rm":
+		return a.rmCommand(args[1:])
+	case "mv":
+		return a.mvCommand(args[1:])
+	case "replace":
+		return a.replaceCommand(args[1:])
+	case "list":
+		return a.listCommand(args[1:])
+	case "stats":
+		return a.statsCommand(args[1:])
 	case "transpile":
 		return a.transpileCommand(args[1:])
+	case "presets":
+		return a.presetsCommand(args[1:])
+	case "prune-bases":
+		return a.pruneBasesCommand(args[1:])
 	case "refactor":
 		return a.refactorCommand(args[1:])
 	case "help":
@@ -82,39 +144,147 @@ func (a *App) Run(args []string) error {
 	}
 }
 
+// configure loads the user and project config files, registers their presets
+// and selects the settings of the --profile flag, which it removes from args.
+func (a *App) configure(args []string) ([]string, error) {
+	profile := ""
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile" && i+1 < len(args):
+			profile = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			profile = strings.TrimPrefix(args[i], "--profile=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if err := cfg.Register(); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	settings, err := cfg.Settings(profile)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	for _, file := range cfg.Files {
+		a.logger.Log("debug", fmt.Sprintf("Loaded config %s", file))
+	}
+	a.config, a.settings = cfg, settings
+	return rest, nil
+}
+
+// excludes returns the exclude patterns given on the command line, else the
+// configured ones, else the defaults.
+func (a *App) excludes(patterns []string) []string {
+	switch {
+	case len(patterns) > 0:
+		return patterns
+	case len(a.settings.Exclude) > 0:
+		return a.settings.Exclude
+	}
+	return defaultExcludePatterns
+}
+
+// presetsCommand lists the marker presets and profiles with where they come from.
+func (a *App) presetsCommand(args []string) error {
+	listing := a.config.List()
+	if slices.Contains(args, "--json") {
+		return listing.WriteJSON(os.Stdout)
+	}
+	return listing.WriteText(os.Stdout)
+}
+
+// pruneBasesCommand removes merge bases recorded longer ago than --older-than
+// days, MaxBaseAge by default; 0 empties the store.
+func (a *App) pruneBasesCommand(args []string) error {
+	maxAge := merge.MaxBaseAge
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--older-than" && i+1 < len(args) {
+			days, err := strconv.Atoi(args[i+1])
+			if err != nil || days < 0 {
+				return fmt.Errorf("invalid --older-than value: %s", args[i+1])
+			}
+			maxAge = time.Duration(days) * 24 * time.Hour
+			i++
+		}
+	}
+	store, err := merge.DefaultBaseStore()
+	if err != nil {
+		return fmt.Errorf("prune failed: %w", err)
+	}
+	removed, err := store.Prune(maxAge)
+	if err != nil {
+		return fmt.Errorf("prune failed: %w", err)
+	}
+	a.logger.Log("success", fmt.Sprintf("Removed %d merge bases from %s", removed, store.Dir))
+	return nil
+}
+
 // extractCommand handles file extraction from marked files.
 func (a *App) extractCommand(args []string) error {
 	if len(args) < 2 {
-		return fmt.Errorf("usage: extract <marked-file> <output-dir> [--overwrite] [--create-dirs] [--dry-run]")
+		return fmt.Errorf("usage: extract <marked-file> <output-dir> [--overwrite] [--create-dirs] [--dry-run] [--merge] [--mirror [--scope path]]")
 	}
 
 	markedFile := args[0]
 	outputDir := args[1]
 
 	options := parser.ExtractOptions{
-		Overwrite:  false,
+		Overwrite:  config.Flag(a.settings.Overwrite),
 		CreateDirs: true,
 		DryRun:     false,
 	}
 
 	// Parse flags
-	for _, arg := range args[2:] {
-		switch arg {
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
 		case "--overwrite":
 			options.Overwrite = true
 		case "--create-dirs":
 			options.CreateDirs = true
 		case "--dry-run":
 			options.DryRun = true
+		case "--merge":
+			options.Merge = true
+		case "--mirror":
+			options.Mirror = true
+		case "--scope":
+			if i+1 < len(args) {
+				options.Scope = args[i+1]
+				i++
+			}
 		}
 	}
 
 	a.logger.Log("info", fmt.Sprintf("Extracting files from %s to %s", markedFile, outputDir))
 
-	result, err := a.parser.ExtractFiles(markedFile, outputDir, options)
+	markedFile, cleanup, err := a.joinChunkSet(markedFile)
 	if err != nil {
 		return fmt.Errorf("extraction failed: %w", err)
 	}
+	defer cleanup()
+
+	var result *parser.ExtractResults
+	if options.Merge {
+		result, err = merge.ExtractWithMerge(markedFile, outputDir, options)
+	} else {
+		result, err = a.reader.ExtractFiles(markedFile, outputDir, options)
+	}
+	if err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+
+	if options.Mirror {
+		if err := a.mirrorOutput(markedFile, outputDir, options, result); err != nil {
+			return fmt.Errorf("mirror failed: %w", err)
+		}
+	}
 
 	if len(result.Errors) > 0 {
 		a.logger.Log("warn", "Extraction completed with errors:")
@@ -137,6 +307,29 @@ func (a *App) extractCommand(args []string) error {
 		}
 	}
 
+	if len(result.MergedFiles) > 0 {
+		a.logger.Log("info", fmt.Sprintf("Merged %d locally modified files", len(result.MergedFiles)))
+		for _, file := range result.MergedFiles {
+			a.logger.Log("debug", fmt.Sprintf("   ⇄ %s", file))
+		}
+	}
+	if len(result.DeletedFiles) > 0 {
+		if options.DryRun {
+			a.logger.Log("info", fmt.Sprintf("[DRY RUN] Would delete %d files not in the archive", len(result.DeletedFiles)))
+		} else {
+			a.logger.Log("info", fmt.Sprintf("Deleted %d files not in the archive", len(result.DeletedFiles)))
+		}
+		for _, file := range result.DeletedFiles {
+			a.logger.Log("info", fmt.Sprintf("   - %s", file))
+		}
+	}
+	if len(result.Conflicts) > 0 {
+		a.logger.Log("warn", fmt.Sprintf("%d files have merge conflicts:", len(result.Conflicts)))
+		for _, conflict := range result.Conflicts {
+			a.logger.Log("warn", fmt.Sprintf("   %s", conflict))
+		}
+	}
+
 	return nil
 }
 
@@ -147,7 +340,7 @@ func (a *App) validateCommand(args []string) error {
     }
 
     markedFile := args[0]
-    strict := false
+    strict := config.Flag(a.settings.Strict)
     for _, arg := range args[1:] {
         if arg == "--strict" {
             strict = true
@@ -156,7 +349,13 @@ func (a *App) validateCommand(args []string) error {
 
     a.logger.Log("info", fmt.Sprintf("Validating markers in %s (strict=%v)", markedFile, strict))
 
-    result, err := a.parser.ValidateMarkers(markedFile, strict)
+    markedFile, cleanup, err := a.joinChunkSet(markedFile)
+    if err != nil {
+        return fmt.Errorf("validation failed: %w", err)
+    }
+    defer cleanup()
+
+    result, err := a.reader.ValidateMarkers(markedFile, strict)
     if err != nil {
         return fmt.Errorf("validation failed: %w", err)
     }
@@ -196,6 +395,597 @@ func (a *App) validateCommand(args []string) error {
 	return nil
 }
 
+// updateCommand incrementally refreshes an existing archive from a directory.
+func (a *App) updateCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: update <archive> <source-dir> [--exclude pattern]")
+	}
+
+	archiveFile := args[0]
+	sourceDir := args[1]
+
+	var excludePatterns []string
+	for i := 2; i < len(args); i++ {
+		if args[i] == "--exclude" && i+1 < len(args) {
+			excludePatterns = append(excludePatterns, args[i+1])
+			i++
+		}
+	}
+	excludePatterns = a.excludes(excludePatterns)
+
+	a.logger.Log("info", fmt.Sprintf("Updating %s from %s", archiveFile, sourceDir))
+
+	result, err := archive.Update(archiveFile, sourceDir, excludePatterns)
+	if err != nil {
+		return fmt.Errorf("update failed: %w", err)
+	}
+
+	if len(result.Errors) > 0 {
+		a.logger.Log("warn", "Update completed with warnings:")
+		for _, errMsg := range result.Errors {
+			a.logger.Log("warn", fmt.Sprintf("   %s", errMsg))
+		}
+	}
+
+	if !result.Changed() {
+		a.logger.Log("success", fmt.Sprintf("Archive is up to date (%d files, %d rehashed)", result.Unchanged, result.Rehashed))
+		return nil
+	}
+
+	a.recordMergeBases(archiveFile, false)
+
+	for _, file := range result.Added {
+		a.logger.Log("debug", fmt.Sprintf("   + %s", file))
+	}
+	for _, file := range result.Modified {
+		a.logger.Log("debug", fmt.Sprintf("   ~ %s", file))
+	}
+	for _, file := range result.Removed {
+		a.logger.Log("debug", fmt.Sprintf("   - %s", file))
+	}
+	a.logger.Log("success", fmt.Sprintf("Updated %s: %d added, %d modified, %d removed, %d unchanged",
+		archiveFile, len(result.Added), len(result.Modified), len(result.Removed), result.Unchanged))
+
+	return nil
+}
+
+// diffCommand compares an archive with another archive or a directory.
+func (a *App) diffCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: diff <archive|dir> <archive|dir> [--stat] [--name-only] [--json] [--exclude pattern]")
+	}
+
+	oldPath := args[0]
+	newPath := args[1]
+
+	var excludePatterns []string
+	stat, nameOnly, asJSON := false, false, false
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case "--stat":
+			stat = true
+		case "--name-only":
+			nameOnly = true
+		case "--json":
+			asJSON = true
+		case "--exclude":
+			if i+1 < len(args) {
+				excludePatterns = append(excludePatterns, args[i+1])
+				i++
+			}
+		}
+	}
+	excludePatterns = a.excludes(excludePatterns)
+
+	oldSrc, err := diff.Load(oldPath, excludePatterns)
+	if err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+	newSrc, err := diff.Load(newPath, excludePatterns)
+	if err != nil {
+		return fmt.Errorf("diff failed: %w", err)
+	}
+
+	result := diff.Compare(oldPath, oldSrc, newPath, newSrc, diff.DefaultContext)
+
+	switch {
+	case asJSON:
+		return result.WriteJSON(os.Stdout)
+	case nameOnly:
+		return result.WriteNameOnly(os.Stdout)
+	case stat:
+		return result.WriteStat(os.Stdout)
+	}
+
+	if !result.HasChanges() {
+		a.logger.Log("success", fmt.Sprintf("No differences (%d files compared)", result.Summary.Unchanged))
+		return nil
+	}
+	return result.WriteText(os.Stdout)
+}
+
+// mergeCommand combines several archives into one.
+func (a *App) mergeCommand(args []string) error {
+	usage := fmt.Errorf("usage: merge <archive>... -o <output> [--prefix archive=path/] [--on-conflict first-wins|last-wins|fail|keep-both-renamed]")
+
+	var inputs []archive.CombineInput
+	prefixes := map[string]string{}
+	output, policyName := "", ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-o", "--output":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		case "--prefix":
+			if i+1 < len(args) {
+				input, prefix, ok := strings.Cut(args[i+1], "=")
+				if !ok {
+					return fmt.Errorf("invalid --prefix %q, expected archive=path/", args[i+1])
+				}
+				prefixes[input] = prefix
+				i++
+			}
+		case "--on-conflict":
+			if i+1 < len(args) {
+				policyName = args[i+1]
+				i++
+			}
+		default:
+			inputs = append(inputs, archive.CombineInput{Path: args[i]})
+		}
+	}
+	if len(inputs) < 2 || output == "" {
+		return usage
+	}
+
+	// Prefixes may name an input by the path given or by its base name
+	for i := range inputs {
+		if prefix, ok := prefixes[inputs[i].Path]; ok {
+			inputs[i].Prefix = prefix
+		} else if prefix, ok := prefixes[filepath.Base(inputs[i].Path)]; ok {
+			inputs[i].Prefix = prefix
+		}
+	}
+	policy, err := archive.ParseConflictPolicy(policyName)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Log("info", fmt.Sprintf("Merging %d archives into %s (on conflict: %s)", len(inputs), output, policy))
+
+	result, err := archive.Combine(inputs, output, policy)
+	if err != nil {
+		return fmt.Errorf("merge failed: %w", err)
+	}
+
+	if len(result.Conflicts) > 0 {
+		a.logger.Log("warn", fmt.Sprintf("%d conflicting paths resolved with %s:", len(result.Conflicts), policy))
+		for _, name := range result.Conflicts {
+			if renamed, ok := result.Renamed[name]; ok {
+				a.logger.Log("warn", fmt.Sprintf("   %s (also kept as %s)", name, renamed))
+			} else {
+				a.logger.Log("warn", fmt.Sprintf("   %s", name))
+			}
+		}
+	}
+
+	a.recordMergeBases(output, false)
+	a.logger.Log("success", fmt.Sprintf("Merged %d files into %s", result.TotalFiles, output))
+	return nil
+}
+
+// convertCommand rewrites an archive in another marker dialect or document
+// format. --from names the dialect or format of the input when it should not
+// be detected, as for txtar files without marker-like content.
+func (a *App) convertCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: convert <input> <output> --to <preset|xml|json|jsonl> [--from <preset|xml|json|jsonl>]")
+	}
+
+	input, output := args[0], args[1]
+	target, from := "", ""
+	for i := 2; i < len(args); i++ {
+		switch {
+		case args[i] == "--to" && i+1 < len(args):
+			target = args[i+1]
+			i++
+		case args[i] == "--from" && i+1 < len(args):
+			from = args[i+1]
+			i++
+		}
+	}
+	dialect, ok := metadata.LookupDialect(target)
+	if !ok && !formats.IsFormat(target) {
+		return fmt.Errorf("convert requires --to with a marker dialect or document format (one of %s), got %q",
+			strings.Join(append(metadata.PresetNames(), formats.Names()...), ", "), target)
+	}
+	source, ok := metadata.LookupDialect(from)
+	if from != "" && !ok && !formats.CanRead(from) {
+		return fmt.Errorf("unknown --from %q (one of %s)", from, strings.Join(append(metadata.PresetNames(), formats.Readers()...), ", "))
+	}
+
+	a.logger.Log("info", fmt.Sprintf("Converting %s to %s", input, target))
+
+	data, err := parser.ReadArchive(input)
+	if err != nil {
+		return fmt.Errorf("convert failed: %w", err)
+	}
+	var converted *archive.Archive
+	switch {
+	case formats.IsFormat(target):
+		results, err := a.parseAs(data, input, from)
+		if err != nil {
+			return fmt.Errorf("convert failed: %w", err)
+		}
+		out, err := formats.Encode(results.Markers, target)
+		if err == nil {
+			err = archive.WriteFileAtomic(output, out)
+		}
+		if err != nil {
+			return fmt.Errorf("convert failed: %w", err)
+		}
+		a.logger.Log("success", fmt.Sprintf("Converted %d files into %s", results.TotalFiles, output))
+		return nil
+	case formats.CanRead(from) || (from == "" && formats.Detect(data) != ""):
+		var results *parser.ParseResults
+		if results, err = a.parseAs(data, input, from); err == nil {
+			converted, err = archive.FromMarkers(dialect.Config(), results.Markers)
+		}
+	case source != nil:
+		converted, err = archive.ParseWithDialect(data, source).Convert(dialect.Config())
+	default:
+		var loaded *archive.Archive
+		if loaded, err = archive.Load(input); err == nil {
+			converted, err = loaded.Convert(dialect.Config())
+		}
+	}
+	if err != nil {
+		var collision *archive.CollisionError
+		if errors.As(err, &collision) {
+			for _, c := range collision.Collisions {
+				a.logger.Log("warn", fmt.Sprintf("   %s:%d: %s", c.Entry, c.Line, c.Text))
+			}
+		}
+		return fmt.Errorf("convert failed: %w", err)
+	}
+	if err := converted.WriteFile(output); err != nil {
+		return fmt.Errorf("convert failed: %w", err)
+	}
+
+	a.logger.Log("success", fmt.Sprintf("Converted %d files into %s", len(converted.Files()), output))
+	return nil
+}
+
+// parseAs parses archive content in the dialect or document format named by
+// from, or in the detected one when from is empty.
+func (a *App) parseAs(data []byte, input, from string) (*parser.ParseResults, error) {
+	if formats.CanRead(from) {
+		return formats.Decode(data, from)
+	}
+	if d, ok := metadata.LookupDialect(from); ok {
+		_, rest, err := metadata.ParseFrontmatter(data)
+		if err != nil {
+			return nil, err
+		}
+		return parser.ParseContent(rest, d), nil
+	}
+	results, _, err := a.reader.ParseContent(data, input)
+	return results, err
+}
+
+// detectCommand reports the marker dialect or document format of an archive and
+// how sure the choice is.
+func (a *App) detectCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: detect <archive> [--json]")
+	}
+
+	input, asJSON := args[0], slices.Contains(args[1:], "--json")
+	data, err := parser.ReadArchive(input)
+	if err != nil {
+		return fmt.Errorf("detect failed: %w", err)
+	}
+	detected, ok, err := formats.DetectDocument(data)
+	if err == nil && !ok {
+		detected, err = metadata.Detect(data)
+	}
+	if err != nil {
+		return fmt.Errorf("detect failed: %w", err)
+	}
+
+	if asJSON {
+		return detected.WriteJSON(os.Stdout)
+	}
+	return detected.WriteText(os.Stdout)
+}
+
+// exportCommand writes the files of an archive to a tar, tar.gz or zip file.
+func (a *App) exportCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: export <archive> <output.tar|output.tar.gz|output.zip>")
+	}
+
+	archiveFile, output := args[0], args[1]
+	archiveFile, cleanup, err := a.joinChunkSet(archiveFile)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	defer cleanup()
+
+	result, err := exchange.ExportFile(archiveFile, output)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	a.logger.Log("success", fmt.Sprintf("Exported %d files to %s", result.Files, output))
+	return nil
+}
+
+// importCommand builds an archive from the regular files of a tar, tar.gz or zip file.
+func (a *App) importCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: import <input.tar|input.tar.gz|input.zip> <archive>")
+	}
+
+	input, output := args[0], args[1]
+	result, err := exchange.ImportFile(input, output)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	if len(result.Skipped) > 0 {
+		a.logger.Log("warn", fmt.Sprintf("Skipped %d entries:", len(result.Skipped)))
+		for _, name := range result.Skipped {
+			a.logger.Log("warn", fmt.Sprintf("   %s", name))
+		}
+	}
+	a.logger.Log("success", fmt.Sprintf("Imported %d files into %s", result.Files, output))
+	return nil
+}
+
+// addCommand adds files, or the files below directories, to an existing archive.
+func (a *App) addCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: add <archive> <file|dir>... [--as name]")
+	}
+
+	archiveFile := args[0]
+	var paths []string
+	as := ""
+	for i := 1; i < len(args); i++ {
+		if args[i] == "--as" && i+1 < len(args) {
+			as = args[i+1]
+			i++
+			continue
+		}
+		paths = append(paths, args[i])
+	}
+	if as != "" {
+		if info, err := os.Stat(paths[0]); len(paths) != 1 || (err == nil && info.IsDir()) {
+			return fmt.Errorf("--as requires exactly one file")
+		}
+	}
+
+	ar, err := archive.Load(archiveFile)
+	if err != nil {
+		return fmt.Errorf("add failed: %w", err)
+	}
+
+	added, updated := 0, 0
+	for _, p := range paths {
+		err := filepath.Walk(p, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(p, file)
+			if info.IsDir() {
+				if file != p && parser.MatchesExclude(rel, defaultExcludePatterns) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if file != p && parser.MatchesExclude(rel, defaultExcludePatterns) {
+				return nil
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			name := file
+			if as != "" {
+				name = as
+			}
+			isNew, err := ar.Set(name, string(content))
+			if err != nil {
+				return err
+			}
+			if isNew {
+				added++
+				a.logger.Log("debug", fmt.Sprintf("   + %s", archive.EntryName(name)))
+			} else {
+				updated++
+				a.logger.Log("debug", fmt.Sprintf("   ~ %s", archive.EntryName(name)))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("add failed: %w", err)
+		}
+	}
+
+	if err := a.saveEdited(ar, archiveFile); err != nil {
+		return err
+	}
+	a.logger.Log("success", fmt.Sprintf("Added %d and updated %d entries in %s", added, updated, archiveFile))
+	return nil
+}
+
+// rmCommand removes entries, or whole directories of entries, from an archive.
+func (a *App) rmCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: rm <archive> <path>...")
+	}
+
+	archiveFile := args[0]
+	ar, err := archive.Load(archiveFile)
+	if err != nil {
+		return fmt.Errorf("rm failed: %w", err)
+	}
+
+	var removed []string
+	for _, name := range args[1:] {
+		names := ar.Remove(name)
+		if len(names) == 0 {
+			return fmt.Errorf("rm failed: no entry named %s", name)
+		}
+		removed = append(removed, names...)
+	}
+
+	if err := a.saveEdited(ar, archiveFile); err != nil {
+		return err
+	}
+	for _, name := range removed {
+		a.logger.Log("debug", fmt.Sprintf("   - %s", name))
+	}
+	a.logger.Log("success", fmt.Sprintf("Removed %d entries from %s", len(removed), archiveFile))
+	return nil
+}
+
+// mvCommand renames an entry, or a directory of entries, inside an archive.
+func (a *App) mvCommand(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: mv <archive> <old-path> <new-path>")
+	}
+
+	archiveFile := args[0]
+	ar, err := archive.Load(archiveFile)
+	if err != nil {
+		return fmt.Errorf("mv failed: %w", err)
+	}
+	moves, err := ar.Move(args[1], args[2])
+	if err != nil {
+		return fmt.Errorf("mv failed: %w", err)
+	}
+
+	if err := a.saveEdited(ar, archiveFile); err != nil {
+		return err
+	}
+	for from, to := range moves {
+		a.logger.Log("debug", fmt.Sprintf("   %s -> %s", from, to))
+	}
+	a.logger.Log("success", fmt.Sprintf("Moved %d entries in %s", len(moves), archiveFile))
+	return nil
+}
+
+// replaceCommand replaces the content of an existing entry with standard input.
+func (a *App) replaceCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: replace <archive> <path> < new-content")
+	}
+
+	archiveFile, name := args[0], args[1]
+	ar, err := archive.Load(archiveFile)
+	if err != nil {
+		return fmt.Errorf("replace failed: %w", err)
+	}
+	if ar.Index(archive.EntryName(name)) < 0 {
+		return fmt.Errorf("replace failed: no entry named %s (use add for new files)", name)
+	}
+
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("replace failed: failed to read standard input: %w", err)
+	}
+	if _, err := ar.Set(name, string(content)); err != nil {
+		return fmt.Errorf("replace failed: %w", err)
+	}
+
+	if err := a.saveEdited(ar, archiveFile); err != nil {
+		return err
+	}
+	a.logger.Log("success", fmt.Sprintf("Replaced %s in %s (%d bytes)", archive.EntryName(name), archiveFile, len(content)))
+	return nil
+}
+
+// saveEdited refreshes the project info of an edited archive and writes it atomically.
+func (a *App) saveEdited(ar *archive.Archive, archiveFile string) error {
+	ar.RefreshInfo()
+	if err := ar.WriteFile(archiveFile); err != nil {
+		return fmt.Errorf("failed to write %s: %w", archiveFile, err)
+	}
+	a.recordMergeBases(archiveFile, false)
+	return nil
+}
+
+// listCommand lists the files of an archive or directory with token estimates.
+func (a *App) listCommand(args []string) error {
+	report, asJSON, err := a.tokenReport("list", args)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return report.WriteJSON(os.Stdout)
+	}
+	return report.WriteList(os.Stdout)
+}
+
+// statsCommand summarizes the size and token cost of an archive or directory.
+func (a *App) statsCommand(args []string) error {
+	report, asJSON, err := a.tokenReport("stats", args)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return report.WriteJSON(os.Stdout)
+	}
+	return report.WriteStats(os.Stdout)
+}
+
+// tokenReport parses the shared list/stats arguments and counts the input.
+func (a *App) tokenReport(command string, args []string) (*tokens.Report, bool, error) {
+	if len(args) < 1 {
+		return nil, false, fmt.Errorf("usage: %s <archive|dir> [--tokenizer name] [--json] [--exclude pattern]", command)
+	}
+
+	input := args[0]
+	var excludePatterns []string
+	tokenizerName, asJSON := a.settings.Tokenizer, false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--tokenizer":
+			if i+1 < len(args) {
+				tokenizerName = args[i+1]
+				i++
+			}
+		case "--exclude":
+			if i+1 < len(args) {
+				excludePatterns = append(excludePatterns, args[i+1])
+				i++
+			}
+		}
+	}
+	excludePatterns = a.excludes(excludePatterns)
+
+	tk, err := tokens.Get(tokenizerName)
+	if err != nil {
+		return nil, false, err
+	}
+	input, cleanup, err := a.joinChunkSet(input)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s failed: %w", command, err)
+	}
+	defer cleanup()
+
+	files, err := diff.Load(input, excludePatterns)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s failed: %w", command, err)
+	}
+	return tokens.NewReport(tk, files), asJSON, nil
+}
+
 // transpileCommand handles Markdown to HTML transpilation.
 func (a *App) transpileCommand(args []string) error {
 	if len(args) < 2 {
@@ -310,18 +1100,75 @@ func (a *App) transpileCommand(args []string) error {
 
 // generateCommand handles project consolidation (directory -> marked file).
 func (a *App) generateCommand(args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: generate <source-dir> <output-file> [--exclude patterns]")
+	usage := fmt.Errorf("usage: generate <source-dir> [output-file] [--profile name] [--exclude patterns] [--armor] [--git | --git-rev rev] [--since ref | --changed-from dir [--context N]] [--max-tokens N] [--max-bytes N] [--token-budget N [--priority glob]] [--tokenizer name] [--format xml|json|jsonl | --sfx out.sh] [--record-base]")
+	if len(args) < 1 {
+		return usage
 	}
 
-	sourceDir := args[0]
-	outputFile := args[1]
+	// The output file may be omitted when the config names one or with --sfx
+	sourceDir, outputFile, positional := args[0], a.settings.Output, false
+	args = args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		outputFile, args, positional = args[0], args[1:], true
+	}
 
     // Parse flags from args
     var excludePatterns []string
-    var markerPreset, markerStart, markerEnd, markerPattern string
-    for i := 2; i < len(args); i++ {
+    var markerPreset, markerStart, markerEnd, markerPattern, format, sfxFile string
+    armor, watchMode, gitMode, recordBase := config.Flag(a.settings.Armor), false, false, false
+    gitRev, since, changedFrom := "", "", ""
+    var changes changeset.Options
+    var limits archive.SplitLimits
+    budget := archive.BudgetOptions{MaxTokens: a.settings.TokenBudget}
+    tokenizerName := a.settings.Tokenizer
+    for i := 0; i < len(args); i++ {
         switch args[i] {
+        case "--token-budget":
+            if i+1 < len(args) {
+                n, err := strconv.Atoi(args[i+1])
+                if err != nil || n <= 0 { return fmt.Errorf("invalid --token-budget value: %s", args[i+1]) }
+                budget.MaxTokens = n
+                i++
+            }
+        case "--priority":
+            if i+1 < len(args) { budget.Priority = append(budget.Priority, args[i+1]); i++ }
+        case "--tokenizer":
+            if i+1 < len(args) { tokenizerName = args[i+1]; i++ }
+        case "--max-tokens":
+            if i+1 < len(args) {
+                n, err := strconv.Atoi(args[i+1])
+                if err != nil || n <= 0 { return fmt.Errorf("invalid --max-tokens value: %s", args[i+1]) }
+                limits.MaxTokens = n
+                i++
+            }
+        case "--max-bytes":
+            if i+1 < len(args) {
+                n, err := strconv.ParseInt(args[i+1], 10, 64)
+                if err != nil || n <= 0 { return fmt.Errorf("invalid --max-bytes value: %s", args[i+1]) }
+                limits.MaxBytes = n
+                i++
+            }
+        case "--armor":
+            armor = true
+        case "--record-base":
+            recordBase = true
+        case "--git":
+            gitMode = true
+        case "--git-rev":
+            if i+1 < len(args) { gitRev = args[i+1]; i++ }
+        case "--since":
+            if i+1 < len(args) { since = args[i+1]; i++ }
+        case "--changed-from":
+            if i+1 < len(args) { changedFrom = args[i+1]; i++ }
+        case "--context":
+            if i+1 < len(args) {
+                n, err := strconv.Atoi(args[i+1])
+                if err != nil || n < 0 { return fmt.Errorf("invalid --context value: %s", args[i+1]) }
+                changes.Excerpts, changes.Context = true, n
+                i++
+            }
+        case "--watch":
+            watchMode = true
         case "--exclude":
             if i+1 < len(args) { excludePatterns = append(excludePatterns, args[i+1]); i++ }
         case "--marker-preset":
@@ -332,50 +1179,137 @@ func (a *App) generateCommand(args []string) error {
             if i+1 < len(args) { markerEnd = args[i+1]; i++ }
         case "--marker-pattern":
             if i+1 < len(args) { markerPattern = args[i+1]; i++ }
+        case "--format":
+            if i+1 < len(args) { format = args[i+1]; i++ }
+        case "--sfx":
+            if i+1 < len(args) { sfxFile = args[i+1]; i++ }
         }
     }
 
-	// Default exclude patterns
-	if len(excludePatterns) == 0 {
-		excludePatterns = []string{
-			"*.git*", "node_modules", "dist", "build", "*.log", "*.tmp",
+	if watchMode && (limits.Enabled() || budget.MaxTokens > 0) {
+		return fmt.Errorf("--watch cannot be combined with --max-tokens, --max-bytes or --token-budget")
+	}
+	if watchMode && (gitMode || gitRev != "" || since != "" || changedFrom != "") {
+		return fmt.Errorf("--watch cannot be combined with --git, --git-rev, --since or --changed-from")
+	}
+	if sfxFile != "" {
+		if positional {
+			return fmt.Errorf("--sfx names the output file; drop the output argument")
 		}
+		if format != "" || armor || watchMode || recordBase || limits.Enabled() || gitRev != "" || since != "" || changedFrom != "" {
+			return fmt.Errorf("--sfx cannot be combined with --format, --armor, --watch, --record-base, --max-tokens, --max-bytes, --git-rev, --since or --changed-from")
+		}
+		outputFile = sfxFile
+	}
+	if outputFile == "" {
+		return usage
+	}
+	if format != "" && !formats.IsFormat(format) {
+		return fmt.Errorf("unknown --format %q (one of %s)", format, strings.Join(formats.Names(), ", "))
 	}
+	if format != "" && (armor || watchMode || limits.Enabled()) {
+		return fmt.Errorf("--format cannot be combined with --armor, --watch, --max-tokens or --max-bytes")
+	}
+	if since != "" && changedFrom != "" {
+		return fmt.Errorf("--since and --changed-from cannot be combined")
+	}
+	if changes.Excerpts && since == "" && changedFrom == "" {
+		return fmt.Errorf("--context requires --since or --changed-from")
+	}
+	tk, err := tokens.Get(tokenizerName)
+	if err != nil {
+		return err
+	}
+	budget.Tokenizer, limits.Tokenizer = tk, tk
+
+	excludePatterns = a.excludes(excludePatterns)
 
 	a.logger.Log("info", fmt.Sprintf("Generating marked file from %s to %s", sourceDir, outputFile))
 
-    // If custom marker parameters provided, use adaptive generator
-    if markerPreset != "" || markerStart != "" || markerEnd != "" || markerPattern != "" {
-        ap := adaptive.New()
-        // Compose config
-        var cfg metadata.MarkerConfig
-        if markerPreset != "" {
-            presets := metadata.GetPresetConfigs()
-            if p, ok := presets[markerPreset]; ok { cfg = p.Config } else { cfg = metadata.GetDefaultConfig() }
-        } else {
-            cfg = metadata.GetDefaultConfig()
-        }
-        if markerPattern != "" { cfg.Pattern = markerPattern }
-        if markerStart != "" { cfg.Start = markerStart }
-        if markerEnd != "" { cfg.End = markerEnd }
-
-        res, err := ap.GenerateFromDirectory(sourceDir, outputFile, excludePatterns, &cfg)
-        if err != nil { return fmt.Errorf("generation failed (adaptive): %w", err) }
-        // Map to log summary
-        if len(res.Errors) > 0 {
-            a.logger.Log("warn", "Generation completed with warnings:")
-            for _, e := range res.Errors { a.logger.Log("warn", "   %s", e) }
-        }
-        a.logger.Log("success", "Successfully generated marked file:")
-        a.logger.Log("success", fmt.Sprintf("   📁 %d files processed", res.TotalFiles))
-        a.logger.Log("success", fmt.Sprintf("   📊 %d bytes written", res.TotalBytes))
-        a.logger.Log("success", fmt.Sprintf("   📄 Output: %s", outputFile))
-        return nil
+    // Compose the marker config when custom marker parameters are provided
+    if markerPreset == "" && markerStart == "" && markerEnd == "" && markerPattern == "" {
+        markerPreset = a.settings.Preset
+    }
+    custom := markerPreset != "" || markerStart != "" || markerEnd != "" || markerPattern != ""
+    cfg := metadata.GetDefaultConfig()
+    if markerPreset != "" {
+        d, ok := metadata.LookupDialect(markerPreset)
+        if !ok { return fmt.Errorf("unknown marker preset: %s (see lookatni presets)", markerPreset) }
+        cfg = d.Config()
     }
+    if markerPattern != "" { cfg.Pattern = markerPattern }
+    if markerStart != "" { cfg.Start = markerStart }
+    if markerEnd != "" { cfg.End = markerEnd }
+
+	var result *parser.GenerateResults
+	switch {
+	case since != "" || changedFrom != "":
+		changes.Config = cfg
+		var res *changeset.Results
+		if since != "" {
+			res, err = changeset.SinceRev(sourceDir, since, gitRev, outputFile, changes)
+		} else {
+			res, err = changeset.BetweenDirs(changedFrom, sourceDir, excludePatterns, outputFile, changes)
+		}
+		if err != nil {
+			return fmt.Errorf("generation failed (changeset): %w", err)
+		}
+		result = res.GenerateResults
+		a.logger.Log("info", fmt.Sprintf("Changeset: %d added, %d modified, %d renamed, %d deleted",
+			res.Summary.Added, res.Summary.Modified, res.Summary.Renamed, len(res.Deleted)))
+		if len(res.Excerpts) > 0 {
+			a.logger.Log("info", fmt.Sprintf("%d files stored as excerpts with %d lines of context", len(res.Excerpts), changes.Context))
+		}
+	case gitMode || gitRev != "":
+		result, err = gitsrc.Generate(sourceDir, gitRev, outputFile, cfg)
+		if err != nil {
+			return fmt.Errorf("generation failed (git): %w", err)
+		}
+	case custom:
+		result, err = adaptive.New().GenerateFromDirectory(sourceDir, outputFile, excludePatterns, &cfg)
+		if err != nil {
+			return fmt.Errorf("generation failed (adaptive): %w", err)
+		}
+	default:
+		result, err = a.parser.GenerateFromDirectory(sourceDir, outputFile, excludePatterns)
+		if err != nil {
+			return fmt.Errorf("generation failed: %w", err)
+		}
+	}
 
-    result, err := a.parser.GenerateFromDirectory(sourceDir, outputFile, excludePatterns)
-	if err != nil {
-		return fmt.Errorf("generation failed: %w", err)
+	if recordBase {
+		a.recordMergeBases(outputFile, true)
+	}
+
+	if err := a.applyTokenBudget(outputFile, budget, result); err != nil {
+		return err
+	}
+
+	if format != "" {
+		if result.TotalBytes, err = formats.ConvertFile(outputFile, format); err != nil {
+			return fmt.Errorf("generation failed (%s): %w", format, err)
+		}
+		a.logger.Log("info", fmt.Sprintf("Archive written as %s", format))
+	}
+
+	if sfxFile != "" {
+		if result.TotalBytes, err = sfx.ConvertFile(outputFile, sourceDir); err != nil {
+			return fmt.Errorf("generation failed (sfx): %w", err)
+		}
+		a.logger.Log("info", fmt.Sprintf("Self-extracting script written; run: sh %s --target <dir>", outputFile))
+	}
+
+	if armor {
+		if result.TotalBytes, err = parser.ArmorFile(outputFile); err != nil {
+			return fmt.Errorf("armoring failed: %w", err)
+		}
+		a.logger.Log("info", "Archive wrapped in ASCII armor")
+	}
+
+	if limits.Enabled() {
+		if err := a.splitOutput(outputFile, limits); err != nil {
+			return err
+		}
 	}
 
 	if len(result.Errors) > 0 {
@@ -388,11 +1322,158 @@ func (a *App) generateCommand(args []string) error {
 	a.logger.Log("success", "Successfully generated marked file:")
 	a.logger.Log("success", fmt.Sprintf("   📁 %d files processed", result.TotalFiles))
 	a.logger.Log("success", fmt.Sprintf("   📊 %d bytes written", result.TotalBytes))
+	a.logger.Log("success", fmt.Sprintf("   🔢 ~%d tokens (%s)", result.TotalTokens, result.Tokenizer))
 	a.logger.Log("success", fmt.Sprintf("   📄 Output: %s", outputFile))
 
+	if watchMode {
+		return a.watchAndUpdate(sourceDir, outputFile, excludePatterns)
+	}
+
 	return nil
 }
 
+// watchAndUpdate keeps an archive in sync with its source directory until interrupted,
+// applying incremental updates after debounced filesystem changes.
+func (a *App) watchAndUpdate(sourceDir, outputFile string, excludePatterns []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a.logger.Log("info", fmt.Sprintf("👀 Watching %s for changes (Ctrl+C to stop)", sourceDir))
+
+	rebuild := func() error {
+		started := time.Now()
+		result, err := archive.Update(outputFile, sourceDir, excludePatterns)
+		if err != nil {
+			return fmt.Errorf("rebuild failed: %w", err)
+		}
+		if !result.Changed() {
+			return nil
+		}
+		a.recordMergeBases(outputFile, false)
+		a.logger.Log("info", fmt.Sprintf("Rebuilt %s: +%d ~%d -%d (%d unchanged) in %s",
+			outputFile, len(result.Added), len(result.Modified), len(result.Removed), result.Unchanged, time.Since(started).Round(time.Millisecond)))
+		return nil
+	}
+	onError := func(err error) {
+		a.logger.Log("warn", err.Error())
+	}
+
+	opts := watch.Options{
+		ExcludePatterns: excludePatterns,
+		SkipPaths:       []string{outputFile, outputFile + archive.CacheSuffix},
+	}
+	return watch.Watch(ctx, sourceDir, opts, rebuild, onError)
+}
+
+// applyTokenBudget drops files from a freshly generated archive until it fits the
+// budget and recounts tokens with the requested tokenizer.
+func (a *App) applyTokenBudget(outputFile string, budget archive.BudgetOptions, result *parser.GenerateResults) error {
+	if budget.MaxTokens == 0 && budget.Tokenizer.Name() == result.Tokenizer {
+		return nil
+	}
+	counted, err := archive.ApplyBudget(outputFile, budget)
+	if err != nil {
+		return fmt.Errorf("token budget failed: %w", err)
+	}
+	result.Tokenizer = counted.Tokenizer
+	result.TotalTokens = counted.TotalTokens
+	result.TotalFiles = counted.TotalFiles
+	result.TotalBytes = counted.TotalBytes
+	result.FileTokens = counted.FileTokens
+	result.DroppedFiles = counted.Dropped
+
+	if len(counted.Dropped) > 0 {
+		a.logger.Log("warn", fmt.Sprintf("Dropped %d files to fit the %d token budget:", len(counted.Dropped), budget.MaxTokens))
+		for _, name := range counted.Dropped {
+			a.logger.Log("warn", fmt.Sprintf("   - %s", name))
+		}
+	}
+	return nil
+}
+
+// splitOutput replaces a freshly generated archive with a chunk set within limits.
+func (a *App) splitOutput(outputFile string, limits archive.SplitLimits) error {
+	result, err := archive.Split(outputFile, limits)
+	if err != nil {
+		return fmt.Errorf("splitting failed: %w", err)
+	}
+	a.logger.Log("info", fmt.Sprintf("Split archive into %d chunks (manifest: %s)", len(result.Chunks), result.Manifest))
+	for _, chunk := range result.Chunks {
+		a.logger.Log("debug", fmt.Sprintf("   📦 %s", chunk))
+	}
+	if len(result.SplitFiles) > 0 {
+		a.logger.Log("info", fmt.Sprintf("%d files exceed one chunk and were stored in parts", len(result.SplitFiles)))
+	}
+	for _, name := range result.Oversized {
+		a.logger.Log("warn", fmt.Sprintf("   %s has a line longer than the chunk limit", name))
+	}
+	return nil
+}
+
+// joinChunkSet returns a path to a single archive for markedFile. When markedFile
+// names a chunk set, its chunks are joined into a temporary file that cleanup removes.
+func (a *App) joinChunkSet(markedFile string) (string, func(), error) {
+	noop := func() {}
+	chunks, ok, err := archive.ResolveChunkSet(markedFile)
+	if err != nil {
+		return "", noop, err
+	}
+	if !ok {
+		return markedFile, noop, nil
+	}
+	data, err := archive.JoinChunks(chunks)
+	if err != nil {
+		return "", noop, err
+	}
+	tmp, err := os.CreateTemp("", "lookatni-chunks-*.lkt")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	a.logger.Log("info", fmt.Sprintf("Reading chunk set of %d files", len(chunks)))
+	return tmp.Name(), cleanup, nil
+}
+
+// mirrorOutput deletes files under the output directory that the archive no longer contains.
+func (a *App) mirrorOutput(markedFile, outputDir string, options parser.ExtractOptions, result *parser.ExtractResults) error {
+	parsed, _, err := a.reader.ParseMarkedFile(markedFile)
+	if err != nil {
+		return err
+	}
+	pruned, err := mirror.Apply(outputDir, parsed, result, options.Scope, options.DryRun)
+	if err != nil {
+		return err
+	}
+	result.DeletedFiles = append(result.DeletedFiles, pruned.DeletedFiles...)
+	result.Errors = append(result.Errors, pruned.Errors...)
+	return nil
+}
+
+// recordMergeBases stores the entries of an archive as bases for later
+// `extract --merge`: of any archive when record is set, as with generate
+// --record-base, otherwise only of archives that already record them.
+func (a *App) recordMergeBases(outputFile string, record bool) {
+	recordBases := merge.UpdateBases
+	if record {
+		recordBases = merge.RecordBases
+	}
+	count, err := recordBases(outputFile)
+	if err != nil {
+		a.logger.Log("warn", fmt.Sprintf("Failed to record merge bases: %v", err))
+		return
+	}
+	a.logger.Log("debug", fmt.Sprintf("Recorded %d merge bases", count))
+}
+
 // refactorCommand handles AI-powered code refactoring using Grompt integration.
 func (a *App) refactorCommand(args []string) error {
 	if len(args) < 1 {
@@ -513,12 +1594,30 @@ Usage:
 Commands:
   extract <marked-file> <output-dir> [flags]  Extract files FROM marked content
   validate <marked-file>                      Validate markers in consolidated file
-  generate <source-dir> <output-file> [flags] Consolidate directory INTO marked file
+  generate <source-dir> [output-file] [flags] Consolidate directory INTO marked file
+  update <archive> <source-dir> [flags]       Rewrite only changed entries of an existing archive
+  diff <archive|dir> <archive|dir> [flags]    Show changes between archives or an archive and a directory
+  merge <archive>... -o <output> [flags]       Combine archives into one
+  convert <input> <output> --to <preset|fmt> Rewrite an archive in another marker dialect or xml, json, jsonl
+                                              (--from <preset|fmt> skips detection, e.g. --from txtar)
+  detect <archive> [--json]                   Report the marker dialect or format and how confident the guess is
+                                              (also repomix, gitingest and files-to-prompt output, which extract unpacks)
+  export <archive> <out.tar|.tar.gz|.zip>     Write archive files to a tar or zip file
+  import <in.tar|.tar.gz|.zip> <archive>      Build an archive from a tar or zip file
+  add <archive> <file|dir>... [--as name]     Add or update entries in place
+  rm <archive> <path>...                      Remove entries (or directories of entries)
+  mv <archive> <old> <new>                    Rename an entry or directory of entries
+  replace <archive> <path> < content          Replace an entry with standard input
+  list <archive|dir> [flags]                  List files with their size and token estimate
+  stats <archive|dir> [flags]                 Summarize files, bytes, lines and tokens
+  presets [--json]                            List marker presets and profiles with their source file
+  prune-bases [--older-than <days>]           Remove merge bases recorded by generate --record-base (default: 90 days old)
   transpile <input> <output-dir> [flags]      Convert Markdown to HTML with AI
   help                                        Show this help
 
 Global Flags:
   --list-presets                              List available marker presets
+  --profile <name>                            Apply a profile from .lookatni.yaml or the user config
   --version                                   Show version information
   --vscode                                    Run in VS Code integration mode
   --port <num>                                Port for VS Code server (default: 8080)
@@ -528,9 +1627,42 @@ Extract Flags:
   --overwrite     Overwrite existing files
   --create-dirs   Create directories as needed
   --dry-run       Show what would be done without doing it
+  --merge         Three-way merge entries into locally modified files
+  --mirror        Delete files the archive no longer contains (honours ignore files)
+  --scope <path>  Limit --mirror deletions to a subpath of the output directory
 
 Generate Flags:
   --exclude <pattern>  Exclude files matching pattern (can be used multiple times)
+  --armor              Wrap the archive in printable ASCII armor (auto-detected on read)
+  --watch              Keep regenerating incrementally as files change
+  --git                Archive exactly the files tracked by git (honours export-ignore)
+  --git-rev <rev>      Archive the tree of a git commit; both record the commit hash
+  --since <ref>        Archive only files changed since a git ref, listing deleted paths
+  --changed-from <dir> Archive only files changed relative to an older copy of the source
+  --context <n>        Store large modified files as diff hunks with n lines of context
+  --max-tokens <n>     Split output into out.001.lkt, out.002.lkt, ... of at most n tokens each
+  --max-bytes <n>      Split output into chunks of at most n bytes each
+  --token-budget <n>   Keep the archive under n tokens, dropping low-priority files
+  --priority <glob>    Admit matching files first under --token-budget (repeatable)
+  --tokenizer <name>   Token estimator: cl100k (default) or chars4
+  --format <fmt>       Write xml documents, a json array or jsonl instead of marker lines
+  --sfx <out.sh>       Write a POSIX sh script that recreates the files without lookatni
+                       (sh out.sh [--dry-run] [--target dir])
+  --record-base        Record file contents as bases for later extract --merge
+
+Merge Flags:
+  -o, --output <file>        Combined archive to write
+  --prefix <archive>=<path>  Place the files of one input under a path prefix (repeatable)
+  --on-conflict <policy>     first-wins, last-wins, fail (default) or keep-both-renamed
+
+List/Stats Flags:
+  --tokenizer <name>   Token estimator: cl100k (default) or chars4
+  --json               Emit the result as JSON
+
+Diff Flags:
+  --stat          Show per-file line counts only
+  --name-only     Show changed paths only
+  --json          Emit the result as JSON
 
 Transpile Flags:
   --with-prompts  Enable AI-powered content enhancement via Grompt integration
@@ -539,6 +1671,8 @@ Transpile Flags:
   The new adaptive marker system supports multiple formats:
   • HTML Comments: <!-- FILE: filename -->
   • Markdown Invisible: [//]: # (FILE: filename)
+  • Markdown Fenced: ### filename over a fenced code block
+  • Go txtar: -- filename -- (convert --to txtar for testdata/*.txtar)
   • Code Comments: // === FILE: filename ===
   • Visual Separators: FILE: filename
   • Classic (ASCII 28): Invisible markers (default)
@@ -554,6 +1688,12 @@ Examples:
   lookatni transpile ./interviews ./output    # Convert Markdown to HTML
   lookatni transpile ./docs ./output --with-prompts  # AI-enhanced transpilation
 
+  # Project config: ~/.config/lookatni/config.yaml, then .lookatni.yaml (flags still win)
+  #   presets:  { review: { pattern: "## {filename}" } }
+  #   defaults: { exclude: [vendor], output: project.lkt }
+  #   profiles: { llm-review: { preset: review, token-budget: 100000 } }
+  lookatni generate . --profile llm-review
+
   # VS Code integration
   lookatni --vscode --port 8080               # Start integration server
 
@@ -0,0 +1,393 @@
+// Package archive provides an order-preserving, byte-exact model of a marked file,
+// used to edit archives in place without disturbing untouched entries.
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// Entry is a single marker section kept exactly as it appears in the archive.
+type Entry struct {
+	Name string
+	// Raw holds the marker line and the content lines, including their newlines.
+	Raw string
+	// dialect unwraps the content of block dialects.
+	dialect metadata.Dialect
+}
+
+// Content returns the entry content with the same normalization as the parsers:
+// the marker line is dropped, blocks are unwrapped and trailing newlines are
+// trimmed.
+func (e Entry) Content() string {
+	return strings.TrimRight(e.Body(), "\n")
+}
+
+// Body returns the text after the marker line: for line dialects the content
+// exactly as archived, for block dialects the content of the block with a
+// final newline.
+func (e Entry) Body() string {
+	_, body, _ := strings.Cut(e.Raw, "\n")
+	if _, ok := e.dialect.(metadata.BlockDialect); ok {
+		if body = metadata.UnwrapContent(e.dialect, body); body != "" && !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+	}
+	return body
+}
+
+// Archive is a parsed marked file that can be edited and written back.
+type Archive struct {
+	// Dialect reads and writes the marker lines.
+	Dialect metadata.Dialect
+	// Preamble holds everything before the first marker, frontmatter included.
+	Preamble string
+	Entries  []Entry
+	// Armored records whether the file was read from an ASCII-armored envelope.
+	Armored bool
+}
+
+// New creates an empty archive in the given dialect. Dialects other than the
+// default one are declared in YAML frontmatter, as the generators do, unless
+// they are undeclared.
+func New(config metadata.MarkerConfig) (*Archive, error) {
+	a, err := ParseWithConfig(nil, config)
+	if err != nil {
+		return nil, err
+	}
+	fm, err := metadata.FrontmatterFor(a.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	a.Preamble = string(fm)
+	return a, nil
+}
+
+// Load reads and parses an archive from disk.
+func Load(path string) (*Archive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	armored := parser.IsArmored(data)
+	if armored {
+		if data, err = parser.Dearmor(data); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	a, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	a.Armored = armored
+	return a, nil
+}
+
+// Parse splits archive content into its preamble and raw entries. The dialect
+// comes from frontmatter or, without it, from sniffing the content.
+func Parse(data []byte) (*Archive, error) {
+	detected, err := metadata.Detect(data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWithDialect(data, detected.Dialect), nil
+}
+
+// ParseWithConfig splits archive content using an explicit marker configuration.
+func ParseWithConfig(data []byte, config metadata.MarkerConfig) (*Archive, error) {
+	d, err := metadata.DialectFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWithDialect(data, d), nil
+}
+
+// ParseWithDialect splits archive content into entries of dialect d.
+func ParseWithDialect(data []byte, d metadata.Dialect) *Archive {
+	a := &Archive{Dialect: d}
+
+	// Frontmatter is never scanned for markers
+	content := string(data)
+	if meta, rest, err := metadata.ParseFrontmatter(data); err == nil && meta != nil {
+		a.Preamble = content[:len(content)-len(rest)]
+		content = string(rest)
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimRight(line, "\r\n")
+	}
+
+	var current *Entry
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if name, ok := a.matchMarker(line); ok {
+			if current != nil {
+				a.Entries = append(a.Entries, *current)
+			}
+			current = &Entry{Name: name, Raw: line, dialect: d}
+			// Lines in the block it opens are content whatever they look like
+			block := metadata.BlockLines(d, trimmed[i+1:])
+			current.Raw += strings.Join(lines[i+1:i+1+block], "")
+			i += block
+			continue
+		}
+		if current == nil {
+			a.Preamble += line
+		} else {
+			current.Raw += line
+		}
+	}
+	if current != nil {
+		a.Entries = append(a.Entries, *current)
+	}
+	return a
+}
+
+// matchMarker reports whether line is a marker line and returns its filename.
+func (a *Archive) matchMarker(line string) (string, bool) {
+	name, ok := a.Dialect.ParseLine(strings.TrimRight(line, "\r\n"))
+	return name, ok && name != ""
+}
+
+// Bytes renders the archive. Untouched entries are emitted byte for byte.
+func (a *Archive) Bytes() []byte {
+	var b strings.Builder
+	b.WriteString(a.Preamble)
+	for _, e := range a.Entries {
+		b.WriteString(e.Raw)
+	}
+	return []byte(b.String())
+}
+
+// NewEntry formats a new entry in the archive's dialect, as the generators do:
+// marker line, raw content, and a final newline if content lacks one.
+func (a *Archive) NewEntry(name, content string) Entry {
+	return a.newEntryAt(name, content, 0)
+}
+
+// newEntryAt is NewEntry for the file at 1-based position index among the
+// files, which callers adding many entries know; 0 looks it up.
+func (a *Archive) newEntryAt(name, content string, index int) Entry {
+	raw := a.markerAttrs(name, content, nil, index) + "\n" + metadata.WrapContent(a.Dialect, name, content)
+	return Entry{Name: name, Raw: raw, dialect: a.Dialect}
+}
+
+// marker formats the marker line of an entry, filling attribute placeholders
+// from its content and its position among the files.
+func (a *Archive) marker(name, content string) string {
+	return a.markerAttrs(name, content, nil, 0)
+}
+
+// markerAttrs is marker keeping a known file mode from attrs, which the
+// content cannot tell, for the file at position index (0 to look it up).
+// Attributes are computed only when the dialect's marker uses them.
+func (a *Archive) markerAttrs(name, content string, known map[string]string, index int) string {
+	if name == parser.ProjectInfoMarker || !metadata.UsesAttributes(a.Dialect, name) {
+		return metadata.FormatMarker(a.Dialect, name, nil)
+	}
+	if index == 0 && metadata.UsesPlaceholder(a.Dialect, name, metadata.IndexPlaceholder) {
+		index = a.fileIndex(name)
+	}
+	attrs := parser.MarkerAttrs(name, content, 0, index)
+	if mode := known[metadata.ModePlaceholder]; mode != "" {
+		attrs[metadata.ModePlaceholder] = mode
+	}
+	return metadata.FormatMarker(a.Dialect, name, attrs)
+}
+
+// fileIndex returns the 1-based position of name among the files, or the
+// position it takes when appended.
+func (a *Archive) fileIndex(name string) int {
+	index := 1
+	for _, e := range a.Entries {
+		if e.Name == name {
+			return index
+		}
+		if e.Name != parser.ProjectInfoMarker {
+			index++
+		}
+	}
+	return index
+}
+
+// Index returns the position of the entry named name, or -1.
+func (a *Archive) Index(name string) int {
+	for i, e := range a.Entries {
+		if e.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Positions maps the name of every entry to its position, for lookups in
+// loops where Index would be quadratic. It is not updated when Entries change.
+func (a *Archive) Positions() map[string]int {
+	positions := make(map[string]int, len(a.Entries))
+	for i, e := range a.Entries {
+		if _, ok := positions[e.Name]; !ok {
+			positions[e.Name] = i
+		}
+	}
+	return positions
+}
+
+// Files returns the entries that hold files, skipping PROJECT_INFO.
+func (a *Archive) Files() []Entry {
+	files := make([]Entry, 0, len(a.Entries))
+	for _, e := range a.Entries {
+		if e.Name != parser.ProjectInfoMarker {
+			files = append(files, e)
+		}
+	}
+	return files
+}
+
+// Info returns the parsed PROJECT_INFO section, or an empty ProjectInfo.
+func (a *Archive) Info() parser.ProjectInfo {
+	if i := a.Index(parser.ProjectInfoMarker); i >= 0 {
+		return parser.ParseProjectInfo(a.Entries[i].Content())
+	}
+	return parser.ProjectInfo{}
+}
+
+// RefreshInfo rewrites the PROJECT_INFO section, if present, to match the current
+// entries: generation time, file count and, when the archive records them, base
+// hashes are recomputed, per-file lines for entries that no longer exist are
+// dropped and every other line is kept in place.
+func (a *Archive) RefreshInfo() {
+	a.refreshInfo(false)
+}
+
+// RecordBaseHashes refreshes the PROJECT_INFO section like RefreshInfo and
+// starts recording the base hash of every file in it.
+func (a *Archive) RecordBaseHashes() {
+	a.refreshInfo(true)
+}
+
+func (a *Archive) refreshInfo(record bool) {
+	i := a.Index(parser.ProjectInfoMarker)
+	if i < 0 {
+		return
+	}
+
+	files := a.Files()
+	names := make(map[string]bool, len(files))
+	for _, e := range files {
+		names[filepath.ToSlash(e.Name)] = true
+	}
+
+	markerLine, _, _ := strings.Cut(a.Entries[i].Raw, "\n")
+	var b strings.Builder
+	for _, line := range strings.Split(a.Entries[i].Content(), "\n") {
+		key, _, _ := strings.Cut(line, ":")
+		switch strings.TrimSpace(key) {
+		case "Generated":
+			line = "Generated: " + time.Now().UTC().Format(time.RFC3339)
+		case "Total Files":
+			line = fmt.Sprintf("Total Files: %d", len(files))
+		case parser.BaseHashKey:
+			record = true
+			continue
+		case parser.ModeKey, parser.ModTimeKey:
+			if _, path, _ := strings.Cut(strings.TrimSpace(line[len(key)+1:]), " "); !names[path] {
+				continue
+			}
+		case parser.ExcerptKey:
+			if !names[strings.TrimSpace(line[len(key)+1:])] {
+				continue
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	if record {
+		hashes := make(map[string]string, len(files))
+		for _, e := range files {
+			hashes[filepath.ToSlash(e.Name)] = parser.ContentHash(e.Content())
+		}
+		b.WriteString(parser.FormatBaseHashes(hashes) + "\n")
+	}
+	a.Entries[i].Raw = markerLine + "\n" + metadata.WrapContent(a.Dialect, parser.ProjectInfoMarker, b.String())
+}
+
+// SetProjectInfo replaces or inserts the PROJECT_INFO section with the given
+// "Key: value" lines and refreshes its generated fields.
+func (a *Archive) SetProjectInfo(lines string) {
+	entry := a.NewEntry(parser.ProjectInfoMarker, lines)
+	if i := a.Index(parser.ProjectInfoMarker); i >= 0 {
+		a.Entries[i] = entry
+	} else {
+		a.Entries = append([]Entry{entry}, a.Entries...)
+	}
+	a.RefreshInfo()
+}
+
+// WriteFile writes the archive atomically, re-armoring it if it was read armored.
+func (a *Archive) WriteFile(path string) error {
+	data := a.Bytes()
+	if a.Armored {
+		data = parser.Armor(data)
+	}
+	return WriteFileAtomic(path, data)
+}
+
+// WriteFileAtomic replaces path with data via a temporary file and rename.
+func WriteFileAtomic(path string, data []byte) error {
+	return WriteAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteAtomic replaces path with what write writes, via a temporary file and
+// rename, so that archives too large to hold in memory can be streamed.
+func WriteAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), tempPrefix(path)+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// tempPrefix starts the names of the temporary files that WriteFileAtomic
+// writes next to path.
+func tempPrefix(path string) string {
+	return "." + filepath.Base(path) + ".tmp-"
+}
+
+// IsTempFile reports whether name is a temporary file WriteFileAtomic creates
+// while replacing path, so that watchers can ignore it like path itself.
+func IsTempFile(name, path string) bool {
+	return filepath.Dir(name) == filepath.Dir(path) && strings.HasPrefix(filepath.Base(name), tempPrefix(path))
+}
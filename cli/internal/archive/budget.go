@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/ignore"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
+)
+
+// entryPointNames are base names, without extension, of files that usually
+// explain a project on their own.
+var entryPointNames = map[string]bool{
+	"main": true, "index": true, "app": true, "server": true, "cli": true,
+	"__main__": true, "__init__": true, "lib": true, "mod": true, "readme": true,
+}
+
+// manifestNames are build and package manifests, also treated as entry points.
+var manifestNames = map[string]bool{
+	"go.mod": true, "package.json": true, "cargo.toml": true, "pyproject.toml": true,
+	"setup.py": true, "pom.xml": true, "build.gradle": true, "makefile": true,
+	"dockerfile": true, "gemfile": true, "composer.json": true,
+}
+
+// IsEntryPoint reports whether path looks like a program entry point or manifest.
+func IsEntryPoint(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	if manifestNames[base] {
+		return true
+	}
+	return entryPointNames[strings.TrimSuffix(base, filepath.Ext(base))]
+}
+
+// BudgetOptions configures ApplyBudget.
+type BudgetOptions struct {
+	// MaxTokens is the budget for the whole archive; 0 only counts tokens.
+	MaxTokens int
+	// Priority globs (gitignore syntax) rank matching files first, in order.
+	Priority []string
+	// Tokenizer counts tokens; nil selects tokens.DefaultTokenizer.
+	Tokenizer tokens.Tokenizer
+}
+
+// BudgetResults reports token usage after ApplyBudget.
+type BudgetResults struct {
+	Tokenizer   string         `json:"tokenizer"`
+	TotalTokens int            `json:"totalTokens"`
+	TotalFiles  int            `json:"totalFiles"`
+	TotalBytes  int64          `json:"totalBytes"`
+	FileTokens  map[string]int `json:"fileTokens"`
+	Dropped     []string       `json:"dropped"`
+}
+
+// ApplyBudget drops entries from the archive at path until it fits MaxTokens.
+// Files are admitted in priority order: files matching a Priority glob (earlier
+// globs first), then entry points, then everything else, smaller files first
+// within each tier. A file that does not fit is skipped and smaller ones are
+// still tried. The archive is rewritten only when something was dropped.
+func ApplyBudget(path string, opts BudgetOptions) (*BudgetResults, error) {
+	tk := opts.Tokenizer
+	if tk == nil {
+		tk, _ = tokens.Get("")
+	}
+	a, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &BudgetResults{Tokenizer: tk.Name(), FileTokens: map[string]int{}, Dropped: []string{}}
+
+	files := a.Files()
+	cost := make(map[string]int, len(files))
+	for _, e := range files {
+		cost[e.Name] = tk.Count(e.Raw)
+	}
+
+	if opts.MaxTokens > 0 {
+		matchers := make([]*ignore.Matcher, len(opts.Priority))
+		for i, glob := range opts.Priority {
+			matchers[i] = &ignore.Matcher{}
+			matchers[i].AddPatterns("", []string{glob})
+		}
+		tier := func(name string) int {
+			for i, m := range matchers {
+				if m.Match(name, false) {
+					return i
+				}
+			}
+			if IsEntryPoint(name) {
+				return len(matchers)
+			}
+			return len(matchers) + 1
+		}
+
+		ranked := append([]Entry(nil), files...)
+		sort.SliceStable(ranked, func(i, j int) bool {
+			ti, tj := tier(ranked[i].Name), tier(ranked[j].Name)
+			if ti != tj {
+				return ti < tj
+			}
+			return cost[ranked[i].Name] < cost[ranked[j].Name]
+		})
+
+		// The preamble and PROJECT_INFO are charged up front, except for base
+		// hash lines, which are charged with the file they describe
+		used := tk.Count(a.Preamble)
+		if i := a.Index(parser.ProjectInfoMarker); i >= 0 {
+			for _, line := range strings.SplitAfter(a.Entries[i].Raw, "\n") {
+				if !strings.HasPrefix(line, parser.BaseHashKey+":") {
+					used += tk.Count(line)
+				}
+			}
+		}
+		records := len(a.Info().BaseHashes()) > 0
+		keep := map[string]bool{}
+		for _, e := range ranked {
+			hashLine := 0
+			if records {
+				hashLine = tk.Count(parser.FormatBaseHashes(map[string]string{filepath.ToSlash(e.Name): parser.ContentHash(e.Content())}))
+			}
+			if used+cost[e.Name]+hashLine <= opts.MaxTokens {
+				used += cost[e.Name] + hashLine
+				keep[e.Name] = true
+			}
+		}
+
+		kept := a.Entries[:0]
+		for _, e := range a.Entries {
+			if e.Name != parser.ProjectInfoMarker && !keep[e.Name] {
+				result.Dropped = append(result.Dropped, e.Name)
+				continue
+			}
+			kept = append(kept, e)
+		}
+		a.Entries = kept
+
+		if len(result.Dropped) > 0 {
+			a.RefreshInfo()
+			if err := a.WriteFile(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, e := range a.Files() {
+		result.FileTokens[e.Name] = cost[e.Name]
+		result.TotalFiles++
+	}
+	data := a.Bytes()
+	result.TotalBytes = int64(len(data))
+	result.TotalTokens = tk.Count(string(data))
+	return result, nil
+}
@@ -0,0 +1,245 @@
+package archive
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// ConflictPolicy decides what Combine does when two inputs hold the same path
+// with different content.
+type ConflictPolicy string
+
+// Conflict policies accepted by Combine.
+const (
+	FirstWins      ConflictPolicy = "first-wins"
+	LastWins       ConflictPolicy = "last-wins"
+	FailOnConflict ConflictPolicy = "fail"
+	KeepBoth       ConflictPolicy = "keep-both-renamed"
+)
+
+// ConflictPolicies lists the valid policies in documentation order.
+var ConflictPolicies = []ConflictPolicy{FirstWins, LastWins, FailOnConflict, KeepBoth}
+
+// ParseConflictPolicy validates a policy name; "" selects FailOnConflict.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	if name == "" {
+		return FailOnConflict, nil
+	}
+	for _, p := range ConflictPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	names := make([]string, len(ConflictPolicies))
+	for i, p := range ConflictPolicies {
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// infoKeysRecomputed are PROJECT_INFO keys that describe one archive and are
+// rebuilt for the combined archive rather than copied from the inputs.
+var infoKeysRecomputed = map[string]bool{
+	"Generated": true, "Total Files": true, "Generator": true, parser.BaseHashKey: true, ChunksKey: true, PartKey: true,
+}
+
+// entryInfoKeys are PROJECT_INFO keys describing one entry, in "Key: value
+// path" lines or, when the value is false, "Key: path" lines. Combine carries
+// them with the entry they describe, under its new name.
+var entryInfoKeys = map[string]bool{parser.ModeKey: true, parser.ModTimeKey: true, parser.ExcerptKey: false}
+
+// MergedFromKey records each input of a combined archive in its PROJECT_INFO.
+const MergedFromKey = "Merged-From"
+
+// CombineInput is one archive to combine, with an optional path prefix.
+type CombineInput struct {
+	Path   string
+	Prefix string
+}
+
+// CombineResults summarizes a Combine run.
+type CombineResults struct {
+	TotalFiles int `json:"totalFiles"`
+	// Conflicts lists paths present in several inputs with different content.
+	Conflicts []string `json:"conflicts"`
+	// Renamed maps original paths to the names given under KeepBoth.
+	Renamed map[string]string `json:"renamed"`
+}
+
+// Combine merges several archives into output. The first input decides the
+// dialect and frontmatter; entries from the others are re-marked to match. Paths
+// identical in content across inputs are kept once; real conflicts follow
+// policy. PROJECT_INFO lines from every input are combined without duplicates,
+// with the paths they name placed under the input's prefix. Changeset archives
+// combine only with each other, since a changeset among full archives would
+// make the whole result one.
+func Combine(inputs []CombineInput, output string, policy ConflictPolicy) (*CombineResults, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input archives")
+	}
+	result := &CombineResults{Conflicts: []string{}, Renamed: map[string]string{}}
+
+	var out *Archive
+	var infoLines, deleted []string
+	entryInfo := map[string][]string{}
+	changesets, records := 0, false
+	seenInfo := map[string]bool{}
+	addInfo := func(line string) {
+		if !seenInfo[line] {
+			seenInfo[line] = true
+			infoLines = append(infoLines, line)
+		}
+	}
+	var conflicts []string
+
+	for n, input := range inputs {
+		a, err := Load(input.Path)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = &Archive{Dialect: a.Dialect, Preamble: a.Preamble, Armored: a.Armored}
+		}
+		addInfo(fmt.Sprintf("%s: %s", MergedFromKey, formatInput(input)))
+		prefixed := func(name string) string {
+			if input.Prefix == "" {
+				return name
+			}
+			return path.Join(filepath.ToSlash(input.Prefix), filepath.ToSlash(name))
+		}
+
+		// Lines about one entry wait for it to be placed
+		own := map[string][]func(string) string{}
+		info := a.Info()
+		if len(info[parser.ChangesetKey]) > 0 {
+			changesets++
+		}
+		records = records || len(info[parser.BaseHashKey]) > 0
+		if i := a.Index(parser.ProjectInfoMarker); i >= 0 {
+			for _, line := range strings.Split(a.Entries[i].Content(), "\n") {
+				key, value, _ := strings.Cut(line, ":")
+				key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+				withValue, perEntry := entryInfoKeys[key]
+				switch {
+				case strings.TrimSpace(line) == "" || infoKeysRecomputed[key]:
+				case key == parser.DeletedKey:
+					deleted = append(deleted, fmt.Sprintf("%s: %s", key, prefixed(value)))
+				case perEntry && withValue:
+					v, name, _ := strings.Cut(value, " ")
+					own[name] = append(own[name], func(to string) string { return fmt.Sprintf("%s: %s %s", key, v, to) })
+				case perEntry:
+					own[value] = append(own[value], func(to string) string { return fmt.Sprintf("%s: %s", key, to) })
+				default:
+					addInfo(line)
+				}
+			}
+		}
+		place := func(e Entry, name string) {
+			entryInfo[name] = entryInfo[name][:0]
+			for _, format := range own[filepath.ToSlash(e.Name)] {
+				entryInfo[name] = append(entryInfo[name], format(name))
+			}
+		}
+
+		// out holds only files until PROJECT_INFO is added, so entry
+		// positions are file positions
+		positions := out.Positions()
+		appendAs := func(e Entry, name string) {
+			positions[name] = len(out.Entries)
+			out.Entries = append(out.Entries, out.renameAt(e, name, len(out.Entries)+1))
+			place(e, name)
+		}
+		for _, e := range a.Files() {
+			name := prefixed(e.Name)
+			i, found := positions[name]
+			if !found {
+				appendAs(e, name)
+				continue
+			}
+			if parser.ContentHash(out.Entries[i].Content()) == parser.ContentHash(e.Content()) {
+				continue
+			}
+			conflicts = append(conflicts, name)
+			switch policy {
+			case LastWins:
+				out.Entries[i] = out.renameAt(e, name, i+1)
+				place(e, name)
+			case KeepBoth:
+				renamed := out.uniqueName(name, n+1)
+				appendAs(e, renamed)
+				result.Renamed[name] = renamed
+			}
+		}
+	}
+	if changesets > 0 && changesets < len(inputs) {
+		return nil, fmt.Errorf("changeset archives cannot be combined with full archives")
+	}
+
+	sort.Strings(conflicts)
+	conflicts = slices.Compact(conflicts)
+	result.Conflicts = append(result.Conflicts, conflicts...)
+	if policy == FailOnConflict && len(conflicts) > 0 {
+		return result, fmt.Errorf("%d conflicting paths: %s", len(conflicts), strings.Join(conflicts, ", "))
+	}
+
+	for _, line := range deleted {
+		addInfo(line)
+	}
+	for _, e := range out.Files() {
+		for _, line := range entryInfo[e.Name] {
+			addInfo(line)
+		}
+	}
+	info := parser.ProjectInfoHeader("", "", 0)
+	info += strings.Join(infoLines, "\n") + "\n"
+	out.Entries = append([]Entry{out.NewEntry(parser.ProjectInfoMarker, info)}, out.Entries...)
+	if records {
+		out.RecordBaseHashes()
+	} else {
+		out.RefreshInfo()
+	}
+
+	result.TotalFiles = len(out.Files())
+	if err := out.WriteFile(output); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Rename returns e marked as name in a's dialect, with its content untouched.
+func (a *Archive) Rename(e Entry, name string) Entry {
+	return a.renameAt(e, name, 0)
+}
+
+// renameAt is Rename for the file at 1-based position index, 0 to look it up.
+func (a *Archive) renameAt(e Entry, name string, index int) Entry {
+	raw := a.markerAttrs(name, e.Content(), nil, index) + "\n" + metadata.WrapContent(a.Dialect, name, e.Body())
+	return Entry{Name: name, Raw: raw, dialect: a.Dialect}
+}
+
+// uniqueName derives a free entry name from name by adding ~n before its extension.
+func (a *Archive) uniqueName(name string, n int) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for {
+		candidate := fmt.Sprintf("%s~%d%s", stem, n, ext)
+		if a.Index(candidate) < 0 {
+			return candidate
+		}
+		n++
+	}
+}
+
+func formatInput(input CombineInput) string {
+	if input.Prefix == "" {
+		return input.Path
+	}
+	return fmt.Sprintf("%s prefix=%s", input.Path, input.Prefix)
+}
@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// Collision is a content line that the target dialect would read as a marker.
+type Collision struct {
+	Entry string `json:"entry"`
+	Line  int    `json:"line"`
+	Text  string `json:"text"`
+}
+
+// CollisionError reports why an archive cannot be converted.
+type CollisionError struct {
+	Collisions []Collision
+}
+
+func (e *CollisionError) Error() string {
+	c := e.Collisions[0]
+	msg := fmt.Sprintf("content collides with the target marker syntax at %s:%d: %q", c.Entry, c.Line, c.Text)
+	if len(e.Collisions) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Collisions)-1)
+	}
+	return msg
+}
+
+// Convert re-emits the archive in the target dialect: marker lines are rewritten,
+// content is kept byte for byte and the frontmatter is replaced, or dropped when
+// converting to the default dialect or an undeclared one. It fails with a *CollisionError when any
+// content line would parse as a marker in the target dialect.
+func (a *Archive) Convert(target metadata.MarkerConfig) (*Archive, error) {
+	// Keep any text between the frontmatter and the first marker
+	_, rest, err := metadata.ParseFrontmatter([]byte(a.Preamble))
+	if err != nil {
+		return nil, err
+	}
+	out, err := New(target)
+	if err != nil {
+		return nil, err
+	}
+	out.Preamble += string(rest)
+	out.Armored = a.Armored
+
+	files := 0
+	for _, e := range a.Entries {
+		if _, err := out.Dialect.Escape(e.Name); err != nil {
+			return nil, err
+		}
+		if e.Name != parser.ProjectInfoMarker {
+			files++
+		}
+		out.Entries = append(out.Entries, out.renameAt(e, e.Name, files))
+	}
+	return out, out.checkCollisions()
+}
+
+// FromMarkers builds an archive in the target dialect holding parsed files in
+// order, PROJECT_INFO included, as when reading an XML or JSON document. It
+// fails like Convert when content would parse as a marker.
+func FromMarkers(target metadata.MarkerConfig, markers []parser.ParsedMarker) (*Archive, error) {
+	out, err := New(target)
+	if err != nil {
+		return nil, err
+	}
+	files := 0
+	for _, m := range markers {
+		if _, err := out.Dialect.Escape(m.Filename); err != nil {
+			return nil, err
+		}
+		if m.Filename != parser.ProjectInfoMarker {
+			files++
+		}
+		raw := out.markerAttrs(m.Filename, m.Content, m.Attrs, files) + "\n" + metadata.WrapContent(out.Dialect, m.Filename, m.Content)
+		out.Entries = append(out.Entries, Entry{Name: m.Filename, Raw: raw, dialect: out.Dialect})
+	}
+	return out, out.checkCollisions()
+}
+
+// checkCollisions returns a *CollisionError listing the content lines that
+// read as markers. Content in the blocks of block dialects cannot collide.
+func (a *Archive) checkCollisions() error {
+	if _, block := a.Dialect.(metadata.BlockDialect); block {
+		return nil
+	}
+	var collisions []Collision
+	for _, e := range a.Entries {
+		for i, line := range strings.Split(e.Body(), "\n") {
+			if _, ok := a.matchMarker(line); ok {
+				collisions = append(collisions, Collision{Entry: e.Name, Line: i + 1, Text: strings.TrimRight(line, "\r")})
+			}
+		}
+	}
+	if len(collisions) > 0 {
+		return &CollisionError{Collisions: collisions}
+	}
+	return nil
+}
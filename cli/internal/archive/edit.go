@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// EntryName normalizes a path into the slash-separated form used for entries.
+func EntryName(name string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "./")
+}
+
+// ValidateName rejects entry names that cannot be extracted safely: empty
+// names, PROJECT_INFO, absolute names and names with ".." components.
+func ValidateName(name string) error {
+	if name == "" || name == "." || name == parser.ProjectInfoMarker {
+		return fmt.Errorf("invalid entry name %q", name)
+	}
+	return parser.CheckName(name)
+}
+
+// Set stores content under name, replacing an existing entry in place or
+// appending a new one. It reports whether the entry was added.
+func (a *Archive) Set(name, content string) (added bool, err error) {
+	name = EntryName(name)
+	if err := ValidateName(name); err != nil {
+		return false, err
+	}
+	entry := a.NewEntry(name, content)
+	if i := a.Index(name); i >= 0 {
+		a.Entries[i] = entry
+		return false, nil
+	}
+	a.Entries = append(a.Entries, entry)
+	return true, nil
+}
+
+// Remove deletes the entry name, or every entry below it when name is a
+// directory. It returns the removed entry names.
+func (a *Archive) Remove(name string) []string {
+	name = EntryName(name)
+	var removed []string
+	kept := a.Entries[:0]
+	for _, e := range a.Entries {
+		if e.Name != parser.ProjectInfoMarker && (e.Name == name || strings.HasPrefix(e.Name, name+"/")) {
+			removed = append(removed, e.Name)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	a.Entries = kept
+	return removed
+}
+
+// Move renames the entry oldName to newName in place. When oldName is not an
+// entry but a directory of entries, every entry below it is moved. It returns
+// the moved entries as old -> new pairs.
+func (a *Archive) Move(oldName, newName string) (map[string]string, error) {
+	oldName, newName = EntryName(oldName), EntryName(newName)
+	if err := ValidateName(newName); err != nil {
+		return nil, err
+	}
+
+	moves := map[string]string{}
+	if a.Index(oldName) >= 0 {
+		moves[oldName] = newName
+	} else {
+		for _, e := range a.Files() {
+			if rest, ok := strings.CutPrefix(e.Name, oldName+"/"); ok {
+				moves[e.Name] = newName + "/" + rest
+			}
+		}
+	}
+	if len(moves) == 0 {
+		return nil, fmt.Errorf("no entry named %s", oldName)
+	}
+	for _, target := range moves {
+		if _, moving := moves[target]; !moving && a.Index(target) >= 0 {
+			return nil, fmt.Errorf("entry already exists: %s", target)
+		}
+	}
+
+	for i, e := range a.Entries {
+		if target, ok := moves[e.Name]; ok {
+			a.Entries[i] = a.Rename(e, target)
+		}
+	}
+	return moves, nil
+}
@@ -0,0 +1,131 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// ErrArmored is returned by NewScanner for armored archives, whose checksum
+// covers the whole archive; they have to be loaded.
+var ErrArmored = errors.New("armored archives cannot be scanned")
+
+// sniffWindow is how much of an archive NewScanner reads ahead to find its
+// frontmatter and dialect.
+const sniffWindow = 1 << 20
+
+// Scanner reads the entries of an archive one at a time, splitting them as
+// ParseWithDialect does while holding a single entry in memory.
+type Scanner struct {
+	// Dialect is read from frontmatter or sniffed from the start of the archive.
+	Dialect metadata.Dialect
+	// Preamble holds everything before the first marker, frontmatter included.
+	// It is complete once Scan has returned the first entry.
+	Preamble string
+
+	r     *bufio.Reader
+	entry Entry
+	// next is the entry being read, opened by a marker line
+	next  *strings.Builder
+	name  string
+	block []string
+	err   error
+	eof   bool
+}
+
+// NewScanner prepares to read the entries of the archive in r.
+func NewScanner(r io.Reader) (*Scanner, error) {
+	br := bufio.NewReaderSize(r, sniffWindow)
+	head, err := br.Peek(sniffWindow)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if parser.IsArmored(head) {
+		return nil, ErrArmored
+	}
+	detected, err := metadata.Detect(head)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scanner{Dialect: detected.Dialect, r: br}
+
+	// Frontmatter is never scanned for markers
+	if meta, rest, err := metadata.ParseFrontmatter(head); err == nil && meta != nil {
+		s.Preamble = string(head[:len(head)-len(rest)])
+		br.Discard(len(s.Preamble))
+	}
+	return s, nil
+}
+
+// Scan advances to the next entry, which Entry then returns. It returns false
+// at the end of the archive or on a read error, reported by Err.
+func (s *Scanner) Scan() bool {
+	for !s.eof && s.err == nil {
+		line, err := s.r.ReadString('\n')
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			s.err = fmt.Errorf("failed to read archive: %w", err)
+			return false
+		}
+		if line == "" {
+			break
+		}
+		if s.opens(line) {
+			done := s.next != nil
+			if done {
+				s.entry = Entry{Name: s.name, Raw: s.next.String(), dialect: s.Dialect}
+			}
+			s.name, _ = s.Dialect.ParseLine(strings.TrimRight(line, "\r\n"))
+			s.next, s.block = &strings.Builder{}, s.block[:0]
+			s.next.WriteString(line)
+			if done {
+				return true
+			}
+			continue
+		}
+		if s.next == nil {
+			s.Preamble += line
+			continue
+		}
+		s.next.WriteString(line)
+		if _, ok := s.Dialect.(metadata.BlockDialect); ok {
+			s.block = append(s.block, strings.TrimRight(line, "\r\n"))
+		}
+	}
+	if s.next == nil {
+		return false
+	}
+	s.entry = Entry{Name: s.name, Raw: s.next.String(), dialect: s.Dialect}
+	s.next = nil
+	return true
+}
+
+// opens reports whether line is a marker line starting a new entry rather
+// than content in the block of the entry being read.
+func (s *Scanner) opens(line string) bool {
+	name, ok := s.Dialect.ParseLine(strings.TrimRight(line, "\r\n"))
+	if !ok || name == "" {
+		return false
+	}
+	if s.next == nil || len(s.block) == 0 {
+		return true
+	}
+	lines := append(s.block, strings.TrimRight(line, "\r\n"))
+	return metadata.BlockLines(s.Dialect, lines) <= len(s.block)
+}
+
+// Entry returns the entry read by the last call to Scan.
+func (s *Scanner) Entry() Entry {
+	return s.entry
+}
+
+// Err returns the first read error met by Scan.
+func (s *Scanner) Err() error {
+	return s.err
+}
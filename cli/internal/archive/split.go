@@ -0,0 +1,334 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
+)
+
+// ManifestIndex is the chunk number of the manifest in a chunk set.
+const ManifestIndex = 0
+
+// Manifest keys written into the PROJECT_INFO section of the manifest chunk.
+const (
+	ChunksKey = "Chunks"
+	PartKey   = "Part"
+)
+
+// chunkNameRegex matches "name.NNN.ext" chunk paths.
+var chunkNameRegex = regexp.MustCompile(`^(.*)\.(\d{3})(\.[^./\\]+)?$`)
+
+// SplitLimits bounds the size of every chunk. A zero field is not enforced.
+type SplitLimits struct {
+	MaxTokens int
+	MaxBytes  int64
+	// Tokenizer counts tokens for MaxTokens; nil selects tokens.DefaultTokenizer.
+	Tokenizer tokens.Tokenizer
+}
+
+// Enabled reports whether any limit is set.
+func (l SplitLimits) Enabled() bool {
+	return l.MaxTokens > 0 || l.MaxBytes > 0
+}
+
+// measure is the size of a text: its bytes and, when a token limit is set, its
+// tokens. Sizes of consecutive texts add up, so growing chunks and parts are
+// never measured again.
+type measure struct {
+	bytes  int64
+	tokens int
+}
+
+func (m measure) add(o measure) measure {
+	return measure{bytes: m.bytes + o.bytes, tokens: m.tokens + o.tokens}
+}
+
+// measure returns the size of text, counting tokens only for a token limit.
+func (l SplitLimits) measure(text string) measure {
+	m := measure{bytes: int64(len(text))}
+	if l.MaxTokens > 0 {
+		m.tokens = l.count(text)
+	}
+	return m
+}
+
+// within reports whether a size stays within the limits.
+func (l SplitLimits) within(m measure) bool {
+	return (l.MaxBytes <= 0 || m.bytes <= l.MaxBytes) && (l.MaxTokens <= 0 || m.tokens <= l.MaxTokens)
+}
+
+func (l SplitLimits) count(text string) int {
+	if l.Tokenizer == nil {
+		return tokens.Count(text)
+	}
+	return l.Tokenizer.Count(text)
+}
+
+// SplitResults describes the chunk set written by Split.
+type SplitResults struct {
+	Manifest string   `json:"manifest"`
+	Chunks   []string `json:"chunks"`
+	// SplitFiles lists files too large for one chunk, stored as continuation parts.
+	SplitFiles []string `json:"splitFiles"`
+	// Oversized lists files with a single line that exceeds the limits on its own.
+	Oversized []string `json:"oversized"`
+}
+
+// ChunkPath returns the path of chunk index for an archive written to path,
+// e.g. out.lkt becomes out.001.lkt.
+func ChunkPath(path string, index int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(path, ext), index, ext)
+}
+
+// Split rewrites the archive at path as a chunk set: a manifest (chunk 000) holding
+// the project info and a map of which file lives in which chunk, followed by
+// chunks 001, 002, ... that each stay within limits. Files are never cut unless a
+// single file exceeds the limits, in which case it is stored as consecutive
+// markers of the same name, each holding a part; the manifest numbers the parts
+// and JoinChunks reassembles them. The original archive is removed once every
+// chunk has been written.
+func Split(path string, limits SplitLimits) (*SplitResults, error) {
+	if !limits.Enabled() {
+		return nil, fmt.Errorf("no chunk limit set")
+	}
+	a, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &SplitResults{Chunks: []string{}, SplitFiles: []string{}, Oversized: []string{}}
+
+	// Cut every file into pieces that fit a chunk next to the preamble
+	type piece struct {
+		Entry
+		size         measure
+		index, total int
+	}
+	preamble := limits.measure(a.Preamble)
+	var pieces []piece
+	for _, e := range a.Files() {
+		if size := limits.measure(e.Raw); limits.within(preamble.add(size)) {
+			pieces = append(pieces, piece{e, size, 1, 1})
+			continue
+		}
+		parts, oversized := a.splitEntry(e, limits)
+		if len(parts) > 1 {
+			result.SplitFiles = append(result.SplitFiles, e.Name)
+		}
+		if oversized {
+			result.Oversized = append(result.Oversized, e.Name)
+		}
+		for i, p := range parts {
+			pieces = append(pieces, piece{p, limits.measure(p.Raw), i + 1, len(parts)})
+		}
+	}
+
+	// Pack pieces into chunks in archive order
+	var chunks [][]piece
+	size := preamble
+	for _, p := range pieces {
+		if len(chunks) == 0 || !limits.within(size.add(p.size)) {
+			chunks = append(chunks, nil)
+			size = preamble
+		}
+		chunks[len(chunks)-1] = append(chunks[len(chunks)-1], p)
+		size = size.add(p.size)
+	}
+
+	var manifest strings.Builder
+	fmt.Fprintf(&manifest, "%s: %d\n", ChunksKey, len(chunks))
+	for i, chunk := range chunks {
+		chunkPath := ChunkPath(path, i+1)
+		c := &Archive{Dialect: a.Dialect, Preamble: a.Preamble, Armored: a.Armored}
+		for _, p := range chunk {
+			c.Entries = append(c.Entries, p.Entry)
+			fmt.Fprintf(&manifest, "%s: %s %s\n", PartKey, formatPart(filepath.Base(chunkPath), p.index, p.total), p.Name)
+		}
+		if err := c.WriteFile(chunkPath); err != nil {
+			return nil, err
+		}
+		result.Chunks = append(result.Chunks, chunkPath)
+	}
+
+	m := &Archive{Dialect: a.Dialect, Preamble: a.Preamble, Armored: a.Armored}
+	if i := a.Index(parser.ProjectInfoMarker); i >= 0 {
+		m.Entries = append(m.Entries, m.NewEntry(parser.ProjectInfoMarker, a.Entries[i].Content()+"\n"+manifest.String()))
+	} else {
+		m.Entries = append(m.Entries, m.NewEntry(parser.ProjectInfoMarker, manifest.String()))
+	}
+	result.Manifest = ChunkPath(path, ManifestIndex)
+	if err := m.WriteFile(result.Manifest); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// formatPart renders the value of a manifest Part line: the chunk holding an
+// entry, followed by "#index/total" when the entry is a part of a split file.
+func formatPart(chunk string, index, total int) string {
+	if total <= 1 {
+		return chunk
+	}
+	return fmt.Sprintf("%s#%d/%d", chunk, index, total)
+}
+
+// parsePart reads the part number and count of a manifest Part line, which are
+// 1 and 1 for a whole file.
+func parsePart(line string) (index, total int) {
+	value, _, _ := strings.Cut(line, " ")
+	m := partRegex.FindStringSubmatch(value)
+	if m == nil {
+		return 1, 1
+	}
+	index, _ = strconv.Atoi(m[1])
+	total, _ = strconv.Atoi(m[2])
+	return index, total
+}
+
+// partRegex matches the "#index/total" suffix of a split file's chunk.
+var partRegex = regexp.MustCompile(`#(\d+)/(\d+)$`)
+
+// splitEntry cuts an entry into parts on line boundaries, each marked with the
+// entry's name. A part never ends on a blank line when that can be avoided,
+// since parsers trim trailing newlines. oversized is set when a single line
+// exceeds the limits.
+func (a *Archive) splitEntry(e Entry, limits SplitLimits) (parts []Entry, oversized bool) {
+	// Reserve room for the preamble and the marker of every part
+	reserve := limits.measure(a.Preamble + a.marker(e.Name, e.Content()) + "\n")
+	lines := strings.SplitAfter(e.Content()+"\n", "\n")
+	lines = lines[:len(lines)-1]
+
+	var bodies []string
+	for start := 0; start < len(lines); {
+		end := start
+		size := reserve
+		for end < len(lines) {
+			next := size.add(limits.measure(lines[end]))
+			if !limits.within(next) {
+				break
+			}
+			size = next
+			end++
+		}
+		if end == start {
+			// A single line over the limit goes into a part of its own
+			oversized = true
+			end = start + 1
+		} else if end < len(lines) {
+			for back := end; back > start+1; back-- {
+				if strings.TrimSpace(lines[back-1]) != "" {
+					end = back
+					break
+				}
+			}
+		}
+		bodies = append(bodies, strings.Join(lines[start:end], ""))
+		start = end
+	}
+
+	for _, body := range bodies {
+		parts = append(parts, a.NewEntry(e.Name, body))
+	}
+	return parts, oversized
+}
+
+// ResolveChunkSet returns the chunk files making up the archive at path, in order,
+// when path names a chunk set: either one of its chunks or the original output
+// path the set was split from. ok is false for an ordinary archive.
+func ResolveChunkSet(path string) (chunks []string, ok bool, err error) {
+	base := path
+	if m := chunkNameRegex.FindStringSubmatch(path); m != nil {
+		base = m[1] + m[3]
+	} else if _, statErr := os.Stat(path); statErr == nil {
+		return nil, false, nil
+	}
+	if _, statErr := os.Stat(ChunkPath(base, 1)); statErr != nil {
+		return nil, false, nil
+	}
+
+	manifest := ChunkPath(base, ManifestIndex)
+	expected := 0
+	if m, err := Load(manifest); err == nil {
+		chunks = append(chunks, manifest)
+		if values := m.Info().Get(ChunksKey); values != "" {
+			expected, _ = strconv.Atoi(values)
+		}
+	}
+	for i := 1; ; i++ {
+		chunk := ChunkPath(base, i)
+		if _, err := os.Stat(chunk); err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	found := len(chunks)
+	if expected > 0 {
+		found--
+	}
+	if expected > 0 && found != expected {
+		return nil, true, fmt.Errorf("incomplete chunk set for %s: found %d of %d chunks", base, found, expected)
+	}
+	return chunks, true, nil
+}
+
+// JoinChunks concatenates a chunk set into a single archive: the first chunk's
+// preamble and project info followed by every file entry in chunk order. The
+// parts of split files, numbered by the manifest's Part lines, are joined back
+// into one entry.
+func JoinChunks(chunks []string) ([]byte, error) {
+	var joined *Archive
+	var parts []string
+	var files []Entry
+	for _, path := range chunks {
+		c, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if joined == nil {
+			joined = &Archive{Dialect: c.Dialect, Preamble: c.Preamble}
+			if i := c.Index(parser.ProjectInfoMarker); i >= 0 {
+				joined.Entries = append(joined.Entries, c.Entries[i])
+				parts = c.Info()[PartKey]
+			}
+		}
+		files = append(files, c.Files()...)
+	}
+	if joined == nil {
+		return nil, fmt.Errorf("empty chunk set")
+	}
+
+	for k := 0; k < len(files); k++ {
+		index, total := 1, 1
+		if k < len(parts) {
+			index, total = parsePart(parts[k])
+		}
+		if total == 1 {
+			joined.Entries = append(joined.Entries, files[k])
+			continue
+		}
+		name := files[k].Name
+		if index != 1 || k+total > len(files) {
+			return nil, fmt.Errorf("incomplete parts of %s in chunk set", name)
+		}
+		contents := make([]string, total)
+		for j := range total {
+			if files[k+j].Name != name {
+				return nil, fmt.Errorf("incomplete parts of %s in chunk set", name)
+			}
+			contents[j] = files[k+j].Content()
+		}
+		joined.Entries = append(joined.Entries, joined.NewEntry(name, strings.Join(contents, "\n")))
+		k += total - 1
+	}
+	return joined.Bytes(), nil
+}
@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// CacheSuffix is appended to an archive path to name its content-hash cache.
+const CacheSuffix = ".cache"
+
+// CacheEntry records what a source file looked like when it was last archived.
+type CacheEntry struct {
+	ModTime int64  `json:"modTime"`
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash"`
+}
+
+// Cache maps slash-separated source paths to their last known state.
+type Cache struct {
+	Version int                   `json:"version"`
+	Files   map[string]CacheEntry `json:"files"`
+}
+
+// LoadCache reads the cache stored next to archivePath. A missing or unreadable
+// cache yields an empty one, which only costs a full rehash.
+func LoadCache(archivePath string) *Cache {
+	cache := &Cache{Version: 1, Files: map[string]CacheEntry{}}
+	data, err := os.ReadFile(archivePath + CacheSuffix)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil || cache.Files == nil {
+		return &Cache{Version: 1, Files: map[string]CacheEntry{}}
+	}
+	return cache
+}
+
+// Save writes the cache next to archivePath.
+func (c *Cache) Save(archivePath string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+	return WriteFileAtomic(archivePath+CacheSuffix, data)
+}
+
+// UpdateResults summarizes an incremental archive update.
+type UpdateResults struct {
+	Success   bool     `json:"success"`
+	Added     []string `json:"added"`
+	Modified  []string `json:"modified"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+	Rehashed  int      `json:"rehashed"`
+	Errors    []string `json:"errors"`
+}
+
+// Changed reports whether the update rewrote any entry.
+func (r *UpdateResults) Changed() bool {
+	return len(r.Added)+len(r.Modified)+len(r.Removed) > 0
+}
+
+// Update brings an existing archive in line with sourceDir, rewriting only the
+// entries whose files changed, were added or were removed. Files whose mtime and
+// size match the cache are not reread, and unchanged entries stay byte-identical.
+func Update(archivePath, sourceDir string, excludePatterns []string) (*UpdateResults, error) {
+	result := &UpdateResults{Success: true, Added: []string{}, Modified: []string{}, Removed: []string{}, Errors: []string{}}
+
+	if _, err := os.Stat(sourceDir); err != nil {
+		return nil, fmt.Errorf("source directory does not exist: %s", sourceDir)
+	}
+	a, err := Load(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	cache := LoadCache(archivePath)
+	nextCache := &Cache{Version: 1, Files: map[string]CacheEntry{}}
+
+	// Never archive the archive itself or its cache
+	skip := map[string]bool{}
+	for _, p := range []string{archivePath, archivePath + CacheSuffix} {
+		if abs, err := filepath.Abs(p); err == nil {
+			skip[abs] = true
+		}
+	}
+
+	type sourceFile struct {
+		rel  string
+		hash string
+	}
+	var current []sourceFile
+	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Error accessing %s: %v", path, err))
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && skip[abs] {
+			return nil
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil || parser.MatchesExclude(rel, excludePatterns) {
+			return nil
+		}
+
+		key := filepath.ToSlash(rel)
+		entry := CacheEntry{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+		if cached, ok := cache.Files[key]; ok && cached.ModTime == entry.ModTime && cached.Size == entry.Size {
+			entry.Hash = cached.Hash
+		} else {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Failed to read %s: %v", rel, err))
+				return nil
+			}
+			entry.Hash = parser.ContentHash(string(data))
+			result.Rehashed++
+		}
+		nextCache.Files[key] = entry
+		current = append(current, sourceFile{rel: rel, hash: entry.Hash})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	present := map[string]bool{}
+	positions := a.Positions()
+	info, hasInfo := positions[parser.ProjectInfoMarker]
+	for _, f := range current {
+		present[f.rel] = true
+		i, found := positions[f.rel]
+		if found && parser.ContentHash(a.Entries[i].Content()) == f.hash {
+			result.Unchanged++
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(sourceDir, f.rel))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to read %s: %v", f.rel, err))
+			continue
+		}
+		at := len(a.Entries)
+		if found {
+			at = i
+		}
+		index := at + 1
+		if hasInfo && info < at {
+			index--
+		}
+		entry := a.newEntryAt(f.rel, string(data), index)
+		if found {
+			a.Entries[i] = entry
+			result.Modified = append(result.Modified, f.rel)
+		} else {
+			positions[f.rel] = len(a.Entries)
+			a.Entries = append(a.Entries, entry)
+			result.Added = append(result.Added, f.rel)
+		}
+	}
+
+	kept := a.Entries[:0]
+	for _, e := range a.Entries {
+		if e.Name != parser.ProjectInfoMarker && !present[e.Name] {
+			result.Removed = append(result.Removed, e.Name)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	a.Entries = kept
+	sort.Strings(result.Removed)
+
+	if result.Changed() {
+		a.RefreshInfo()
+		if err := a.WriteFile(archivePath); err != nil {
+			return nil, err
+		}
+	}
+	// Rewriting an unchanged cache would wake watchers of the source tree
+	if !maps.Equal(nextCache.Files, cache.Files) {
+		if err := nextCache.Save(archivePath); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to save cache: %v", err))
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		result.Success = false
+	}
+	return result, nil
+}
@@ -0,0 +1,184 @@
+// Package changeset builds archives holding only the files that differ between
+// two versions of a project, plus the paths that were deleted.
+package changeset
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/diff"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/gitsrc"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
+)
+
+// Options configures how changed files are stored.
+type Options struct {
+	// Excerpts stores a modified file as its diff hunks, with Context lines
+	// around each change, whenever that is shorter than the whole file.
+	// Excerpted entries are for reading only and are skipped by extract.
+	Excerpts bool
+	Context  int
+	// Config is the marker dialect of the archive.
+	Config metadata.MarkerConfig
+}
+
+// Results summarizes a changeset.
+type Results struct {
+	*parser.GenerateResults
+	Summary  diff.Summary `json:"summary"`
+	Deleted  []string     `json:"deleted"`
+	Excerpts []string     `json:"excerpts"`
+}
+
+// SinceRev writes the changes from the tree of rev to the files tracked below
+// dir, or to the tree of toRev when it is set.
+func SinceRev(dir, rev, toRev, outputFile string, opts Options) (*Results, error) {
+	base, err := gitsrc.AtRevision(dir, rev)
+	if err != nil {
+		return nil, err
+	}
+	var head *gitsrc.Snapshot
+	if toRev == "" {
+		head, err = gitsrc.Tracked(dir)
+	} else {
+		head, err = gitsrc.AtRevision(dir, toRev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := fmt.Sprintf("%s: %s %s\n", parser.ChangesetKey, rev, base.Commit)
+	if head.Commit != "" {
+		info += fmt.Sprintf("%s: %s\n", parser.CommitKey, head.Commit)
+	}
+	result, err := Write(snapshotSource(base), snapshotSource(head), dir, info, outputFile, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, skipped := range head.Skipped {
+		result.Errors = append(result.Errors, "Skipped "+skipped)
+	}
+	return result, nil
+}
+
+// BetweenDirs writes the changes from oldDir to newDir.
+func BetweenDirs(oldDir, newDir string, excludePatterns []string, outputFile string, opts Options) (*Results, error) {
+	oldSrc, err := diff.LoadDirectory(oldDir, excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	newSrc, err := diff.LoadDirectory(newDir, excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	// Never treat the output of an earlier run as part of the project
+	if rel, err := relPath(newDir, outputFile); err == nil {
+		delete(oldSrc, rel)
+		delete(newSrc, rel)
+	}
+	info := fmt.Sprintf("%s: %s\n", parser.ChangesetKey, oldDir)
+	return Write(oldSrc, newSrc, newDir, info, outputFile, opts)
+}
+
+// Write stores every file of newSrc that is added, modified or renamed relative
+// to oldSrc, and records removed and renamed-away paths as Deleted lines, so
+// that extracting the archive with --mirror over the old version reproduces
+// the new one. info holds extra PROJECT_INFO lines describing the base.
+func Write(oldSrc, newSrc diff.Source, sourceDir, info, outputFile string, opts Options) (*Results, error) {
+	context := diff.DefaultContext
+	if opts.Excerpts {
+		context = opts.Context
+	}
+	changes := diff.Compare("old", oldSrc, "new", newSrc, context)
+
+	a, err := archive.New(opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	result := &Results{GenerateResults: parser.NewGenerateResults(), Summary: changes.Summary, Deleted: []string{}, Excerpts: []string{}}
+	for _, c := range changes.Changes {
+		if c.Kind == diff.Removed || c.Kind == diff.Renamed {
+			deleted := c.Path
+			if c.Kind == diff.Renamed {
+				deleted = c.OldPath
+			}
+			result.Deleted = append(result.Deleted, deleted)
+		}
+		if c.Kind == diff.Removed {
+			continue
+		}
+		if c.Binary {
+			result.Errors = append(result.Errors, fmt.Sprintf("Skipped %s (binary)", c.Path))
+			continue
+		}
+
+		content := newSrc[c.Path]
+		if opts.Excerpts && c.Kind == diff.Modified {
+			if hunks := excerpt(c.Patch); strings.Count(hunks, "\n") < strings.Count(content, "\n") {
+				content = hunks
+				result.Excerpts = append(result.Excerpts, c.Path)
+			}
+		}
+		if _, err := a.Set(c.Path, content); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Skipped %s: %v", c.Path, err))
+		}
+	}
+
+	abs, err := filepath.Abs(sourceDir)
+	if err != nil {
+		abs = sourceDir
+	}
+	header := parser.ProjectInfoHeader(filepath.Base(abs), sourceDir, 0)
+	header += info
+	for _, name := range result.Deleted {
+		header += fmt.Sprintf("%s: %s\n", parser.DeletedKey, name)
+	}
+	for _, name := range result.Excerpts {
+		header += fmt.Sprintf("%s: %s\n", parser.ExcerptKey, name)
+	}
+	a.SetProjectInfo(header)
+
+	if err := a.WriteFile(outputFile); err != nil {
+		return nil, err
+	}
+	data := a.Bytes()
+	for _, e := range a.Files() {
+		result.FileTokens[e.Name] = tokens.Count(e.Raw)
+	}
+	result.TotalFiles = len(a.Files())
+	result.TotalBytes = int64(len(data))
+	result.TotalTokens = tokens.Count(string(data))
+	return result, nil
+}
+
+// excerpt drops the file header lines of a unified diff, keeping its hunks.
+func excerpt(patch string) string {
+	_, rest, _ := strings.Cut(patch, "\n")
+	_, hunks, _ := strings.Cut(rest, "\n")
+	return hunks
+}
+
+// relPath returns target relative to dir in slash form.
+func relPath(dir, target string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absTarget)
+	return filepath.ToSlash(rel), err
+}
+
+func snapshotSource(s *gitsrc.Snapshot) diff.Source {
+	src := diff.Source{}
+	for _, f := range s.Files {
+		src[f.Name] = strings.TrimRight(string(f.Content), "\n")
+	}
+	return src
+}
@@ -0,0 +1,294 @@
+// Package config loads layered lookatni settings: the built-in presets, then
+// the user's config file, then the .lookatni.yaml of the project. Later layers
+// add presets and profiles and override default settings field by field.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile is the name of the project config file, looked up from the
+// working directory towards the filesystem root.
+const ProjectFile = ".lookatni.yaml"
+
+// BuiltIn is the source reported for presets compiled into lookatni.
+const BuiltIn = "built-in"
+
+// Settings are command defaults. Flags given on the command line win.
+type Settings struct {
+	// Preset is the marker dialect generate writes.
+	Preset string `yaml:"preset,omitempty" json:"preset,omitempty"`
+	// Exclude replaces, not extends, the exclude patterns of earlier layers.
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	// Output is the archive generate writes when no output file is given.
+	Output      string `yaml:"output,omitempty" json:"output,omitempty"`
+	Strict      *bool  `yaml:"strict,omitempty" json:"strict,omitempty"`
+	Overwrite   *bool  `yaml:"overwrite,omitempty" json:"overwrite,omitempty"`
+	Armor       *bool  `yaml:"armor,omitempty" json:"armor,omitempty"`
+	Tokenizer   string `yaml:"tokenizer,omitempty" json:"tokenizer,omitempty"`
+	TokenBudget int    `yaml:"token-budget,omitempty" json:"tokenBudget,omitempty"`
+}
+
+// Preset is a named marker dialect defined in a config file.
+type Preset struct {
+	Description           string `yaml:"description,omitempty"`
+	metadata.MarkerConfig `yaml:",inline"`
+	// Source is the file that defined the preset.
+	Source string `yaml:"-"`
+}
+
+// Profile is a named set of settings selected with --profile.
+type Profile struct {
+	Settings `yaml:",inline"`
+	Source   string `yaml:"-"`
+}
+
+// File is the layout of a config file.
+type File struct {
+	Presets  map[string]Preset  `yaml:"presets,omitempty"`
+	Defaults Settings           `yaml:"defaults,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Config is the merged result of every layer.
+type Config struct {
+	Presets  map[string]Preset
+	Defaults Settings
+	Profiles map[string]Profile
+	// Files lists the config files that were loaded, in layer order.
+	Files []string
+}
+
+// UserFile returns the path of the user's config file:
+// $XDG_CONFIG_HOME/lookatni/config.yaml, or ~/.config/lookatni/config.yaml.
+func UserFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "lookatni", "config.yaml")
+}
+
+// FindProjectFile returns the nearest ProjectFile at or above dir, or "".
+func FindProjectFile(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Load reads the user config file and the project file for dir, when they exist.
+func Load(dir string) (*Config, error) {
+	var paths []string
+	if user := UserFile(); user != "" {
+		paths = append(paths, user)
+	}
+	if project := FindProjectFile(dir); project != "" {
+		paths = append(paths, project)
+	}
+	return LoadFiles(paths...)
+}
+
+// LoadFiles merges the given config files in order; missing files are skipped.
+func LoadFiles(paths ...string) (*Config, error) {
+	c := &Config{Presets: map[string]Preset{}, Profiles: map[string]Profile{}}
+	builtIn := metadata.GetPresetConfigs()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var f File
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		c.Files = append(c.Files, path)
+
+		for name, p := range f.Presets {
+			if _, ok := builtIn[name]; ok {
+				return nil, fmt.Errorf("%s: preset %q redefines a built-in preset", path, name)
+			}
+			if _, err := metadata.NewDialect(name, p.MarkerConfig); err != nil {
+				return nil, fmt.Errorf("%s: preset %q: %w", path, name, err)
+			}
+			p.Source = path
+			c.Presets[name] = p
+		}
+		for name, p := range f.Profiles {
+			p.Source = path
+			c.Profiles[name] = p
+		}
+		c.Defaults = c.Defaults.Merge(f.Defaults)
+	}
+	return c, nil
+}
+
+// Merge returns s overridden by every field set in over.
+func (s Settings) Merge(over Settings) Settings {
+	if over.Preset != "" {
+		s.Preset = over.Preset
+	}
+	if len(over.Exclude) > 0 {
+		s.Exclude = over.Exclude
+	}
+	if over.Output != "" {
+		s.Output = over.Output
+	}
+	if over.Strict != nil {
+		s.Strict = over.Strict
+	}
+	if over.Overwrite != nil {
+		s.Overwrite = over.Overwrite
+	}
+	if over.Armor != nil {
+		s.Armor = over.Armor
+	}
+	if over.Tokenizer != "" {
+		s.Tokenizer = over.Tokenizer
+	}
+	if over.TokenBudget > 0 {
+		s.TokenBudget = over.TokenBudget
+	}
+	return s
+}
+
+// Settings returns the defaults overridden by the named profile, if any.
+func (c *Config) Settings(profile string) (Settings, error) {
+	if profile == "" {
+		return c.Defaults, nil
+	}
+	p, ok := c.Profiles[profile]
+	if !ok {
+		return Settings{}, fmt.Errorf("unknown profile %q (defined: %v)", profile, sortedKeys(c.Profiles))
+	}
+	return c.Defaults.Merge(p.Settings), nil
+}
+
+// Register makes the config presets available as marker dialects. Presets
+// registered by an earlier load with the same markers are left alone.
+func (c *Config) Register() error {
+	for _, name := range sortedKeys(c.Presets) {
+		p := c.Presets[name]
+		if d, ok := metadata.LookupDialect(name); ok {
+			if reflect.DeepEqual(d.Config(), p.MarkerConfig) {
+				continue
+			}
+			return fmt.Errorf("%s: preset %q is already registered with other markers", p.Source, name)
+		}
+		d, err := metadata.NewDialect(name, p.MarkerConfig)
+		if err != nil {
+			return fmt.Errorf("%s: preset %q: %w", p.Source, name, err)
+		}
+		metadata.RegisterDialect(d)
+	}
+	return nil
+}
+
+// Flag returns the value of a boolean setting, or false when unset.
+func Flag(b *bool) bool {
+	return b != nil && *b
+}
+
+// PresetInfo describes a preset for listings.
+type PresetInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Example     string `json:"example"`
+	Source      string `json:"source"`
+}
+
+// ProfileInfo describes a profile for listings.
+type ProfileInfo struct {
+	Name     string   `json:"name"`
+	Source   string   `json:"source"`
+	Settings Settings `json:"settings"`
+}
+
+// Listing is the output of the presets command.
+type Listing struct {
+	Presets  []PresetInfo  `json:"presets"`
+	Profiles []ProfileInfo `json:"profiles"`
+}
+
+// List describes the built-in and configured presets, and the profiles.
+func (c *Config) List() Listing {
+	l := Listing{Presets: []PresetInfo{}, Profiles: []ProfileInfo{}}
+	builtIn := metadata.GetPresetConfigs()
+	for _, name := range sortedKeys(builtIn) {
+		p := builtIn[name]
+		l.Presets = append(l.Presets, PresetInfo{Name: name, Description: p.Description, Example: p.Config.FormatMarker("example.go"), Source: BuiltIn})
+	}
+	for _, name := range sortedKeys(c.Presets) {
+		p := c.Presets[name]
+		l.Presets = append(l.Presets, PresetInfo{Name: name, Description: p.Description, Example: p.FormatMarker("example.go"), Source: p.Source})
+	}
+	for _, name := range sortedKeys(c.Profiles) {
+		p := c.Profiles[name]
+		l.Profiles = append(l.Profiles, ProfileInfo{Name: name, Source: p.Source, Settings: p.Settings})
+	}
+	return l
+}
+
+// WriteJSON writes the listing as indented JSON.
+func (l Listing) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// WriteText writes the listing for humans.
+func (l Listing) WriteText(w io.Writer) error {
+	fmt.Fprintln(w, "Presets:")
+	for _, p := range l.Presets {
+		fmt.Fprintf(w, "  %-10s %s  [%s]\n", p.Name, p.Example, p.Source)
+		if p.Description != "" {
+			fmt.Fprintf(w, "  %-10s %s\n", "", p.Description)
+		}
+	}
+	if len(l.Profiles) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "Profiles:")
+	for _, p := range l.Profiles {
+		if _, err := fmt.Fprintf(w, "  %-10s [%s]\n", p.Name, p.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
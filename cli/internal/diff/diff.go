@@ -0,0 +1,287 @@
+// Package diff compares LookAtni archives with each other or with directories.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/adaptive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// ChangeKind classifies a file-level difference.
+type ChangeKind string
+
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Modified ChangeKind = "modified"
+	Renamed  ChangeKind = "renamed"
+)
+
+// FileChange describes how a single file differs between two sources.
+type FileChange struct {
+	Kind      ChangeKind `json:"kind"`
+	Path      string     `json:"path"`
+	OldPath   string     `json:"oldPath,omitempty"`
+	Binary    bool       `json:"binary,omitempty"`
+	Additions int        `json:"additions"`
+	Deletions int        `json:"deletions"`
+	Patch     string     `json:"patch,omitempty"`
+}
+
+// Summary counts changes by kind.
+type Summary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Renamed   int `json:"renamed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Result contains the differences between two sources.
+type Result struct {
+	Old     string       `json:"old"`
+	New     string       `json:"new"`
+	Changes []FileChange `json:"changes"`
+	Summary Summary      `json:"summary"`
+}
+
+// Source is a snapshot of file contents keyed by slash-separated relative path.
+// Contents are normalized like parsed markers: trailing newlines are trimmed.
+type Source map[string]string
+
+// Load reads an archive or a directory into a Source.
+func Load(path string, excludePatterns []string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return LoadDirectory(path, excludePatterns)
+	}
+	return LoadArchive(path)
+}
+
+// LoadArchive parses a marked file into a Source, skipping the PROJECT_INFO section.
+func LoadArchive(markedFile string) (Source, error) {
+	results, _, err := adaptive.New().ParseMarkedFile(markedFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", markedFile, err)
+	}
+	src := Source{}
+	for _, marker := range results.Markers {
+		if marker.Filename == parser.ProjectInfoMarker {
+			continue
+		}
+		// Later duplicates win, matching extraction with --overwrite
+		src[filepath.ToSlash(marker.Filename)] = marker.Content
+	}
+	return src, nil
+}
+
+// LoadDirectory reads every non-excluded file below dir into a Source.
+func LoadDirectory(dir string, excludePatterns []string) (Source, error) {
+	src := Source{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel != "." && parser.MatchesExclude(rel, excludePatterns) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if parser.MatchesExclude(rel, excludePatterns) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		src[filepath.ToSlash(rel)] = strings.TrimRight(string(data), "\n")
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	return src, nil
+}
+
+// Compare computes file-level changes from oldSrc to newSrc. Files removed on one
+// side and added on the other with identical content are reported as renames.
+func Compare(oldName string, oldSrc Source, newName string, newSrc Source, context int) *Result {
+	result := &Result{Old: oldName, New: newName, Changes: []FileChange{}}
+
+	var removed, added []string
+	for path, oldContent := range oldSrc {
+		newContent, ok := newSrc[path]
+		if !ok {
+			removed = append(removed, path)
+			continue
+		}
+		if oldContent == newContent {
+			result.Summary.Unchanged++
+			continue
+		}
+		change := FileChange{Kind: Modified, Path: path}
+		fillPatch(&change, "a/"+path, "b/"+path, oldContent, newContent, context)
+		result.Changes = append(result.Changes, change)
+		result.Summary.Modified++
+	}
+	for path := range newSrc {
+		if _, ok := oldSrc[path]; !ok {
+			added = append(added, path)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	// Pair exact-content matches as renames
+	addedByHash := map[string][]string{}
+	for _, path := range added {
+		if newSrc[path] == "" {
+			continue
+		}
+		h := parser.ContentHash(newSrc[path])
+		addedByHash[h] = append(addedByHash[h], path)
+	}
+	renamedTo := map[string]bool{}
+	for _, oldPath := range removed {
+		if oldSrc[oldPath] == "" {
+			continue
+		}
+		h := parser.ContentHash(oldSrc[oldPath])
+		candidates := addedByHash[h]
+		if len(candidates) == 0 {
+			continue
+		}
+		newPath := candidates[0]
+		addedByHash[h] = candidates[1:]
+		renamedTo[newPath] = true
+		renamedTo[oldPath] = true
+		result.Changes = append(result.Changes, FileChange{Kind: Renamed, Path: newPath, OldPath: oldPath})
+		result.Summary.Renamed++
+	}
+
+	for _, path := range removed {
+		if renamedTo[path] {
+			continue
+		}
+		change := FileChange{Kind: Removed, Path: path}
+		fillPatch(&change, "a/"+path, "/dev/null", oldSrc[path], "", context)
+		result.Changes = append(result.Changes, change)
+		result.Summary.Removed++
+	}
+	for _, path := range added {
+		if renamedTo[path] {
+			continue
+		}
+		change := FileChange{Kind: Added, Path: path}
+		fillPatch(&change, "/dev/null", "b/"+path, "", newSrc[path], context)
+		result.Changes = append(result.Changes, change)
+		result.Summary.Added++
+	}
+
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		return result.Changes[i].Path < result.Changes[j].Path
+	})
+	return result
+}
+
+// HasChanges reports whether any file differs.
+func (r *Result) HasChanges() bool {
+	return len(r.Changes) > 0
+}
+
+// WriteJSON writes the result as indented JSON.
+func (r *Result) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteNameOnly writes one changed path per line.
+func (r *Result) WriteNameOnly(w io.Writer) error {
+	for _, c := range r.Changes {
+		if _, err := fmt.Fprintln(w, c.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStat writes a per-file summary of added and removed lines.
+func (r *Result) WriteStat(w io.Writer) error {
+	width := 0
+	for _, c := range r.Changes {
+		width = max(width, len(statName(c)))
+	}
+	additions, deletions := 0, 0
+	for _, c := range r.Changes {
+		additions += c.Additions
+		deletions += c.Deletions
+		detail := fmt.Sprintf("+%d -%d", c.Additions, c.Deletions)
+		if c.Binary {
+			detail = "binary"
+		}
+		if _, err := fmt.Fprintf(w, " %-*s | %-8s %s\n", width, statName(c), c.Kind, detail); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, " %d files changed (%d added, %d removed, %d modified, %d renamed), %d insertions(+), %d deletions(-)\n",
+		len(r.Changes), r.Summary.Added, r.Summary.Removed, r.Summary.Modified, r.Summary.Renamed, additions, deletions)
+	return err
+}
+
+// WriteText writes a change list followed by unified diffs for text entries.
+func (r *Result) WriteText(w io.Writer) error {
+	for _, c := range r.Changes {
+		if _, err := fmt.Fprintf(w, "%-8s %s\n", c.Kind, statName(c)); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Changes {
+		var err error
+		switch {
+		case c.Binary:
+			_, err = fmt.Fprintf(w, "\nBinary files differ: %s\n", c.Path)
+		case c.Patch != "":
+			_, err = fmt.Fprintf(w, "\n%s\n", c.Patch)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func statName(c FileChange) string {
+	if c.Kind == Renamed {
+		return c.OldPath + " => " + c.Path
+	}
+	return c.Path
+}
+
+func fillPatch(change *FileChange, oldName, newName, oldContent, newContent string, context int) {
+	if isBinary(oldContent) || isBinary(newContent) {
+		change.Binary = true
+		return
+	}
+	change.Patch, change.Additions, change.Deletions = Unified(oldName, newName, oldContent, newContent, context)
+}
+
+func isBinary(content string) bool {
+	return strings.ContainsRune(content, 0) || !utf8.ValidString(content)
+}
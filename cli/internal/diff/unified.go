@@ -0,0 +1,277 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each hunk.
+const DefaultContext = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// edit is a single step of a line edit script.
+type edit struct {
+	kind opKind
+	line string
+}
+
+// Unified renders a unified diff between two texts. It returns the diff body
+// (without trailing newline) and the number of added and removed lines.
+func Unified(oldName, newName, oldText, newText string, context int) (string, int, int) {
+	a := splitLines(oldText)
+	b := splitLines(newText)
+	edits := lineEdits(a, b)
+
+	additions, deletions := 0, 0
+	changed := make([]int, 0)
+	for i, e := range edits {
+		switch e.kind {
+		case opInsert:
+			additions++
+			changed = append(changed, i)
+		case opDelete:
+			deletions++
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return "", 0, 0
+	}
+
+	// Line positions before each edit, used for hunk headers
+	aPos := make([]int, len(edits)+1)
+	bPos := make([]int, len(edits)+1)
+	for i, e := range edits {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if e.kind != opInsert {
+			aPos[i+1]++
+		}
+		if e.kind != opDelete {
+			bPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s", oldName, newName)
+
+	for g := 0; g < len(changed); {
+		// Group changes whose context windows overlap
+		first := changed[g]
+		last := first
+		g++
+		for g < len(changed) && changed[g]-last-1 <= 2*context {
+			last = changed[g]
+			g++
+		}
+
+		start := max(first-context, 0)
+		end := min(last+context+1, len(edits))
+
+		aCount := aPos[end] - aPos[start]
+		bCount := bPos[end] - bPos[start]
+		fmt.Fprintf(&out, "\n@@ -%s +%s @@", hunkRange(aPos[start], aCount), hunkRange(bPos[start], bCount))
+		for _, e := range edits[start:end] {
+			switch e.kind {
+			case opEqual:
+				out.WriteString("\n " + e.line)
+			case opDelete:
+				out.WriteString("\n-" + e.line)
+			case opInsert:
+				out.WriteString("\n+" + e.line)
+			}
+		}
+	}
+
+	return out.String(), additions, deletions
+}
+
+// hunkRange formats the "start,count" part of a hunk header.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// lineEdits computes a shortest edit script between a and b using the
+// linear-space variant of Myers' algorithm: it finds the middle snake of the
+// edit graph and recurses on both halves, so memory stays O(n+m).
+func lineEdits(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	return diffLines(edits, a, b)
+}
+
+// diffLines appends the edits turning a into b to edits.
+func diffLines(edits []edit, a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, edit{kind: opEqual, line: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			edits = append(edits, edit{kind: opInsert, line: line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			edits = append(edits, edit{kind: opDelete, line: line})
+		}
+	default:
+		if x, y, ok := split(a, b); ok {
+			edits = diffLines(edits, a[:x], b[:y])
+			edits = diffLines(edits, a[x:], b[y:])
+		} else {
+			for _, line := range a {
+				edits = append(edits, edit{kind: opDelete, line: line})
+			}
+			for _, line := range b {
+				edits = append(edits, edit{kind: opInsert, line: line})
+			}
+		}
+	}
+
+	for _, line := range common {
+		edits = append(edits, edit{kind: opEqual, line: line})
+	}
+	return edits
+}
+
+// split returns where a shortest edit script between a and b crosses its
+// middle, or false when a and b have no line in common. Without one the
+// script is a whole replacement, which the search would take time
+// proportional to the square of the lengths to find.
+func split(a, b []string) (int, int, bool) {
+	seen := make(map[string]bool, len(a))
+	for _, line := range a {
+		seen[line] = true
+	}
+	for _, line := range b {
+		if seen[line] {
+			return middleSnake(a, b)
+		}
+	}
+	return 0, 0, false
+}
+
+// middleSnake runs the forward and reverse searches of Myers' algorithm until
+// they overlap and returns the point where a shortest path crosses between
+// them. It reports false when a and b have no line in common.
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*maxD+3)
+	reverse := make([]int, 2*maxD+3)
+	for i := range forward {
+		forward[i], reverse[i] = -1, -1
+	}
+	forward[offset+1], reverse[offset+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals that ran off the edit graph are trimmed from the search
+	fStart, fEnd, rStart, rEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if r := offset + delta - k; r >= 0 && r < len(reverse) && reverse[r] != -1 && x >= n-reverse[r] {
+					return x, y, true
+				}
+			}
+		}
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && reverse[offset+k-1] < reverse[offset+k+1]) {
+				x = reverse[offset+k+1]
+			} else {
+				x = reverse[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			reverse[offset+k] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				if f := offset + delta - k; f >= 0 && f < len(forward) && forward[f] != -1 && forward[f] >= n-x {
+					fx := forward[f]
+					return fx, fx - (delta - k), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Match pairs an unchanged line: A indexes the old lines and B the new ones.
+type Match struct {
+	A, B int
+}
+
+// LineMatches returns the lines common to a and b along a shortest edit script,
+// in increasing order of both indexes.
+func LineMatches(a, b []string) []Match {
+	matches := make([]Match, 0)
+	i, j := 0, 0
+	for _, e := range lineEdits(a, b) {
+		switch e.kind {
+		case opEqual:
+			matches = append(matches, Match{A: i, B: j})
+			i++
+			j++
+		case opDelete:
+			i++
+		case opInsert:
+			j++
+		}
+	}
+	return matches
+}
@@ -0,0 +1,397 @@
+// Package exchange converts between lookatni archives and tar or zip files.
+package exchange
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// Format is a container format understood by Export and Import.
+type Format string
+
+// Supported formats.
+const (
+	Tar   Format = "tar"
+	TarGz Format = "tar.gz"
+	Zip   Format = "zip"
+)
+
+// DefaultMode is used for entries without a recorded mode.
+const DefaultMode fs.FileMode = 0o644
+
+// DetectFormat derives the format from a file name.
+func DetectFormat(name string) (Format, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return TarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return Tar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return Zip, nil
+	}
+	return "", fmt.Errorf("unsupported format for %s (expected .tar, .tar.gz, .tgz or .zip)", name)
+}
+
+// Results summarizes an export or import.
+type Results struct {
+	Files int `json:"files"`
+	// Skipped lists entries that were not transferred, with the reason.
+	Skipped []string `json:"skipped"`
+}
+
+// ExportFile writes the archive at archivePath to output in the format its name
+// implies. Entries are streamed from the archive, which is read twice: for its
+// PROJECT_INFO section, which need not come first, and then for the files.
+// Armored archives are loaded whole.
+func ExportFile(archivePath, output string) (*Results, error) {
+	format, err := DetectFormat(output)
+	if err != nil {
+		return nil, err
+	}
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+	defer in.Close()
+
+	var info parser.ProjectInfo
+	var entries iter.Seq2[archive.Entry, error]
+	sc, err := archive.NewScanner(in)
+	switch {
+	case errors.Is(err, archive.ErrArmored):
+		a, err := archive.Load(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		info, entries = a.Info(), entriesOf(a)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+	default:
+		for sc.Scan() {
+			if e := sc.Entry(); e.Name == parser.ProjectInfoMarker {
+				info = parser.ParseProjectInfo(e.Content())
+				break
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		if _, err := in.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		if sc, err = archive.NewScanner(in); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		entries = func(yield func(archive.Entry, error) bool) {
+			for sc.Scan() {
+				if e := sc.Entry(); e.Name != parser.ProjectInfoMarker && !yield(e, nil) {
+					return
+				}
+			}
+			if err := sc.Err(); err != nil {
+				yield(archive.Entry{}, fmt.Errorf("failed to read %s: %w", archivePath, err))
+			}
+		}
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	result, err := export(info, entries, f, format)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close %s: %w", output, closeErr)
+	}
+	if err != nil {
+		os.Remove(output)
+		return nil, err
+	}
+	return result, nil
+}
+
+// Export streams every file entry of a into w. Modes and modification times
+// recorded in PROJECT_INFO are applied; other entries get DefaultMode and the
+// archive's generation time. Absolute names and names with ".." components
+// are skipped.
+func Export(a *archive.Archive, w io.Writer, format Format) (*Results, error) {
+	return export(a.Info(), entriesOf(a), w, format)
+}
+
+// entriesOf yields the file entries of a.
+func entriesOf(a *archive.Archive) iter.Seq2[archive.Entry, error] {
+	return func(yield func(archive.Entry, error) bool) {
+		for _, e := range a.Files() {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// export writes the file entries to w with the modes and times of info.
+func export(info parser.ProjectInfo, entries iter.Seq2[archive.Entry, error], w io.Writer, format Format) (*Results, error) {
+	modes := info.PathValues(parser.ModeKey)
+	mtimes := info.PathValues(parser.ModTimeKey)
+	generated, err := time.Parse(time.RFC3339, info.Get("Generated"))
+	if err != nil {
+		generated = time.Now()
+	}
+
+	header := func(e archive.Entry) (fs.FileMode, time.Time) {
+		mode := DefaultMode
+		if m, err := strconv.ParseUint(modes[e.Name], 8, 32); err == nil {
+			mode = fs.FileMode(m).Perm()
+		}
+		mtime := generated
+		if t, err := time.Parse(time.RFC3339, mtimes[e.Name]); err == nil {
+			mtime = t
+		}
+		return mode, mtime
+	}
+	result := &Results{Skipped: []string{}}
+
+	// Names that would escape the directory the container is unpacked to
+	// are left out, as extraction refuses them
+	files := func(yield func(archive.Entry, error) bool) {
+		for e, err := range entries {
+			if err == nil {
+				if err := archive.ValidateName(e.Name); err != nil {
+					result.Skipped = append(result.Skipped, fmt.Sprintf("%s (%v)", e.Name, err))
+					continue
+				}
+			}
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+
+	switch format {
+	case Zip:
+		zw := zip.NewWriter(w)
+		for e, err := range files {
+			if err != nil {
+				return nil, err
+			}
+			mode, mtime := header(e)
+			fh := &zip.FileHeader{Name: e.Name, Method: zip.Deflate, Modified: mtime}
+			fh.SetMode(mode)
+			fw, err := zw.CreateHeader(fh)
+			if err != nil {
+				return nil, fmt.Errorf("failed to add %s: %w", e.Name, err)
+			}
+			if _, err := io.WriteString(fw, exportContent(e)); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", e.Name, err)
+			}
+			result.Files++
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to finish zip: %w", err)
+		}
+
+	case Tar, TarGz:
+		var gz *gzip.Writer
+		if format == TarGz {
+			gz = gzip.NewWriter(w)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		for e, err := range files {
+			if err != nil {
+				return nil, err
+			}
+			mode, mtime := header(e)
+			content := exportContent(e)
+			hdr := &tar.Header{Typeflag: tar.TypeReg, Name: e.Name, Mode: int64(mode), Size: int64(len(content)), ModTime: mtime, Format: tar.FormatPAX}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, fmt.Errorf("failed to add %s: %w", e.Name, err)
+			}
+			if _, err := io.WriteString(tw, content); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", e.Name, err)
+			}
+			result.Files++
+		}
+		if err := tw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to finish tar: %w", err)
+		}
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				return nil, fmt.Errorf("failed to finish gzip: %w", err)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return result, nil
+}
+
+// exportContent returns the entry body exactly as archived, which for generated
+// archives is the original file content.
+func exportContent(e archive.Entry) string {
+	return e.Body()
+}
+
+// ImportFile reads a tar or zip file and writes its regular files as an archive
+// in the default dialect, recording their modes and modification times. Entries
+// are written to the output as they are read, so memory does not grow with the
+// input; the PROJECT_INFO section, which lists every mode and time, comes last.
+// Of several entries with one name, the first is kept.
+func ImportFile(input, output string) (*Results, error) {
+	format, err := DetectFormat(input)
+	if err != nil {
+		return nil, err
+	}
+	d, err := metadata.DialectFor(metadata.GetDefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	fm, err := metadata.FrontmatterFor(d)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", input, err)
+	}
+	defer f.Close()
+
+	modes, mtimes, seen := map[string]string{}, map[string]string{}, map[string]bool{}
+	result := &Results{Skipped: []string{}}
+	err = archive.WriteAtomic(output, func(out io.Writer) error {
+		w := bufio.NewWriter(out)
+		if _, err := w.Write(fm); err != nil {
+			return err
+		}
+		add := func(name string, mode fs.FileMode, mtime time.Time, r io.Reader) error {
+			name = archive.EntryName(name)
+			if err := archive.ValidateName(name); err != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s (%v)", name, err))
+				return nil
+			}
+			if seen[name] {
+				result.Skipped = append(result.Skipped, name+" (duplicate)")
+				return nil
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			if bytes.IndexByte(data, 0) >= 0 {
+				result.Skipped = append(result.Skipped, name+" (binary)")
+				return nil
+			}
+			content := string(data)
+			marker := metadata.FormatMarker(d, name, parser.MarkerAttrs(name, content, mode.Perm(), result.Files+1))
+			if _, err := w.WriteString(marker + "\n" + metadata.WrapContent(d, name, content)); err != nil {
+				return fmt.Errorf("failed to write %s: %w", name, err)
+			}
+			seen[name] = true
+			if mode.Perm() != DefaultMode {
+				modes[name] = fmt.Sprintf("%04o", mode.Perm())
+			}
+			if !mtime.IsZero() {
+				mtimes[name] = mtime.UTC().Format(time.RFC3339)
+			}
+			result.Files++
+			return nil
+		}
+		if err := readContainer(f, input, format, add, result); err != nil {
+			return err
+		}
+
+		info := parser.ProjectInfoHeader(strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)), input, result.Files)
+		info += parser.FormatPathValues(parser.ModeKey, modes)
+		info += parser.FormatPathValues(parser.ModTimeKey, mtimes) + "\n"
+		if _, err := w.WriteString(metadata.FormatMarker(d, parser.ProjectInfoMarker, nil) + "\n" + metadata.WrapContent(d, parser.ProjectInfoMarker, info)); err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readContainer calls add for every regular file of the tar or zip file f,
+// recording other entries in result as skipped.
+func readContainer(f *os.File, input string, format Format, add func(string, fs.FileMode, time.Time, io.Reader) error, result *Results) error {
+	switch format {
+	case Zip:
+		stat, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", input, err)
+		}
+		zr, err := zip.NewReader(f, stat.Size())
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", input, err)
+		}
+		for _, zf := range zr.File {
+			if !zf.Mode().IsRegular() {
+				if !zf.Mode().IsDir() {
+					result.Skipped = append(result.Skipped, zf.Name+" (not a regular file)")
+				}
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", zf.Name, err)
+			}
+			err = add(zf.Name, zf.Mode(), zf.Modified, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+	case Tar, TarGz:
+		var r io.Reader = f
+		if format == TarGz {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", input, err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", input, err)
+			}
+			switch hdr.Typeflag {
+			case tar.TypeReg:
+				if err := add(hdr.Name, fs.FileMode(hdr.Mode), hdr.ModTime, tr); err != nil {
+					return err
+				}
+			case tar.TypeDir, tar.TypeXGlobalHeader:
+			default:
+				result.Skipped = append(result.Skipped, hdr.Name+" (not a regular file)")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package formats reads and writes archives as structured documents instead
+// of marker lines: XML documents as recommended for LLM prompts, and JSON or
+// JSONL with one object per file. Every format holds the same entries as a
+// marked file, PROJECT_INFO included, so conversions between them are lossless.
+// It also reads the output of other repository packers: repomix, gitingest and
+// files-to-prompt.
+package formats
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// Format names.
+const (
+	XML   = "xml"
+	JSON  = "json"
+	JSONL = "jsonl"
+)
+
+// Base64 is the encoding recorded for content a format cannot carry as text.
+const Base64 = "base64"
+
+// Names lists the document formats.
+func Names() []string {
+	return []string{XML, JSON, JSONL}
+}
+
+// Readers lists the formats Decode reads: the document formats, then the
+// packer formats.
+func Readers() []string {
+	names := Names()
+	for _, p := range packers {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// IsFormat reports whether name is a document format.
+func IsFormat(name string) bool {
+	return slices.Contains(Names(), name)
+}
+
+// CanRead reports whether name is a format Decode reads.
+func CanRead(name string) bool {
+	return slices.Contains(Readers(), name)
+}
+
+// Detect returns the document or packer format of data, or "" for a marked file.
+func Detect(data []byte) string {
+	format, _ := detect(data)
+	return format
+}
+
+// DetectDocument describes the format of data as a detection with the files
+// it holds; ok is false for a marked file.
+func DetectDocument(data []byte) (detected metadata.Detection, ok bool, err error) {
+	format, confidence := detect(data)
+	if format == "" {
+		return metadata.Detection{}, false, nil
+	}
+	results, err := Decode(data, format)
+	if err != nil {
+		return metadata.Detection{}, false, err
+	}
+	return metadata.Detection{
+		Format:     format,
+		Source:     metadata.FromDocument,
+		Confidence: math.Round(confidence*100) / 100,
+		Markers:    results.TotalMarkers,
+	}, true, nil
+}
+
+// detect returns the format of data and how sure the choice is. The document
+// formats are certain from their first character on.
+func detect(data []byte) (string, float64) {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\ufeff")), " \t\r\n")
+	switch {
+	case len(trimmed) == 0:
+		return "", 0
+	case trimmed[0] == '<' && isXMLDocuments(trimmed):
+		return XML, 1
+	case trimmed[0] == '[' && isJSON(trimmed):
+		return JSON, 1
+	case trimmed[0] == '{' && isJSONL(trimmed):
+		return JSONL, 1
+	}
+	return detectPacker(data)
+}
+
+// Decode reads the files of data in the given format into parse results, as
+// parser.ParseContent does for marked files.
+func Decode(data []byte, format string) (*parser.ParseResults, error) {
+	var markers []parser.ParsedMarker
+	var err error
+	switch format {
+	case XML:
+		markers, err = decodeXML(data)
+	case JSON, JSONL:
+		markers, err = decodeJSON(data, format)
+	default:
+		p, ok := lookupPacker(format)
+		if !ok {
+			return nil, fmt.Errorf("unknown document format %q", format)
+		}
+		markers = p.split(strings.Split(string(data), "\n"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s document: %w", format, err)
+	}
+
+	results := &parser.ParseResults{Errors: make([]parser.ParseError, 0), Markers: make([]parser.ParsedMarker, 0, len(markers))}
+	for i, m := range markers {
+		if m.Filename == "" {
+			results.Errors = append(results.Errors, parser.ParseError{Line: i + 1, Message: fmt.Sprintf("Empty filename in %s entry %d", format, i+1), Severity: "error"})
+			continue
+		}
+		if err := parser.CheckName(m.Filename); err != nil {
+			results.Errors = append(results.Errors, parser.ParseError{Line: i + 1, Message: fmt.Sprintf("Unsafe filename in %s entry %d: %v", format, i+1, err), Severity: "error"})
+			continue
+		}
+		m.Content = strings.TrimRight(m.Content, "\n")
+		m.Size = int64(len(m.Content))
+		results.Markers = append(results.Markers, m)
+		results.TotalMarkers++
+		results.TotalFiles++
+		results.TotalBytes += m.Size
+	}
+	return results, nil
+}
+
+// Encode renders markers in the given format.
+func Encode(markers []parser.ParsedMarker, format string) ([]byte, error) {
+	switch format {
+	case XML:
+		return encodeXML(markers), nil
+	case JSON, JSONL:
+		return encodeJSON(markers, format)
+	}
+	return nil, fmt.Errorf("unknown document format %q", format)
+}
+
+// ConvertFile rewrites the marked file at path in the given format and
+// returns its new size.
+func ConvertFile(path, format string) (int64, error) {
+	data, err := parser.ReadArchive(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	detected, err := metadata.Detect(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	_, rest, _ := metadata.ParseFrontmatter(data)
+	results := parser.ParseContent(rest, detected.Dialect)
+	out, err := Encode(results.Markers, format)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return int64(len(out)), nil
+}
+
+// textContent returns content and "", or its base64 form and Base64 when
+// valid reports that the format cannot carry it as text.
+func textContent(content string, valid func(string) bool) (string, string) {
+	if valid(content) {
+		return content, ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(content)), Base64
+}
+
+// decodeContent reverses textContent.
+func decodeContent(content, encoding string) (string, error) {
+	switch encoding {
+	case "":
+		return content, nil
+	case Base64:
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+		return string(data), err
+	}
+	return "", fmt.Errorf("unknown content encoding %q", encoding)
+}
+
+// isUTF8 is the text check of the JSON formats.
+func isUTF8(s string) bool {
+	return utf8.ValidString(s)
+}
@@ -0,0 +1,101 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// jsonFile is one file in the JSON and JSONL formats. Content that is not
+// valid UTF-8 is base64-encoded and marked with its encoding.
+type jsonFile struct {
+	Filename string            `json:"filename"`
+	Content  string            `json:"content"`
+	Encoding string            `json:"encoding,omitempty"`
+	Size     int64             `json:"size"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+}
+
+// isJSON reports whether data is a JSON array.
+func isJSON(data []byte) bool {
+	return json.Valid(data)
+}
+
+// isJSONL reports whether the first line of data is a JSON object.
+func isJSONL(data []byte) bool {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	return json.Valid(bytes.TrimSpace(first))
+}
+
+// decodeJSON reads a JSON array of files, or one file per line for JSONL.
+func decodeJSON(data []byte, format string) ([]parser.ParsedMarker, error) {
+	var files []jsonFile
+	lines := []int{}
+	if format == JSON {
+		if err := json.Unmarshal(data, &files); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, len(data)+1)
+		for n := 1; scanner.Scan(); n++ {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var f jsonFile
+			if err := json.Unmarshal(line, &f); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			files = append(files, f)
+			lines = append(lines, n)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	markers := make([]parser.ParsedMarker, 0, len(files))
+	for i, f := range files {
+		content, err := decodeContent(f.Content, f.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", f.Filename, err)
+		}
+		m := parser.ParsedMarker{Filename: f.Filename, Content: content, Attrs: f.Attrs}
+		if i < len(lines) {
+			m.StartLine, m.EndLine = lines[i], lines[i]
+		}
+		markers = append(markers, m)
+	}
+	return markers, nil
+}
+
+// encodeJSON writes markers as an indented JSON array, or as JSONL with one
+// compact object per line.
+func encodeJSON(markers []parser.ParsedMarker, format string) ([]byte, error) {
+	files := make([]jsonFile, 0, len(markers))
+	for _, m := range markers {
+		content, encoding := textContent(m.Content, isUTF8)
+		files = append(files, jsonFile{Filename: m.Filename, Content: content, Encoding: encoding, Size: int64(len(m.Content)), Attrs: m.Attrs})
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if format == JSONL {
+		for _, f := range files {
+			if err := enc.Encode(f); err != nil {
+				return nil, err
+			}
+		}
+		return b.Bytes(), nil
+	}
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(files); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
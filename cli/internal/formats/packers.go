@@ -0,0 +1,261 @@
+package formats
+
+import (
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// Formats of other repository packers, which lookatni reads but does not write.
+// The XML of files-to-prompt --cxml is the XML format.
+const (
+	Repomix               = "repomix" // repomix --style xml, its default
+	RepomixMarkdown       = "repomix-markdown"
+	RepomixPlain          = "repomix-plain"
+	Gitingest             = "gitingest"
+	FilesToPrompt         = "files-to-prompt"
+	FilesToPromptMarkdown = "files-to-prompt-markdown"
+)
+
+// packer splits the output of a repository packer into files. Summaries,
+// directory trees and other sections around the files are dropped.
+type packer struct {
+	name  string
+	split func(lines []string) []parser.ParsedMarker
+}
+
+var packers = []packer{
+	{Repomix, splitRepomixXML},
+	{RepomixMarkdown, splitFencedHeadings("## File: ")},
+	{RepomixPlain, splitRuled("File: ")},
+	{Gitingest, splitRuled("FILE: ")},
+	{FilesToPrompt, splitDashed},
+	{FilesToPromptMarkdown, splitFencedPaths},
+}
+
+func lookupPacker(name string) (packer, bool) {
+	for _, p := range packers {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return packer{}, false
+}
+
+// detectPacker returns the packer format of data and how sure the choice is.
+// Archives with lookatni frontmatter or a leading PROJECT_INFO marker are never
+// packer output, and otherwise the packer must find more files than the best
+// marker dialect finds markers.
+func detectPacker(data []byte) (string, float64) {
+	lines := strings.Split(string(data), "\n")
+	best, top := "", 0
+	for _, p := range packers {
+		if n := len(p.split(lines)); n > top {
+			best, top = p.name, n
+		}
+	}
+	if top == 0 {
+		return "", 0
+	}
+	detected, err := metadata.Detect(data)
+	if err != nil {
+		return "", 0
+	}
+	if detected.Source != metadata.FromDefault {
+		if detected.Source == metadata.FromFrontmatter || detected.Markers >= top {
+			return "", 0
+		}
+		if m := parser.ParseContent(data, detected.Dialect).Markers; len(m) > 0 && m[0].Filename == parser.ProjectInfoMarker {
+			return "", 0
+		}
+	}
+	// The same measure as for dialects: the lead over the markers found, and
+	// the amount of evidence
+	t, second := float64(top), float64(detected.Markers)
+	return best, (t - second) / t * (t / (t + 1))
+}
+
+// splitRepomixXML reads <file path="..."> elements, whose content repomix
+// writes unescaped. A file runs to the last </file> before the next file.
+func splitRepomixXML(lines []string) []parser.ParsedMarker {
+	var files []parser.ParsedMarker
+	for i := 0; i < len(lines); i++ {
+		path, ok := repomixFile(lines[i])
+		if !ok {
+			continue
+		}
+		next := i + 1
+		for next < len(lines) && trimCR(lines[next]) != "</files>" {
+			if _, ok := repomixFile(lines[next]); ok {
+				break
+			}
+			next++
+		}
+		end := next - 1
+		for end > i && trimCR(lines[end]) != "</file>" {
+			end--
+		}
+		if end == i {
+			continue
+		}
+		files = append(files, parser.ParsedMarker{Filename: path, Content: strings.Join(lines[i+1:end], "\n"), StartLine: i + 1, EndLine: end + 1})
+		i = end
+	}
+	return files
+}
+
+func repomixFile(line string) (string, bool) {
+	path, ok := strings.CutPrefix(trimCR(line), `<file path="`)
+	if !ok {
+		return "", false
+	}
+	path, ok = strings.CutSuffix(path, `">`)
+	return path, ok && path != ""
+}
+
+// splitRuled reads files headed by a label line between two rules of "=",
+// as repomix writes "File: path" and gitingest "FILE: path". Ruled section
+// headings with other text, such as gitingest symlinks, end the file before.
+func splitRuled(label string) func([]string) []parser.ParsedMarker {
+	return func(lines []string) []parser.ParsedMarker {
+		var files []parser.ParsedMarker
+		var current *parser.ParsedMarker
+		var body []string
+		flush := func(end int) {
+			if current != nil {
+				current.Content, current.EndLine = strings.Join(body, "\n"), end
+				files = append(files, *current)
+			}
+			current, body = nil, nil
+		}
+		for i := 0; i < len(lines); i++ {
+			if i+2 < len(lines) && isRule(lines[i]) && isRule(lines[i+2]) && trimCR(lines[i+1]) != "" {
+				flush(i)
+				if path, ok := strings.CutPrefix(trimCR(lines[i+1]), label); ok && strings.TrimSpace(path) != "" {
+					current = &parser.ParsedMarker{Filename: strings.TrimSpace(path), StartLine: i + 2}
+				}
+				i += 2
+				continue
+			}
+			if current != nil {
+				body = append(body, lines[i])
+			}
+		}
+		flush(len(lines))
+		return files
+	}
+}
+
+func isRule(line string) bool {
+	line = trimCR(line)
+	return len(line) >= 3 && strings.Trim(line, "=") == ""
+}
+
+// splitFencedHeadings reads files headed by prefix and a path over a fenced
+// code block, as repomix writes "## File: path".
+func splitFencedHeadings(prefix string) func([]string) []parser.ParsedMarker {
+	return func(lines []string) []parser.ParsedMarker {
+		var files []parser.ParsedMarker
+		for i := 0; i < len(lines); i++ {
+			path, ok := strings.CutPrefix(trimCR(lines[i]), prefix)
+			if !ok || strings.TrimSpace(path) == "" {
+				continue
+			}
+			m, block := fencedFile(lines, i, strings.TrimSpace(path))
+			if block > 0 {
+				files = append(files, m)
+				i += block
+			}
+		}
+		return files
+	}
+}
+
+// splitFencedPaths reads files-to-prompt --markdown: a bare path line over a
+// fenced code block for every file, and nothing else.
+func splitFencedPaths(lines []string) []parser.ParsedMarker {
+	var files []parser.ParsedMarker
+	for i := 0; i < len(lines); i++ {
+		line := trimCR(lines[i])
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !looksLikePath(line) {
+			return nil
+		}
+		m, block := fencedFile(lines, i, line)
+		if block == 0 {
+			return nil
+		}
+		files = append(files, m)
+		i += block
+	}
+	return files
+}
+
+// fencedFile reads the fenced block after the heading at lines[i] and returns
+// it with the number of lines it spans, 0 when there is none.
+func fencedFile(lines []string, i int, path string) (parser.ParsedMarker, int) {
+	fenced, _ := metadata.LookupDialect("fenced")
+	rest := make([]string, len(lines)-i-1)
+	for k, line := range lines[i+1:] {
+		rest[k] = trimCR(line)
+	}
+	block := metadata.BlockLines(fenced, rest)
+	if block == 0 {
+		return parser.ParsedMarker{}, 0
+	}
+	content := metadata.UnwrapContent(fenced, strings.Join(rest[:block], "\n"))
+	return parser.ParsedMarker{Filename: path, Content: content, StartLine: i + 1, EndLine: i + 1 + block}, block
+}
+
+// splitDashed reads files-to-prompt plain output: every file is its path, a
+// "---" line, the content and a closing "---". A "---" line in content closes
+// the file only when the end or another path and "---" follow it.
+func splitDashed(lines []string) []parser.ParsedMarker {
+	isHeader := func(i int) bool {
+		return i+1 < len(lines) && looksLikePath(trimCR(lines[i])) && trimCR(lines[i+1]) == "---"
+	}
+	var files []parser.ParsedMarker
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if !isHeader(i) {
+			return nil
+		}
+		end := -1
+		for k := i + 2; k < len(lines) && end < 0; k++ {
+			if trimCR(lines[k]) == "---" && (blankFrom(lines, k+1) || isHeader(k+1)) {
+				end = k
+			}
+		}
+		if end < 0 {
+			return nil
+		}
+		files = append(files, parser.ParsedMarker{Filename: trimCR(lines[i]), Content: strings.Join(lines[i+2:end], "\n"), StartLine: i + 1, EndLine: end + 1})
+		i = end
+	}
+	return files
+}
+
+func blankFrom(lines []string, i int) bool {
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikePath accepts a line that could be a relative or absolute file
+// path, rejecting prose and "key: value" lines.
+func looksLikePath(line string) bool {
+	return line != "" && len(line) <= 4096 && strings.TrimSpace(line) == line &&
+		!strings.Contains(line, ": ") && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "```")
+}
+
+func trimCR(line string) string {
+	return strings.TrimRight(line, "\r")
+}
@@ -0,0 +1,227 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// xmlDocument is one file in the XML format:
+//
+//	<document index="1">
+//	<source>path/to/file.go</source>
+//	<document_content>
+//	<![CDATA[...]]>
+//	</document_content>
+//	</document>
+//
+// Marker attributes other than the index become attributes of the element.
+type xmlDocument struct {
+	Index    string     `xml:"index,attr"`
+	Encoding string     `xml:"encoding,attr"`
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Source   string     `xml:"source"`
+	Content  string     `xml:"document_content"`
+}
+
+// isXMLDocuments reports whether data starts, after any XML declaration, with
+// a <documents> or <document> element.
+func isXMLDocuments(data []byte) bool {
+	if bytes.HasPrefix(data, []byte("<?xml")) {
+		end := bytes.Index(data, []byte("?>"))
+		if end < 0 {
+			return false
+		}
+		data = bytes.TrimLeft(data[end+2:], " \t\r\n")
+	}
+	return bytes.HasPrefix(data, []byte("<documents>")) || bytes.HasPrefix(data, []byte("<document>")) ||
+		bytes.HasPrefix(data, []byte("<documents ")) || bytes.HasPrefix(data, []byte("<document "))
+}
+
+// decodeXML reads every <document> element, with or without a <documents> root.
+// Documents written by hand or by other tools often hold content that is not
+// escaped; when data is not well-formed they are read as raw text instead.
+func decodeXML(data []byte) ([]parser.ParsedMarker, error) {
+	markers, err := decodeWellFormedXML(data)
+	var syntax *xml.SyntaxError
+	if errors.As(err, &syntax) {
+		return decodeRawXML(data)
+	}
+	return markers, err
+}
+
+func decodeWellFormedXML(data []byte) ([]parser.ParsedMarker, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var markers []parser.ParsedMarker
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return markers, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "document" {
+			continue
+		}
+		line, _ := dec.InputPos()
+		var doc xmlDocument
+		if err := dec.DecodeElement(&doc, &start); err != nil {
+			return nil, err
+		}
+
+		// The writer puts the content on the line after the opening tag
+		content := strings.TrimPrefix(strings.TrimPrefix(doc.Content, "\r"), "\n")
+		if content, err = decodeContent(content, doc.Encoding); err != nil {
+			return nil, fmt.Errorf("document %s: %w", doc.Source, err)
+		}
+		m := parser.ParsedMarker{Filename: strings.TrimSpace(doc.Source), Content: content, StartLine: line}
+		for _, attr := range doc.Attrs {
+			if m.Attrs == nil {
+				m.Attrs = map[string]string{}
+			}
+			m.Attrs[attr.Name.Local] = attr.Value
+		}
+		m.EndLine, _ = dec.InputPos()
+		markers = append(markers, m)
+	}
+}
+
+// decodeRawXML reads <document> elements whose content is taken verbatim up to
+// the closing </document_content>, unwrapping CDATA sections. Only the source
+// and the encoding attribute are read from the markup.
+func decodeRawXML(data []byte) ([]parser.ParsedMarker, error) {
+	text := string(data)
+	var markers []parser.ParsedMarker
+	for pos := 0; ; {
+		start := nextDocument(text, pos)
+		if start < 0 {
+			return markers, nil
+		}
+		end := strings.Index(text[start:], "</document>")
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: <document> is not closed", lineAt(data, start))
+		}
+		doc := text[start : start+end]
+		m := parser.ParsedMarker{StartLine: lineAt(data, start), EndLine: lineAt(data, start+end)}
+		if source, ok := between(doc, "<source>", "</source>"); ok {
+			m.Filename = strings.TrimSpace(html.UnescapeString(source))
+		}
+		content, _ := between(doc, "<document_content>", "</document_content>")
+		content = strings.TrimPrefix(strings.TrimPrefix(unwrapCDATA(content), "\r"), "\n")
+		encoding := ""
+		if tag, _, ok := strings.Cut(doc, ">"); ok && strings.Contains(tag, `encoding="`+Base64+`"`) {
+			encoding = Base64
+		}
+		var err error
+		if m.Content, err = decodeContent(content, encoding); err != nil {
+			return nil, fmt.Errorf("document %s: %w", m.Filename, err)
+		}
+		markers = append(markers, m)
+		pos = start + end + len("</document>")
+	}
+}
+
+// nextDocument returns the offset of the next <document> start tag in text.
+func nextDocument(text string, from int) int {
+	for i := from; ; {
+		j := strings.Index(text[i:], "<document")
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if rest := text[i+len("<document"):]; strings.HasPrefix(rest, ">") || strings.HasPrefix(rest, " ") {
+			return i
+		}
+		i += len("<document")
+	}
+}
+
+// between returns the text of s between the first open and the following close.
+func between(s, open, close string) (string, bool) {
+	_, rest, ok := strings.Cut(s, open)
+	if !ok {
+		return "", false
+	}
+	inner, _, ok := strings.Cut(rest, close)
+	return inner, ok
+}
+
+// unwrapCDATA removes the CDATA delimiters around content, joining sections
+// split to carry "]]>".
+func unwrapCDATA(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "<![CDATA[") || !strings.HasSuffix(trimmed, "]]>") {
+		return content
+	}
+	inner := trimmed[len("<![CDATA[") : len(trimmed)-len("]]>")]
+	return strings.ReplaceAll(inner, "]]><![CDATA[", "")
+}
+
+func lineAt(data []byte, offset int) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// encodeXML writes markers as <document> elements in a <documents> root, with
+// content in CDATA sections. Content XML cannot carry, such as control
+// characters or carriage returns, which parsers normalize, is base64-encoded.
+func encodeXML(markers []parser.ParsedMarker) []byte {
+	var b bytes.Buffer
+	b.WriteString("<documents>\n")
+	for i, m := range markers {
+		content, encoding := textContent(m.Content, isXMLText)
+		fmt.Fprintf(&b, "<document index=\"%d\"", i+1)
+		if encoding != "" {
+			fmt.Fprintf(&b, " encoding=\"%s\"", encoding)
+		}
+		names := make([]string, 0, len(m.Attrs))
+		for name := range m.Attrs {
+			if name != "index" && name != "encoding" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, " %s=\"%s\"", name, escapeXML(m.Attrs[name]))
+		}
+		b.WriteString(">\n<source>" + escapeXML(m.Filename) + "</source>\n")
+		b.WriteString("<document_content>\n<![CDATA[")
+		b.WriteString(strings.ReplaceAll(content, "]]>", "]]]]><![CDATA[>"))
+		b.WriteString("]]>\n</document_content>\n</document>\n")
+	}
+	b.WriteString("</documents>\n")
+	return b.Bytes()
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// isXMLText reports whether s survives an XML parser unchanged: valid UTF-8
+// of XML 1.0 characters, without carriage returns.
+func isXMLText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n':
+		case r < 0x20 || r == 0xFFFE || r == 0xFFFF:
+			return false
+		case r >= 0xD800 && r <= 0xDFFF:
+			return false
+		}
+	}
+	return true
+}
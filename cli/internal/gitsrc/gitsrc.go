@@ -0,0 +1,249 @@
+// Package gitsrc builds archives from the files git knows about, either the
+// tracked files of a working tree or the tree of a commit.
+package gitsrc
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
+)
+
+// File is one regular file taken from git.
+type File struct {
+	Name    string
+	Content []byte
+	Mode    fs.FileMode
+}
+
+// Snapshot is the set of files selected from a repository.
+type Snapshot struct {
+	// Commit is the full hash of the commit the files belong to; it is empty
+	// for a repository without commits.
+	Commit string
+	Files  []File
+	// Skipped lists files that were not taken, with the reason.
+	Skipped []string
+}
+
+// Tracked returns the files `git ls-files` lists below dir, read from the
+// working tree. Files with the export-ignore attribute are left out, as
+// `git archive` would.
+func Tracked(dir string) (*Snapshot, error) {
+	out, err := run(dir, nil, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	names := splitNul(out)
+	ignored, err := exportIgnored(dir, names)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{}
+	if commit, err := run(dir, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		s.Commit = strings.TrimSpace(string(commit))
+	}
+	for _, name := range names {
+		if ignored[name] {
+			continue
+		}
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Lstat(full)
+		if err != nil {
+			s.Skipped = append(s.Skipped, name+" (missing from the working tree)")
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			s.Skipped = append(s.Skipped, name+" (not a regular file)")
+			continue
+		}
+		content, err := os.ReadFile(full)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		s.add(name, content, info.Mode())
+	}
+	return s, nil
+}
+
+// AtRevision returns the files of rev's tree below dir, as `git archive` exports them.
+func AtRevision(dir, rev string) (*Snapshot, error) {
+	commit, err := run(dir, nil, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+	out, err := run(dir, nil, "archive", "--format=tar", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{Commit: strings.TrimSpace(string(commit))}
+	tr := tar.NewReader(bytes.NewReader(out))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git archive output: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+			}
+			s.add(hdr.Name, content, fs.FileMode(hdr.Mode))
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+		default:
+			s.Skipped = append(s.Skipped, hdr.Name+" (not a regular file)")
+		}
+	}
+	return s, nil
+}
+
+// add records a file, skipping binary content, which archives cannot carry.
+func (s *Snapshot) add(name string, content []byte, mode fs.FileMode) {
+	if bytes.IndexByte(content, 0) >= 0 {
+		s.Skipped = append(s.Skipped, name+" (binary)")
+		return
+	}
+	s.Files = append(s.Files, File{Name: name, Content: content, Mode: mode.Perm()})
+}
+
+// Generate writes the snapshot of dir to outputFile in the given dialect: the
+// tracked files when rev is empty, otherwise the tree of rev. The commit is
+// recorded in PROJECT_INFO, along with the mode of executable files.
+func Generate(dir, rev, outputFile string, config metadata.MarkerConfig) (*parser.GenerateResults, error) {
+	var s *Snapshot
+	var err error
+	if rev == "" {
+		s, err = Tracked(dir)
+	} else {
+		s, err = AtRevision(dir, rev)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := archive.New(config)
+	if err != nil {
+		return nil, err
+	}
+	result := parser.NewGenerateResults()
+	modes := map[string]string{}
+	for _, f := range s.Files {
+		if _, err := a.Set(f.Name, string(f.Content)); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Skipped %s: %v", f.Name, err))
+			continue
+		}
+		// git only distinguishes executable files, whatever the umask left behind
+		if f.Mode&0o111 != 0 {
+			modes[f.Name] = "0755"
+		}
+	}
+	for _, skipped := range s.Skipped {
+		result.Errors = append(result.Errors, "Skipped "+skipped)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	info := parser.ProjectInfoHeader(filepath.Base(abs), dir, 0)
+	if s.Commit != "" {
+		info += fmt.Sprintf("%s: %s\n", parser.CommitKey, s.Commit)
+	}
+	info += parser.FormatPathValues(parser.ModeKey, modes)
+	a.SetProjectInfo(info)
+
+	if err := a.WriteFile(outputFile); err != nil {
+		return nil, err
+	}
+	data := a.Bytes()
+	for _, e := range a.Files() {
+		result.FileTokens[e.Name] = tokens.Count(e.Raw)
+	}
+	result.TotalFiles = len(a.Files())
+	result.TotalBytes = int64(len(data))
+	result.TotalTokens = tokens.Count(string(data))
+	return result, nil
+}
+
+// exportIgnored returns the names that carry the export-ignore attribute,
+// themselves or through one of their parent directories.
+func exportIgnored(dir string, names []string) (map[string]bool, error) {
+	ignored := map[string]bool{}
+	if len(names) == 0 {
+		return ignored, nil
+	}
+	// Attributes do not propagate to directory contents, so ask about every
+	// parent directory as well
+	var paths []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		for p := name; p != "." && !seen[p]; p = path.Dir(p) {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	out, err := run(dir, []byte(strings.Join(paths, "\x00")+"\x00"), "check-attr", "-z", "--stdin", "export-ignore")
+	if err != nil {
+		return nil, err
+	}
+	// Output is a sequence of path, attribute, value triples
+	set := map[string]bool{}
+	fields := splitNul(out)
+	for i := 0; i+2 < len(fields); i += 3 {
+		if value := fields[i+2]; value != "unspecified" && value != "unset" {
+			set[fields[i]] = true
+		}
+	}
+	for _, name := range names {
+		for p := name; p != "."; p = path.Dir(p) {
+			if set[p] {
+				ignored[name] = true
+				break
+			}
+		}
+	}
+	return ignored, nil
+}
+
+// run executes git in dir and returns its standard output.
+func run(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+func splitNul(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\x00")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\x00")
+}
@@ -0,0 +1,193 @@
+// Package ignore implements gitignore-style path matching for .gitignore and
+// .lookatniignore files.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileNames are the ignore files honoured in every directory.
+var FileNames = []string{".gitignore", ".lookatniignore"}
+
+// DefaultPatterns are always applied: version control metadata is never touched.
+var DefaultPatterns = []string{".git/", ".hg/", ".svn/"}
+
+type rule struct {
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher decides whether slash-separated paths relative to a root are ignored.
+// Later rules take precedence over earlier ones, as in git.
+type Matcher struct {
+	rules []rule
+}
+
+// New creates a Matcher preloaded with DefaultPatterns.
+func New() *Matcher {
+	m := &Matcher{}
+	m.AddPatterns("", DefaultPatterns)
+	return m
+}
+
+// AddPatterns adds gitignore patterns scoped to base, a slash-separated directory
+// relative to the root ("" for the root itself).
+func (m *Matcher) AddPatterns(base string, patterns []string) {
+	for _, p := range patterns {
+		if r, ok := compile(base, p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// LoadDir reads the ignore files found in dir, a directory below root, and
+// scopes their patterns to it. Missing files are skipped.
+func (m *Matcher) LoadDir(root, dir string) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return err
+	}
+	base := filepath.ToSlash(rel)
+	if base == "." {
+		base = ""
+	}
+	for _, name := range FileNames {
+		patterns, err := readPatterns(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		m.AddPatterns(base, patterns)
+	}
+	return nil
+}
+
+// Match reports whether rel is ignored, either directly or through a parent directory.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	rel = strings.Trim(filepath.ToSlash(rel), "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.matchOne(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.matchOne(rel, isDir)
+}
+
+func (m *Matcher) matchOne(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, r.base+"/")
+		}
+		if r.re.MatchString(target) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func readPatterns(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+// compile converts one gitignore line into a rule.
+func compile(base, pattern string) (rule, bool) {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return rule{}, false
+	}
+
+	r := rule{base: strings.Trim(base, "/")}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return rule{}, false
+	}
+
+	// A separator at the start or in the middle anchors the pattern to base
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(pattern[i+1:], ']'); end >= 0 {
+				class := pattern[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				re.WriteString("[" + class + "]")
+				i += end + 1
+			} else {
+				re.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = compiled
+	return r, true
+}
@@ -0,0 +1,133 @@
+package merge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/adaptive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// ExtractWithMerge extracts an archive over a working tree without losing local
+// changes. For each entry it compares the local file and the archive version with
+// the base recorded by generate --record-base: one-sided changes are applied as is, and
+// changes on both sides are merged line by line, leaving conflict markers where
+// they overlap.
+func ExtractWithMerge(markedFile, outputDir string, options parser.ExtractOptions) (*parser.ExtractResults, error) {
+	results, _, err := adaptive.New().ParseMarkedFile(markedFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse marked file: %w", err)
+	}
+	baseHashes := results.ProjectInfo().BaseHashes()
+	excerpts := results.ProjectInfo().Excerpts()
+
+	store, storeErr := DefaultBaseStore()
+
+	out := &parser.ExtractResults{
+		Success:        true,
+		ExtractedFiles: []string{},
+		MergedFiles:    []string{},
+		Conflicts:      []string{},
+		Errors:         []string{},
+	}
+	for _, parseErr := range results.Errors {
+		out.Errors = append(out.Errors, fmt.Sprintf("Line %d: %s", parseErr.Line, parseErr.Message))
+	}
+
+	for _, marker := range results.Markers {
+		if marker.Filename == parser.ProjectInfoMarker {
+			continue
+		}
+		if excerpts[marker.Filename] {
+			out.Errors = append(out.Errors, fmt.Sprintf("Skipped excerpt (diff hunks, not file content): %s", marker.Filename))
+			continue
+		}
+		if err := parser.CheckName(marker.Filename); err != nil {
+			out.Errors = append(out.Errors, err.Error())
+			out.Success = false
+			continue
+		}
+		outputPath := filepath.Join(outputDir, marker.Filename)
+		remote := marker.Content
+
+		localData, err := os.ReadFile(outputPath)
+		if os.IsNotExist(err) {
+			if writeEntry(out, outputPath, remote, options) {
+				out.ExtractedFiles = append(out.ExtractedFiles, outputPath)
+			}
+			continue
+		}
+		if err != nil {
+			out.Errors = append(out.Errors, fmt.Sprintf("Failed to read %s: %v", outputPath, err))
+			out.Success = false
+			continue
+		}
+
+		local := string(localData)
+		if strings.TrimRight(local, "\n") == remote {
+			continue
+		}
+
+		baseHash := baseHashes[filepath.ToSlash(marker.Filename)]
+		switch {
+		case baseHash != "" && parser.ContentHash(local) == baseHash:
+			// Only the archive changed
+			if writeEntry(out, outputPath, remote, options) {
+				out.ExtractedFiles = append(out.ExtractedFiles, outputPath)
+			}
+		case baseHash != "" && parser.ContentHash(remote) == baseHash:
+			// Only the local file changed: keep it
+		default:
+			// Without the base every difference is a conflict: both versions
+			// are kept in one block for the user to resolve
+			base, ok := "", false
+			switch {
+			case baseHash == "":
+				out.Errors = append(out.Errors, fmt.Sprintf("No merge base recorded for %s in the archive (generate it with --record-base), merging without common ancestor", outputPath))
+			case storeErr != nil:
+				out.Errors = append(out.Errors, fmt.Sprintf("Merge base store unavailable for %s (%v), merging without common ancestor", outputPath, storeErr))
+			default:
+				if base, ok = store.Get(baseHash); !ok {
+					out.Errors = append(out.Errors, fmt.Sprintf("Merge base %s of %s is not in %s, merging without common ancestor", baseHash, outputPath, store.Dir))
+				}
+			}
+
+			merged, conflicts := Merge3(base, local, remote, DefaultLabels)
+			if strings.HasSuffix(local, "\n") {
+				merged += "\n"
+			}
+			if !writeEntry(out, outputPath, merged, options) {
+				continue
+			}
+			out.MergedFiles = append(out.MergedFiles, outputPath)
+			if conflicts > 0 {
+				out.Conflicts = append(out.Conflicts, fmt.Sprintf("%s (%d conflicts)", outputPath, conflicts))
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// writeEntry writes content to path honouring CreateDirs and DryRun.
+func writeEntry(out *parser.ExtractResults, path, content string, options parser.ExtractOptions) bool {
+	if options.DryRun {
+		return true
+	}
+	if options.CreateDirs {
+		dir := filepath.Dir(path)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			out.Errors = append(out.Errors, fmt.Sprintf("Failed to create directory %s: %v", dir, err))
+			out.Success = false
+			return false
+		}
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		out.Errors = append(out.Errors, fmt.Sprintf("Failed to write %s: %v", path, err))
+		out.Success = false
+		return false
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+// Package merge provides three-way merging for extracting archives over modified trees.
+package merge
+
+import (
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/diff"
+)
+
+// Labels name the sides of a conflict in the emitted conflict markers.
+type Labels struct {
+	Local  string
+	Base   string
+	Remote string
+}
+
+// DefaultLabels are used when extracting an archive over a working tree.
+var DefaultLabels = Labels{Local: "local", Base: "base", Remote: "archive"}
+
+// Merge3 merges local and remote changes made to base, line by line, in the style
+// of diff3. Overlapping changes are written as conflict blocks. It returns the
+// merged text and the number of conflicts.
+func Merge3(base, local, remote string, labels Labels) (string, int) {
+	b := splitLines(base)
+	l := splitLines(local)
+	r := splitLines(remote)
+
+	toLocal := matchIndex(diff.LineMatches(b, l))
+	toRemote := matchIndex(diff.LineMatches(b, r))
+
+	var out []string
+	conflicts := 0
+	i, j, k := 0, 0, 0
+	for {
+		// Copy lines unchanged on both sides
+		for i < len(b) {
+			lj, lok := toLocal[i]
+			rk, rok := toRemote[i]
+			if !lok || !rok || lj != j || rk != k {
+				break
+			}
+			out = append(out, b[i])
+			i++
+			j++
+			k++
+		}
+
+		// Find the next base line kept by both sides
+		ni, nj, nk := len(b), len(l), len(r)
+		for n := i; n < len(b); n++ {
+			lj, lok := toLocal[n]
+			rk, rok := toRemote[n]
+			if lok && rok && lj >= j && rk >= k {
+				ni, nj, nk = n, lj, rk
+				break
+			}
+		}
+
+		baseChunk := b[i:ni]
+		localChunk := l[j:nj]
+		remoteChunk := r[k:nk]
+
+		switch {
+		case equalLines(localChunk, baseChunk):
+			out = append(out, remoteChunk...)
+		case equalLines(remoteChunk, baseChunk), equalLines(localChunk, remoteChunk):
+			out = append(out, localChunk...)
+		default:
+			conflicts++
+			out = append(out, "<<<<<<< "+labels.Local)
+			out = append(out, localChunk...)
+			out = append(out, "||||||| "+labels.Base)
+			out = append(out, baseChunk...)
+			out = append(out, "=======")
+			out = append(out, remoteChunk...)
+			out = append(out, ">>>>>>> "+labels.Remote)
+		}
+
+		if ni == len(b) {
+			break
+		}
+		i, j, k = ni, nj, nk
+	}
+
+	return strings.Join(out, "\n"), conflicts
+}
+
+func matchIndex(matches []diff.Match) map[int]int {
+	index := make(map[int]int, len(matches))
+	for _, m := range matches {
+		index[m.A] = m.B
+	}
+	return index
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
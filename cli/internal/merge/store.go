@@ -0,0 +1,169 @@
+package merge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// BaseStore is a content-addressed cache of file contents recorded at generation
+// time, so extraction can recover the common ancestor of a three-way merge.
+type BaseStore struct {
+	Dir string
+}
+
+// MaxBaseAge is how long an object stays in the store after it was last
+// recorded. Archives generated longer ago merge without a common ancestor.
+const MaxBaseAge = 90 * 24 * time.Hour
+
+// pruneInterval is the least time between two prunes of the store.
+const pruneInterval = 24 * time.Hour
+
+// DefaultBaseStore returns the store under $LOOKATNI_CACHE_DIR, or the user cache directory.
+func DefaultBaseStore() (*BaseStore, error) {
+	root := os.Getenv("LOOKATNI_CACHE_DIR")
+	if root == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate cache directory: %w", err)
+		}
+		root = filepath.Join(cacheDir, "lookatni")
+	}
+	return &BaseStore{Dir: filepath.Join(root, "objects")}, nil
+}
+
+// Put stores content under its parser.ContentHash and returns the hash.
+func (s *BaseStore) Put(content string) (string, error) {
+	hash := parser.ContentHash(content)
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		// Recording an object again keeps it from being pruned
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create object directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.TrimRight(content, "\n")), 0o644); err != nil {
+		return "", fmt.Errorf("failed to store object %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// Get returns the content stored under hash.
+func (s *BaseStore) Get(hash string) (string, bool) {
+	if len(hash) < 3 {
+		return "", false
+	}
+	data, err := os.ReadFile(s.path(hash))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Prune removes the objects last recorded before maxAge ago and returns how
+// many it removed.
+func (s *BaseStore) Prune(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	shards, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read object directory: %w", err)
+	}
+	removed := 0
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.Dir, shard.Name())
+		objects, err := os.ReadDir(dir)
+		if err != nil {
+			return removed, fmt.Errorf("failed to read object directory: %w", err)
+		}
+		kept := len(objects)
+		for _, object := range objects {
+			info, err := object.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, object.Name())); err != nil {
+				return removed, fmt.Errorf("failed to prune object %s: %w", shard.Name()+object.Name(), err)
+			}
+			removed++
+			kept--
+		}
+		if kept == 0 {
+			os.Remove(dir)
+		}
+	}
+	return removed, nil
+}
+
+// pruneIfDue prunes the store when it was last pruned pruneInterval ago or
+// more, so that generating does not walk the whole store every time.
+func (s *BaseStore) pruneIfDue() error {
+	stamp := filepath.Join(s.Dir, ".pruned")
+	if info, err := os.Stat(stamp); err == nil && time.Since(info.ModTime()) < pruneInterval {
+		return nil
+	}
+	if _, err := s.Prune(MaxBaseAge); err != nil {
+		return err
+	}
+	return os.WriteFile(stamp, nil, 0o644)
+}
+
+func (s *BaseStore) path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash[2:])
+}
+
+// RecordBases makes an archive record merge bases: every entry is stored as a
+// base and its hash written to the archive's PROJECT_INFO section, where
+// extract --merge finds it. Objects older than MaxBaseAge are pruned. It
+// returns the number of entries recorded.
+func RecordBases(markedFile string) (int, error) {
+	return recordBases(markedFile, true)
+}
+
+// UpdateBases stores the entries of an archive that records merge bases, after
+// an update or edit changed them; other archives are left alone. It returns the
+// number of entries stored.
+func UpdateBases(markedFile string) (int, error) {
+	return recordBases(markedFile, false)
+}
+
+func recordBases(markedFile string, start bool) (int, error) {
+	a, err := archive.Load(markedFile)
+	if err != nil {
+		return 0, err
+	}
+	if !start && len(a.Info().BaseHashes()) == 0 {
+		return 0, nil
+	}
+	store, err := DefaultBaseStore()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, e := range a.Files() {
+		if _, err := store.Put(e.Content()); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if start {
+		a.RecordBaseHashes()
+		if err := a.WriteFile(markedFile); err != nil {
+			return count, err
+		}
+	}
+	return count, store.pruneIfDue()
+}
@@ -4,6 +4,8 @@ package metadata
 import (
 	"bytes"
 	"fmt"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -12,11 +14,26 @@ import (
 
 // MarkerConfig defines how file markers should be formatted.
 type MarkerConfig struct {
+	// Dialect names a registered dialect implemented in Go, such as
+	// "fenced"; the other fields then only describe its marker line.
+	Dialect string `yaml:"dialect,omitempty"`
 	Version string `yaml:"version,omitempty"`
 	Pattern string `yaml:"pattern,omitempty"`
 	Start   string `yaml:"start,omitempty"`
 	End     string `yaml:"end,omitempty"`
 	Format  string `yaml:"format,omitempty"`
+	// Languages gives files of some languages their own marker pattern, so
+	// that each marker line is a comment in the language of the file below
+	// it. Other files use Pattern or start/end/format.
+	Languages []LanguagePattern `yaml:"languages,omitempty"`
+}
+
+// LanguagePattern is the marker pattern of files with the given extensions.
+type LanguagePattern struct {
+	Pattern string `yaml:"pattern"`
+	// Extensions are matched without the dot and case-insensitively against
+	// the file extension, or against the whole base name (e.g. "makefile").
+	Extensions []string `yaml:"extensions,flow"`
 }
 
 // LookAtniMetadata represents the frontmatter metadata in a marked file.
@@ -31,6 +48,9 @@ type MarkerPreset struct {
 	Config      MarkerConfig
 }
 
+// DefaultFormat is the marker template used when a config sets only start and end.
+const DefaultFormat = "{start} {filename} {end}"
+
 // GetDefaultConfig returns the classic ASCII 28 marker configuration.
 func GetDefaultConfig() MarkerConfig {
 	return MarkerConfig{
@@ -85,12 +105,38 @@ func GetPresetConfigs() map[string]MarkerPreset {
 				Format:  "{start} {filename} {end}",
 			},
 		},
+		"lang": {
+			Name:        "Language Comments",
+			Description: "Markers written as a comment in each file's language",
+			Config: MarkerConfig{
+				Version: "2.0",
+				Pattern: "// FILE: {filename}",
+				Languages: []LanguagePattern{
+					{Pattern: "# FILE: {filename}", Extensions: []string{"py", "sh", "bash", "zsh", "rb", "pl", "r", "yaml", "yml", "toml", "conf", "cfg", "ps1", "tf", "jl", "ex", "exs", "nim", "awk", "mk", "cmake", "makefile", "dockerfile", "gitignore", "dockerignore", "env"}},
+					{Pattern: "-- FILE: {filename}", Extensions: []string{"sql", "lua", "hs", "elm", "ada", "adb", "ads"}},
+					{Pattern: "<!-- FILE: {filename} -->", Extensions: []string{"html", "htm", "xhtml", "xml", "svg", "md", "markdown", "vue"}},
+					{Pattern: "/* FILE: {filename} */", Extensions: []string{"css"}},
+					{Pattern: "; FILE: {filename}", Extensions: []string{"ini", "clj", "cljs", "lisp", "el", "scm", "asm"}},
+					{Pattern: "% FILE: {filename}", Extensions: []string{"tex", "erl", "sty"}},
+				},
+			},
+		},
+		"fenced": {
+			Name:        "Markdown Fenced Code",
+			Description: "A ### path heading over a fenced code block tagged with the file's language",
+			Config:      fencedConfig,
+		},
+		"txtar": {
+			Name:        "Go txtar",
+			Description: "-- path -- headers as read by golang.org/x/tools/txtar and Go script tests",
+			Config:      txtarConfig,
+		},
 		"custom": {
 			Name:        "Custom Template",
-			Description: "User-defined marker pattern",
+			Description: "Template showing attribute placeholders; copy and edit it",
 			Config: MarkerConfig{
 				Version: "2.0",
-				Pattern: "{custom}",
+				Pattern: "### [{index}] {filename} ({lang}, {size})",
 			},
 		},
 	}
@@ -98,47 +144,66 @@ func GetPresetConfigs() map[string]MarkerPreset {
 
 // FormatMarker generates a marker string based on the configuration.
 func (mc *MarkerConfig) FormatMarker(filename string) string {
+	return mc.FormatMarkerAttrs(filename, nil)
+}
+
+// FormatMarkerAttrs generates a marker, filling attribute placeholders such as
+// {size} or {index} from attrs; unknown values render as zeros.
+func (mc *MarkerConfig) FormatMarkerAttrs(filename string, attrs map[string]string) string {
+	marker := fillPlaceholders(mc.template(filename), filename, attrs)
+	return strings.ReplaceAll(marker, "{filename}", filename)
+}
+
+// template returns the marker template for filename with {start} and {end}
+// expanded: its language pattern, the pattern, or the format.
+func (mc *MarkerConfig) template(filename string) string {
+	// Use the language pattern of the file if there is one
+	if pattern := mc.LanguagePattern(filename); pattern != "" {
+		return pattern
+	}
+
 	// Use pattern if defined
 	if mc.Pattern != "" {
-		return strings.ReplaceAll(mc.Pattern, "{filename}", filename)
+		return mc.Pattern
 	}
 
 	// Use start/end/format if defined
-	if mc.Format != "" {
-		marker := mc.Format
-		marker = strings.ReplaceAll(marker, "{filename}", filename)
-		marker = strings.ReplaceAll(marker, "{start}", mc.Start)
-		marker = strings.ReplaceAll(marker, "{end}", mc.End)
-		return marker
+	if format := mc.format(); format != "" {
+		return strings.NewReplacer("{start}", mc.Start, "{end}", mc.End).Replace(format)
 	}
 
 	// Fallback to default format
 	defaultConfig := GetDefaultConfig()
-	return defaultConfig.FormatMarker(filename)
+	return defaultConfig.template(filename)
 }
 
-// GenerateRegex creates a regex pattern to match markers based on configuration.
-func (mc *MarkerConfig) GenerateRegex() (*regexp.Regexp, error) {
-	var pattern string
+// LanguagePattern returns the pattern Languages assigns to filename, or "".
+func (mc *MarkerConfig) LanguagePattern(filename string) string {
+	base := strings.ToLower(path.Base(filepath.ToSlash(filename)))
+	ext := strings.TrimPrefix(path.Ext(base), ".")
+	for _, lang := range mc.Languages {
+		for _, e := range lang.Extensions {
+			if e = strings.ToLower(strings.TrimPrefix(e, ".")); e == ext || e == base {
+				return lang.Pattern
+			}
+		}
+	}
+	return ""
+}
 
-	if mc.Pattern != "" {
-		// Escape special regex characters and replace filename placeholder
-		pattern = regexp.QuoteMeta(mc.Pattern)
-		pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta("{filename}"), "(.+?)")
-	} else if mc.Format != "" {
-		// Build pattern from format template
-		formatPattern := regexp.QuoteMeta(mc.Format)
-		formatPattern = strings.ReplaceAll(formatPattern, regexp.QuoteMeta("{start}"), regexp.QuoteMeta(mc.Start))
-		formatPattern = strings.ReplaceAll(formatPattern, regexp.QuoteMeta("{end}"), regexp.QuoteMeta(mc.End))
-		formatPattern = strings.ReplaceAll(formatPattern, regexp.QuoteMeta("{filename}"), "(.+?)")
-		pattern = "^" + formatPattern + "$"
-	} else {
-		// Fallback to default pattern
-		defaultConfig := GetDefaultConfig()
-		return defaultConfig.GenerateRegex()
+// format returns the marker template, defaulting to "{start} {filename} {end}"
+// when only start and end tokens are configured.
+func (mc *MarkerConfig) format() string {
+	if mc.Format == "" && (mc.Start != "" || mc.End != "") {
+		return DefaultFormat
 	}
+	return mc.Format
+}
 
-	return regexp.Compile("^" + pattern + "$")
+// GenerateRegex creates a regex pattern to match markers based on configuration.
+// Markers of files with a language pattern are matched by PatternRegex.
+func (mc *MarkerConfig) GenerateRegex() (*regexp.Regexp, error) {
+	return PatternRegex(mc.template(""))
 }
 
 // ParseFrontmatter extracts metadata from the beginning of a marked file.
@@ -172,12 +237,47 @@ func ParseFrontmatter(content []byte) (*LookAtniMetadata, []byte, error) {
 	// Parse YAML
 	var metadata LookAtniMetadata
 	if err := yaml.Unmarshal(frontmatterBytes, &metadata); err != nil {
-		return nil, content, fmt.Errorf("failed to parse YAML frontmatter: %w", err)
+		lenient, ok := parseFrontmatterLines(lines[1:endIndex])
+		if !ok {
+			return nil, content, fmt.Errorf("failed to parse YAML frontmatter: %w", err)
+		}
+		metadata = lenient
 	}
 
 	return &metadata, remainingContent, nil
 }
 
+// scalarKeys are the MarkerConfig keys holding a single string.
+var scalarKeys = map[string]bool{"dialect": true, "version": true, "pattern": true, "start": true, "end": true, "format": true}
+
+// parseFrontmatterLines reads frontmatter the way the TypeScript core does,
+// taking everything after "key:" as the value, so hand-written frontmatter
+// with unquoted values such as "pattern: <!-- FILE: {filename} -->" is still
+// understood. It quotes those values and decodes the result as YAML, so that
+// every key, languages included, is read as in well-formed frontmatter.
+func parseFrontmatterLines(lines [][]byte) (LookAtniMetadata, bool) {
+	quoted := make([]string, len(lines))
+	for i, line := range lines {
+		text := string(line)
+		body := strings.TrimLeft(text, " ")
+		indent := text[:len(text)-len(body)]
+		if item, ok := strings.CutPrefix(body, "- "); ok {
+			indent, body = indent+"- ", item
+		}
+		key, value, ok := strings.Cut(body, ":")
+		value = strings.TrimSpace(value)
+		if ok && indent != "" && scalarKeys[key] && value != "" && value[0] != '\'' && value[0] != '"' {
+			text = indent + key + ": '" + strings.ReplaceAll(value, "'", "''") + "'"
+		}
+		quoted[i] = text
+	}
+	var meta LookAtniMetadata
+	if err := yaml.Unmarshal([]byte(strings.Join(quoted, "\n")), &meta); err != nil {
+		return meta, false
+	}
+	return meta, true
+}
+
 // GenerateFrontmatter creates YAML frontmatter for a marker configuration.
 func GenerateFrontmatter(config MarkerConfig) ([]byte, error) {
 	metadata := LookAtniMetadata{
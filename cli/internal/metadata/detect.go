@@ -0,0 +1,297 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Detection sources.
+const (
+	FromFrontmatter = "frontmatter"
+	FromContent     = "content"
+	FromDefault     = "default"
+	FromDocument    = "document"
+)
+
+// Detection is the marker dialect chosen for an archive.
+type Detection struct {
+	// Format names the document format of an archive that holds no marker
+	// lines, such as xml or json; the dialect fields are then unset.
+	Format string `json:"format,omitempty"`
+	// Preset names the matching registered dialect; it is empty for a
+	// frontmatter config that matches none.
+	Preset string       `json:"preset"`
+	Config MarkerConfig `json:"config"`
+	// Dialect parses and writes the chosen markers.
+	Dialect Dialect `json:"-"`
+	// Source tells how the dialect was chosen: from frontmatter, by sniffing
+	// content, or by falling back to the default when nothing matched.
+	Source string `json:"source"`
+	// Confidence ranges from 0 to 1; frontmatter is always 1.
+	Confidence float64 `json:"confidence"`
+	// Markers counts marker lines of the chosen dialect.
+	Markers int `json:"markers"`
+	// Scores holds the marker count of every dialect tried by sniffing.
+	Scores map[string]int `json:"scores,omitempty"`
+	// Warning explains a fallback to the default dialect when the sniffed
+	// markers were too ambiguous to trust.
+	Warning string `json:"warning,omitempty"`
+}
+
+// maxMarkerLine bounds the lines considered as marker candidates.
+const maxMarkerLine = 4096
+
+// MinConfidence is the confidence below which sniffed markers are too
+// ambiguous to trust: Detect then falls back to the default dialect with a
+// warning rather than extracting files in a guessed dialect.
+const MinConfidence = 0.25
+
+// Detect chooses the marker dialect of archive content. Frontmatter wins when
+// present. Otherwise every registered dialect is scored against the content,
+// leaving out candidate lines that sit inside another dialect's blocks, such
+// as a marker quoted in a fenced code block. Some markers are decisive: the
+// first PROJECT_INFO marker names the dialect that wrote the archive, and
+// without one, marker lines framed by the FS control character (ASCII 28)
+// appear in no ordinary text. Failing both, the dialect with the most marker
+// lines is chosen unless its lead is too small to trust.
+func Detect(content []byte) (Detection, error) {
+	meta, rest, err := ParseFrontmatter(content)
+	if err != nil {
+		return Detection{}, err
+	}
+	if meta != nil {
+		d, err := DialectFor(meta.LookAtni)
+		if err != nil {
+			return Detection{}, err
+		}
+		return Detection{Preset: d.Name(), Config: meta.LookAtni, Dialect: d, Source: FromFrontmatter, Confidence: 1, Markers: d.Detect(rest)}, nil
+	}
+
+	dialects := Dialects()
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	visible := make(map[string][]string, len(dialects))
+	scores := make(map[string]int, len(dialects))
+	weights := make(map[string]float64, len(dialects))
+	for _, d := range dialects {
+		visible[d.Name()] = outsideBlocks(lines, d, dialects)
+		scores[d.Name()] = d.Detect([]byte(strings.Join(visible[d.Name()], "\n")))
+		weights[d.Name()] = float64(scores[d.Name()])
+		// A PROJECT_INFO marker counts for a few ordinary ones
+		if scores[d.Name()] > 0 && projectInfoLine(visible[d.Name()], d) >= 0 {
+			weights[d.Name()] += 3
+		}
+	}
+	ranked := append([]Dialect(nil), dialects...)
+	sort.SliceStable(ranked, func(i, j int) bool { return weights[ranked[i].Name()] > weights[ranked[j].Name()] })
+
+	best, decisive := decisiveDialect(ranked, visible, scores)
+	if !decisive {
+		best = ranked[0]
+	}
+	if scores[best.Name()] == 0 {
+		return fallback(scores, 0, ""), nil
+	}
+	// Marker lines the chosen dialect reads too say nothing against it, as
+	// when a language-aware archive and an HTML one both hold <!-- FILE: a.md -->
+	top, second := weights[best.Name()], 0.0
+	for _, other := range ranked {
+		if other != best && scores[other.Name()] > 0 {
+			second = max(second, contradicting(visible[other.Name()], best, other))
+		}
+	}
+	// Confidence grows with the lead over the runner-up and with the amount of
+	// evidence: one unopposed marker gives 0.5, ten give about 0.9
+	confidence := math.Round(max(top-second, 0)/top*(top/(top+1))*100) / 100
+	if !decisive && confidence < MinConfidence {
+		return fallback(scores, confidence, fmt.Sprintf("markers are ambiguous (%s with confidence %.2f); reading as default", best.Name(), confidence)), nil
+	}
+	return Detection{
+		Preset:     best.Name(),
+		Config:     best.Config(),
+		Dialect:    best,
+		Source:     FromContent,
+		Confidence: confidence,
+		Markers:    scores[best.Name()],
+		Scores:     scores,
+	}, nil
+}
+
+// fallback returns the default dialect for content that sniffing could not
+// place.
+func fallback(scores map[string]int, confidence float64, warning string) Detection {
+	def, _ := LookupDialect("default")
+	return Detection{Preset: "default", Config: GetDefaultConfig(), Dialect: def, Source: FromDefault, Confidence: confidence, Markers: scores["default"], Scores: scores, Warning: warning}
+}
+
+// decisiveDialect returns the dialect whose PROJECT_INFO marker comes first,
+// or else the best ranked one with markers framed by the FS character.
+func decisiveDialect(ranked []Dialect, visible map[string][]string, scores map[string]int) (Dialect, bool) {
+	var first Dialect
+	at := -1
+	for _, d := range ranked {
+		if scores[d.Name()] == 0 {
+			continue
+		}
+		// Ranked order settles a line two dialects read alike
+		if i := projectInfoLine(visible[d.Name()], d); i >= 0 && (at < 0 || i < at) {
+			first, at = d, i
+		}
+	}
+	if first != nil {
+		return first, true
+	}
+	for _, d := range ranked {
+		if scores[d.Name()] > 0 && hasFSMarker(visible[d.Name()], d) {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// outsideBlocks returns lines with those inside the blocks of every block
+// dialect other than d blanked, so that d's markers quoted there are not
+// counted.
+func outsideBlocks(lines []string, d Dialect, dialects []Dialect) []string {
+	visible := append([]string(nil), lines...)
+	for _, other := range dialects {
+		if other == d {
+			continue
+		}
+		if _, ok := other.(BlockDialect); !ok {
+			continue
+		}
+		for i := 0; i < len(lines); i++ {
+			if len(lines[i]) > maxMarkerLine {
+				continue
+			}
+			name, ok := other.ParseLine(lines[i])
+			if !ok || !plausibleName(name) {
+				continue
+			}
+			block := BlockLines(other, lines[i+1:])
+			for j := i + 1; j <= i+block; j++ {
+				visible[j] = ""
+			}
+			i += block
+		}
+	}
+	return visible
+}
+
+// projectInfoLine returns the index of the first PROJECT_INFO marker of d in
+// lines, or -1.
+func projectInfoLine(lines []string, d Dialect) int {
+	for i, line := range lines {
+		if !strings.Contains(line, "PROJECT_INFO") || len(line) > maxMarkerLine {
+			continue
+		}
+		if name, ok := d.ParseLine(line); ok && strings.TrimSpace(name) == "PROJECT_INFO" {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasFSMarker reports whether lines hold a marker of d framed by the FS
+// control character.
+func hasFSMarker(lines []string, d Dialect) bool {
+	for _, line := range lines {
+		if !strings.ContainsRune(line, '\x1c') || len(line) > maxMarkerLine {
+			continue
+		}
+		if name, ok := d.ParseLine(line); ok && plausibleName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// contradicting weighs the marker lines of other that best does not accept.
+func contradicting(lines []string, best, other Dialect) float64 {
+	w := 0.0
+	for _, text := range lines {
+		if len(text) > maxMarkerLine {
+			continue
+		}
+		name, ok := other.ParseLine(text)
+		if !ok || !plausibleName(name) {
+			continue
+		}
+		if _, also := best.ParseLine(text); also {
+			continue
+		}
+		w++
+		if strings.TrimSpace(name) == "PROJECT_INFO" {
+			w += 3
+		}
+	}
+	return w
+}
+
+// WriteJSON writes the detection as indented JSON.
+func (d Detection) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteText writes the detection for humans, with per-preset scores when the
+// dialect was sniffed, or the document format and its file count.
+func (d Detection) WriteText(w io.Writer) error {
+	if d.Format != "" {
+		fmt.Fprintf(w, "Format:     %s\n", d.Format)
+		fmt.Fprintf(w, "Source:     %s\n", d.Source)
+		fmt.Fprintf(w, "Confidence: %.2f\n", d.Confidence)
+		_, err := fmt.Fprintf(w, "Files:      %d\n", d.Markers)
+		return err
+	}
+	preset := d.Preset
+	if preset == "" {
+		preset = "custom"
+	}
+	fmt.Fprintf(w, "Dialect:    %s\n", preset)
+	fmt.Fprintf(w, "Marker:     %s\n", d.Config.FormatMarker("<path>"))
+	fmt.Fprintf(w, "Source:     %s\n", d.Source)
+	fmt.Fprintf(w, "Confidence: %.2f\n", d.Confidence)
+	if d.Warning != "" {
+		fmt.Fprintf(w, "Warning:    %s\n", d.Warning)
+	}
+	_, err := fmt.Fprintf(w, "Markers:    %d\n", d.Markers)
+	if d.Source == FromFrontmatter || err != nil {
+		return err
+	}
+	for _, name := range PresetNames() {
+		if _, err := fmt.Fprintf(w, "  %-10s %d\n", name, d.Scores[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PresetNames lists the registered dialects, default first.
+func PresetNames() []string {
+	var names []string
+	for _, d := range Dialects() {
+		names = append(names, d.Name())
+	}
+	return names
+}
+
+// PresetName returns the name of the registered dialect whose markers config
+// produces, or "".
+func PresetName(config MarkerConfig) string {
+	if d, err := DialectFor(config); err == nil {
+		return d.Name()
+	}
+	return ""
+}
+
+// plausibleName reports whether a captured marker name looks like a file path.
+func plausibleName(name string) bool {
+	name = strings.TrimSpace(name)
+	return name != "" && !strings.ContainsAny(name, "\x00\t") && len(name) <= 1024
+}
@@ -0,0 +1,379 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Dialect is a marker syntax. Parsing, generation, validation, detection and
+// conversion are written against it, so a dialect registered here works with
+// every command.
+type Dialect interface {
+	// Name identifies the dialect in the registry; it is empty for a
+	// frontmatter configuration that matches no registered dialect.
+	Name() string
+	// Config describes the dialect for frontmatter.
+	Config() MarkerConfig
+	// Detect counts the lines of content that are well-formed markers.
+	Detect(content []byte) int
+	// ParseLine reports whether line, without its newline, is a marker and
+	// returns the filename it carries.
+	ParseLine(line string) (string, bool)
+	// FormatMarker renders the marker line for filename, without a newline.
+	FormatMarker(filename string) string
+	// Escape returns filename as it must be written in a marker, or an error
+	// when the dialect cannot represent it.
+	Escape(filename string) (string, error)
+}
+
+// AttributeDialect is implemented by dialects whose markers carry attribute
+// placeholders such as {size} or {index} besides the filename.
+type AttributeDialect interface {
+	Dialect
+	// FormatMarkerAttrs renders the marker for filename with attribute values.
+	FormatMarkerAttrs(filename string, attrs map[string]string) string
+	// ParseLineAttrs is ParseLine also returning the captured attributes.
+	ParseLineAttrs(line string) (string, map[string]string, bool)
+}
+
+// FormatMarker renders the marker of filename in d, with attrs when d supports them.
+func FormatMarker(d Dialect, filename string, attrs map[string]string) string {
+	if ad, ok := d.(AttributeDialect); ok {
+		return ad.FormatMarkerAttrs(filename, attrs)
+	}
+	return d.FormatMarker(filename)
+}
+
+// ParseMarker reads a marker line of d with its attributes, if d has any.
+func ParseMarker(d Dialect, line string) (string, map[string]string, bool) {
+	if ad, ok := d.(AttributeDialect); ok {
+		return ad.ParseLineAttrs(line)
+	}
+	name, ok := d.ParseLine(line)
+	return name, nil, ok
+}
+
+// UsesPlaceholder reports whether the marker d writes for filename carries the
+// attribute placeholder name, so that writers compute only the values used.
+// Attribute dialects that are not built from a MarkerConfig are assumed to
+// use every placeholder.
+func UsesPlaceholder(d Dialect, filename, name string) bool {
+	if _, ok := d.(AttributeDialect); !ok {
+		return false
+	}
+	if cd, ok := d.(*configDialect); ok {
+		return strings.Contains(cd.config.template(filename), "{"+name+"}")
+	}
+	return true
+}
+
+// UsesAttributes reports whether the marker d writes for filename carries any
+// attribute placeholder.
+func UsesAttributes(d Dialect, filename string) bool {
+	for _, p := range placeholders {
+		if UsesPlaceholder(d, filename, p.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockDialect is implemented by dialects that enclose each file's content in
+// a block after its marker line, as fenced Markdown does. Marker lines inside
+// a block are content, so readers skip the lines BlockLines reports.
+type BlockDialect interface {
+	Dialect
+	// WrapContent renders content as the lines that follow its marker line.
+	WrapContent(filename, content string) string
+	// BlockLines returns how many of lines, those following a marker line,
+	// belong to the block it opens; 0 when it opens none.
+	BlockLines(lines []string) int
+	// UnwrapContent returns the content held by body, the text between a
+	// marker line and the next one.
+	UnwrapContent(body string) string
+}
+
+// WrapContent renders content as it follows its marker line in d: in a block
+// for block dialects, otherwise as is with a final newline.
+func WrapContent(d Dialect, filename, content string) string {
+	if bd, ok := d.(BlockDialect); ok {
+		return bd.WrapContent(filename, content)
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content
+}
+
+// UnwrapContent returns the content of body, the text after a marker of d.
+func UnwrapContent(d Dialect, body string) string {
+	if bd, ok := d.(BlockDialect); ok {
+		return bd.UnwrapContent(body)
+	}
+	return body
+}
+
+// BlockLines returns how many of lines, those after a marker line of d, are
+// content whatever they look like.
+func BlockLines(d Dialect, lines []string) int {
+	if bd, ok := d.(BlockDialect); ok {
+		return bd.BlockLines(lines)
+	}
+	return 0
+}
+
+// UndeclaredDialect is implemented by dialects whose archives are written
+// without frontmatter, because other tools give meaning to the text before
+// the first marker, as txtar does to its comment.
+type UndeclaredDialect interface {
+	Dialect
+	Undeclared()
+}
+
+// FrontmatterFor returns the frontmatter that declares d at the top of an
+// archive. The default dialect, which readers fall back to, and undeclared
+// dialects get none.
+func FrontmatterFor(d Dialect) ([]byte, error) {
+	if _, ok := d.(UndeclaredDialect); ok || d.Name() == "default" {
+		return nil, nil
+	}
+	return GenerateFrontmatter(d.Config())
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Dialect{}
+)
+
+func init() {
+	for name, preset := range GetPresetConfigs() {
+		// Presets naming a dialect are implemented in Go and registered by it
+		if preset.Config.Dialect != "" {
+			continue
+		}
+		d, err := NewDialect(name, preset.Config)
+		if err != nil {
+			panic(fmt.Sprintf("metadata: preset %s: %v", name, err))
+		}
+		RegisterDialect(d)
+	}
+}
+
+// RegisterDialect makes a dialect available by name to detection, convert and
+// generate. It panics if the name is empty or already registered.
+func RegisterDialect(d Dialect) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := d.Name()
+	if name == "" {
+		panic("metadata: RegisterDialect with an empty name")
+	}
+	if _, dup := registry[name]; dup {
+		panic("metadata: RegisterDialect called twice for " + name)
+	}
+	registry[name] = d
+}
+
+// LookupDialect returns the registered dialect called name.
+func LookupDialect(name string) (Dialect, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	d, ok := registry[name]
+	return d, ok
+}
+
+// Dialects returns the registered dialects, default first and the rest by name.
+func Dialects() []Dialect {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	dialects := make([]Dialect, 0, len(registry))
+	for _, d := range registry {
+		dialects = append(dialects, d)
+	}
+	sort.Slice(dialects, func(i, j int) bool {
+		a, b := dialects[i].Name(), dialects[j].Name()
+		if (a == "default") != (b == "default") {
+			return a == "default"
+		}
+		return a < b
+	})
+	return dialects
+}
+
+// DialectFor returns the registered dialect that writes the same markers as
+// config, or an unnamed dialect built from config. A config naming its
+// dialect gets that one.
+func DialectFor(config MarkerConfig) (Dialect, error) {
+	if config.Dialect != "" {
+		d, ok := LookupDialect(config.Dialect)
+		if !ok {
+			return nil, fmt.Errorf("unknown marker dialect %q", config.Dialect)
+		}
+		return d, nil
+	}
+	for _, d := range Dialects() {
+		if c := d.Config(); c.signature() == config.signature() {
+			return d, nil
+		}
+	}
+	return NewDialect("", config)
+}
+
+// NewDialect builds a dialect from a marker configuration.
+func NewDialect(name string, config MarkerConfig) (Dialect, error) {
+	re, err := config.GenerateRegex()
+	if err != nil {
+		return nil, fmt.Errorf("invalid marker configuration: %w", err)
+	}
+	if re.SubexpIndex("filename") < 0 {
+		return nil, fmt.Errorf("invalid marker configuration: %q lacks {filename}", config.template(""))
+	}
+	d := &configDialect{name: name, config: config, re: re}
+	for _, lang := range config.Languages {
+		re, err := PatternRegex(lang.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid marker configuration: %w", err)
+		}
+		if re.SubexpIndex("filename") < 0 {
+			return nil, fmt.Errorf("invalid marker configuration: language pattern %q lacks {filename}", lang.Pattern)
+		}
+		d.langs = append(d.langs, languageRegex{pattern: lang.Pattern, re: re})
+	}
+	return d, nil
+}
+
+// configDialect matches the marker lines described by a MarkerConfig: one
+// syntax, or one per language plus a fallback, read as a single set.
+type configDialect struct {
+	name   string
+	config MarkerConfig
+	re     *regexp.Regexp
+	langs  []languageRegex
+}
+
+type languageRegex struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (d *configDialect) Name() string         { return d.name }
+func (d *configDialect) Config() MarkerConfig { return d.config }
+
+func (d *configDialect) Detect(content []byte) int {
+	n := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(line) > maxMarkerLine {
+			continue
+		}
+		if name, ok := d.ParseLine(string(bytes.TrimRight(line, "\r"))); ok && plausibleName(name) {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *configDialect) ParseLine(line string) (string, bool) {
+	name, _, ok := d.ParseLineAttrs(line)
+	return name, ok
+}
+
+// ParseLineAttrs accepts a language marker only for files of that language,
+// so that a quoted marker of another language inside content is left alone.
+func (d *configDialect) ParseLineAttrs(line string) (string, map[string]string, bool) {
+	for _, lang := range d.langs {
+		if name, attrs, ok := matchMarker(lang.re, line); ok && d.config.LanguagePattern(name) == lang.pattern {
+			return name, attrs, true
+		}
+	}
+	name, attrs, ok := matchMarker(d.re, line)
+	if !ok || (len(d.langs) > 0 && name != "" && d.config.LanguagePattern(name) != "") {
+		return "", nil, false
+	}
+	return name, attrs, true
+}
+
+// matchMarker returns the filename and attribute groups re captures in line.
+func matchMarker(re *regexp.Regexp, line string) (string, map[string]string, bool) {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return "", nil, false
+	}
+	var attrs map[string]string
+	for i, group := range re.SubexpNames() {
+		if group != "" && group != "filename" {
+			if attrs == nil {
+				attrs = map[string]string{}
+			}
+			attrs[group] = m[i]
+		}
+	}
+	return strings.TrimSpace(m[re.SubexpIndex("filename")]), attrs, true
+}
+
+func (d *configDialect) FormatMarker(filename string) string {
+	return d.config.FormatMarker(filename)
+}
+
+func (d *configDialect) FormatMarkerAttrs(filename string, attrs map[string]string) string {
+	return d.config.FormatMarkerAttrs(filename, attrs)
+}
+
+// Escape accepts the names that read back unchanged from their marker line;
+// these marker syntaxes have no quoting.
+func (d *configDialect) Escape(filename string) (string, error) {
+	return escapeByRoundTrip(d, filename)
+}
+
+// escapeByRoundTrip accepts filename when its marker line in d reads back as
+// filename.
+func escapeByRoundTrip(d Dialect, filename string) (string, error) {
+	if strings.ContainsAny(filename, "\r\n") {
+		return "", fmt.Errorf("filename cannot be represented: %q", filename)
+	}
+	if name, ok := d.ParseLine(d.FormatMarker(filename)); !ok || name != filename {
+		dialect := d.Name()
+		if dialect == "" {
+			dialect = "custom"
+		}
+		return "", fmt.Errorf("filename cannot be represented in %s markers: %q", dialect, filename)
+	}
+	return filename, nil
+}
+
+// signature identifies the markers a config writes, whatever its spelling.
+func (mc *MarkerConfig) signature() string {
+	sig := mc.FormatMarker("x")
+	for _, lang := range mc.Languages {
+		for _, ext := range lang.Extensions {
+			sig += "\n" + ext + "=" + lang.Pattern
+		}
+	}
+	return sig
+}
+
+// MarkerTokens returns the text a dialect writes before and after the filename
+// and attributes of a marker, trimmed of spaces. Strict validation treats lines
+// containing either token as intended markers; tokens shorter than three
+// characters are too common to tell and come back empty.
+func MarkerTokens(d Dialect) (start, end string) {
+	template := d.FormatMarker("\x00")
+	if cd, ok := d.(*configDialect); ok {
+		template = strings.ReplaceAll(cd.config.template(""), "{filename}", "\x00")
+	}
+	first, last := strings.IndexAny(template, "{\x00"), strings.LastIndexAny(template, "}\x00")
+	if first < 0 {
+		return "", ""
+	}
+	start, end = strings.TrimSpace(template[:first]), strings.TrimSpace(template[last+1:])
+	if len([]rune(start)) < 3 {
+		start = ""
+	}
+	if len([]rune(end)) < 3 {
+		end = ""
+	}
+	return start, end
+}
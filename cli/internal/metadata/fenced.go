@@ -0,0 +1,166 @@
+package metadata
+
+import (
+	"strings"
+)
+
+// fencedConfig describes the fenced dialect in frontmatter.
+var fencedConfig = MarkerConfig{Dialect: "fenced", Version: "2.0", Pattern: "### {filename}"}
+
+func init() {
+	RegisterDialect(fencedDialect{})
+}
+
+// fencedDialect writes each file as a "### path" heading followed by a fenced
+// code block tagged with its language, the layout chat models read and write
+// most reliably. Fences grow past the backtick runs of the content, and tilde
+// fences are read as well.
+type fencedDialect struct{}
+
+const fencedHeading = "### "
+
+func (fencedDialect) Name() string         { return "fenced" }
+func (fencedDialect) Config() MarkerConfig { return fencedConfig }
+
+// Detect counts the headings that open a fenced block, skipping the blocks
+// themselves so that headings of Markdown files inside them do not count.
+func (d fencedDialect) Detect(content []byte) int {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	n := 0
+	for i := 0; i < len(lines); i++ {
+		if len(lines[i]) > maxMarkerLine {
+			continue
+		}
+		name, ok := d.ParseLine(lines[i])
+		if !ok {
+			continue
+		}
+		block := d.BlockLines(lines[i+1:])
+		if block > 0 && plausibleName(name) {
+			n++
+		}
+		i += block
+	}
+	return n
+}
+
+// ParseLine reads "### path", also with the path in backticks as chat models
+// often write it.
+func (fencedDialect) ParseLine(line string) (string, bool) {
+	name, ok := strings.CutPrefix(line, fencedHeading)
+	if !ok {
+		return "", false
+	}
+	name = strings.TrimSpace(name)
+	if len(name) > 2 && strings.HasPrefix(name, "`") && strings.HasSuffix(name, "`") {
+		name = name[1 : len(name)-1]
+	}
+	return name, name != ""
+}
+
+func (fencedDialect) FormatMarker(filename string) string {
+	return fencedHeading + filename
+}
+
+func (d fencedDialect) Escape(filename string) (string, error) {
+	return escapeByRoundTrip(d, filename)
+}
+
+// WrapContent fences content with one more backtick than its longest run of
+// backticks, and at least three.
+func (fencedDialect) WrapContent(filename, content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+
+	var b strings.Builder
+	b.WriteString(fence + LanguageOf(filename) + "\n")
+	// Readers trim trailing newlines, so the fence follows the last line
+	if content = strings.TrimRight(content, "\n"); content != "" {
+		b.WriteString(content + "\n")
+	}
+	b.WriteString(fence + "\n\n")
+	return b.String()
+}
+
+// BlockLines covers the blank lines after the heading and the fenced block
+// that follows them; a fence left open runs to the end of the document.
+func (fencedDialect) BlockLines(lines []string) int {
+	open := 0
+	for open < len(lines) && strings.TrimSpace(lines[open]) == "" {
+		open++
+	}
+	if open == len(lines) {
+		return 0
+	}
+	fence, _, ok := openingFence(lines[open])
+	if !ok {
+		return 0
+	}
+	for i := open + 1; i < len(lines); i++ {
+		if closesFence(lines[i], fence) {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// UnwrapContent returns the lines inside the fence, without the indentation
+// of the opening fence. Text after the closing fence is commentary and is
+// dropped; a body without a fence is returned as is.
+func (d fencedDialect) UnwrapContent(body string) string {
+	lines := strings.Split(body, "\n")
+	end := d.BlockLines(lines)
+	if end == 0 {
+		return body
+	}
+	open := 0
+	for strings.TrimSpace(lines[open]) == "" {
+		open++
+	}
+	fence, indent, _ := openingFence(lines[open])
+	if closesFence(lines[end-1], fence) && end-1 > open {
+		end--
+	}
+	inner := lines[open+1 : end]
+	for i, line := range inner {
+		trimmed := strings.TrimLeft(line, " ")
+		inner[i] = line[min(indent, len(line)-len(trimmed)):]
+	}
+	return strings.Join(inner, "\n")
+}
+
+// openingFence parses a CommonMark opening code fence: up to three spaces of
+// indentation, three or more backticks or tildes, and an info string, which
+// for backtick fences cannot contain backticks.
+func openingFence(line string) (fence string, indent int, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent = len(line) - len(trimmed)
+	if indent > 3 || trimmed == "" || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", 0, false
+	}
+	info := strings.TrimLeft(trimmed, trimmed[:1])
+	n := len(trimmed) - len(info)
+	if n < 3 || (trimmed[0] == '`' && strings.Contains(info, "`")) {
+		return "", 0, false
+	}
+	return trimmed[:n], indent, true
+}
+
+// closesFence reports whether line closes a block opened with fence: the same
+// character at least as many times, with nothing but spaces after it.
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	rest := strings.TrimLeft(trimmed, fence[:1])
+	return len(trimmed)-len(rest) >= len(fence) && strings.TrimSpace(rest) == ""
+}
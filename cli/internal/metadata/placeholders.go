@@ -0,0 +1,128 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Placeholders a marker template may use besides {filename}, {start} and
+// {end}. Generators fill them per file; parsers capture them as named groups.
+const (
+	SizePlaceholder   = "size"   // content size, e.g. 512B or 1.2KB
+	SHA256Placeholder = "sha256" // hex SHA-256 of the content without trailing newlines
+	LangPlaceholder   = "lang"   // language derived from the file name, e.g. go
+	ModePlaceholder   = "mode"   // octal permission bits, e.g. 0755
+	IndexPlaceholder  = "index"  // 1-based position of the file in the archive
+)
+
+// placeholder describes how an attribute placeholder is rendered and matched.
+type placeholder struct {
+	name  string
+	regex string
+	// zero is rendered when no value is known, so the marker still parses.
+	zero string
+}
+
+var placeholders = []placeholder{
+	{SizePlaceholder, `\d+(?:\.\d+)?[KMGT]?B`, "0B"},
+	{SHA256Placeholder, `[0-9a-f]{64}`, strings.Repeat("0", 64)},
+	{LangPlaceholder, `[A-Za-z0-9_+#.-]+`, "text"},
+	{ModePlaceholder, `[0-7]{3,4}`, "0644"},
+	{IndexPlaceholder, `\d+`, "0"},
+}
+
+// PatternRegex compiles a marker template into an anchored regex with a
+// "filename" group and one named group per attribute placeholder it uses.
+func PatternRegex(pattern string) (*regexp.Regexp, error) {
+	// Escape special regex characters and replace the placeholders
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = replaceGroup(quoted, "filename", ".+?")
+	for _, p := range placeholders {
+		quoted = replaceGroup(quoted, p.name, p.regex)
+	}
+	return regexp.Compile("^" + quoted + "$")
+}
+
+// replaceGroup turns the first {name} of a quoted template into a named group
+// and any repetition into a plain group, as group names must be unique.
+func replaceGroup(quoted, name, regex string) string {
+	token := regexp.QuoteMeta("{" + name + "}")
+	quoted = strings.Replace(quoted, token, "(?P<"+name+">"+regex+")", 1)
+	return strings.ReplaceAll(quoted, token, "(?:"+regex+")")
+}
+
+// fillPlaceholders renders the attribute placeholders of template from attrs.
+// The language defaults to the one of filename and other values to zeros.
+func fillPlaceholders(template, filename string, attrs map[string]string) string {
+	for _, p := range placeholders {
+		token := "{" + p.name + "}"
+		if !strings.Contains(template, token) {
+			continue
+		}
+		value := attrs[p.name]
+		if value == "" && p.name == LangPlaceholder {
+			value = LanguageOf(filename)
+		}
+		if value == "" {
+			value = p.zero
+		}
+		template = strings.ReplaceAll(template, token, value)
+	}
+	return template
+}
+
+// languages maps file extensions to the names written by {lang}.
+var languages = map[string]string{
+	"go": "go", "py": "python", "js": "javascript", "mjs": "javascript", "cjs": "javascript",
+	"ts": "typescript", "tsx": "tsx", "jsx": "jsx", "rb": "ruby", "rs": "rust",
+	"java": "java", "kt": "kotlin", "swift": "swift", "c": "c", "h": "c",
+	"cc": "cpp", "cpp": "cpp", "hpp": "cpp", "cs": "csharp", "php": "php",
+	"sh": "bash", "bash": "bash", "zsh": "zsh", "ps1": "powershell", "sql": "sql",
+	"lua": "lua", "hs": "haskell", "html": "html", "htm": "html", "css": "css",
+	"scss": "scss", "xml": "xml", "svg": "xml", "json": "json", "yaml": "yaml",
+	"yml": "yaml", "toml": "toml", "ini": "ini", "md": "markdown", "markdown": "markdown",
+	"tex": "latex", "r": "r", "pl": "perl", "ex": "elixir", "exs": "elixir",
+	"erl": "erlang", "clj": "clojure", "scala": "scala", "dart": "dart", "vue": "vue",
+	"proto": "protobuf", "tf": "hcl", "txt": "text",
+}
+
+var plainExt = regexp.MustCompile(`^[a-z0-9_+#-]+$`)
+
+// LanguageOf names the language of filename for {lang}: a known name for
+// common extensions, the extension itself otherwise, or "text".
+func LanguageOf(filename string) string {
+	base := strings.ToLower(path.Base(filepath.ToSlash(filename)))
+	switch base {
+	case "makefile", "gnumakefile":
+		return "makefile"
+	case "dockerfile":
+		return "dockerfile"
+	}
+	ext := strings.TrimPrefix(path.Ext(base), ".")
+	if lang, ok := languages[ext]; ok {
+		return lang
+	}
+	if plainExt.MatchString(ext) {
+		return ext
+	}
+	return "text"
+}
+
+// FormatSize renders a byte count for {size}: bytes below 1KB, then one
+// decimal in the largest fitting binary unit.
+func FormatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, unit := float64(n)/1024, "KB"
+	for _, next := range []string{"MB", "GB", "TB"} {
+		if value < 1024 {
+			break
+		}
+		value, unit = value/1024, next
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + unit
+}
@@ -0,0 +1,56 @@
+package metadata
+
+import (
+	"bytes"
+	"strings"
+)
+
+// txtarConfig describes the txtar dialect in listings. Its archives carry no
+// frontmatter, so readers find it by sniffing or by name.
+var txtarConfig = MarkerConfig{Dialect: "txtar", Version: "2.0", Pattern: "-- {filename} --"}
+
+func init() {
+	RegisterDialect(txtarDialect{})
+}
+
+// txtarDialect reads and writes the format of golang.org/x/tools/txtar, used
+// by Go script tests and fixtures: each file follows a "-- path --" line, and
+// the text before the first file is the archive comment, which script tests
+// run as their script.
+type txtarDialect struct{}
+
+func (txtarDialect) Name() string         { return "txtar" }
+func (txtarDialect) Config() MarkerConfig { return txtarConfig }
+
+// Undeclared keeps frontmatter out of the comment section.
+func (txtarDialect) Undeclared() {}
+
+func (d txtarDialect) Detect(content []byte) int {
+	n := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(line) > maxMarkerLine {
+			continue
+		}
+		if name, ok := d.ParseLine(string(bytes.TrimRight(line, "\r"))); ok && plausibleName(name) {
+			n++
+		}
+	}
+	return n
+}
+
+// ParseLine follows the txtar package: "-- ", a name trimmed of spaces and " --".
+func (txtarDialect) ParseLine(line string) (string, bool) {
+	if len(line) < len("-- ")+len(" --") || !strings.HasPrefix(line, "-- ") || !strings.HasSuffix(line, " --") {
+		return "", false
+	}
+	name := strings.TrimSpace(line[len("-- ") : len(line)-len(" --")])
+	return name, name != ""
+}
+
+func (txtarDialect) FormatMarker(filename string) string {
+	return "-- " + filename + " --"
+}
+
+func (d txtarDialect) Escape(filename string) (string, error) {
+	return escapeByRoundTrip(d, filename)
+}
@@ -0,0 +1,247 @@
+// Package mirror removes files from an extraction target that an archive no longer contains.
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/ignore"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// Results lists the files deleted (or, in dry-run mode, that would be deleted).
+type Results struct {
+	DeletedFiles []string `json:"deletedFiles"`
+	Errors       []string `json:"errors"`
+}
+
+// Apply mirrors a parsed archive onto outputDir after extracted was written
+// from it: files the archive does not contain are pruned, except for
+// changesets, which hold only what changed and delete exactly the paths they
+// list. It refuses archives with parse errors, archives without files and
+// failed extractions, where pruning would delete files the archive meant to
+// keep.
+func Apply(outputDir string, parsed *parser.ParseResults, extracted *parser.ExtractResults, scope string, dryRun bool) (*Results, error) {
+	if len(parsed.Errors) > 0 {
+		return nil, fmt.Errorf("refusing to mirror an archive with %d parse errors", len(parsed.Errors))
+	}
+	if extracted != nil && !extracted.Success {
+		return nil, fmt.Errorf("refusing to mirror after a failed extraction")
+	}
+	if info := parsed.ProjectInfo(); len(info[parser.ChangesetKey]) > 0 {
+		return Remove(outputDir, info[parser.DeletedKey], scope, dryRun)
+	}
+	keep := make([]string, 0, len(parsed.Markers))
+	files := 0
+	for _, marker := range parsed.Markers {
+		keep = append(keep, marker.Filename)
+		if marker.Filename != parser.ProjectInfoMarker {
+			files++
+		}
+	}
+	if files == 0 {
+		return nil, fmt.Errorf("refusing to mirror an archive without files")
+	}
+	return Prune(outputDir, keep, scope, dryRun)
+}
+
+// Prune deletes every file below outputDir, or below its scope subpath when set,
+// whose slash-separated path relative to outputDir is not in keep.
+//
+// Ignore files, the files they match and version control directories are never
+// touched. When outputDir is inside a git work tree, only files tracked by
+// git are candidates, so untracked local files survive as well.
+func Prune(outputDir string, keep []string, scope string, dryRun bool) (*Results, error) {
+	result := &Results{DeletedFiles: []string{}, Errors: []string{}}
+
+	root := filepath.Clean(outputDir)
+	if scope != "" {
+		clean := filepath.Clean(filepath.FromSlash(scope))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("mirror scope must be a relative path inside the output directory: %s", scope)
+		}
+		root = filepath.Join(outputDir, clean)
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return result, nil
+	}
+
+	keepSet := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		keepSet[filepath.ToSlash(filepath.Clean(k))] = true
+	}
+	tracked := gitTrackedFiles(outputDir)
+
+	// Ignore files between outputDir and the scope root still apply, outermost first
+	var chain []string
+	for dir := root; ; dir = filepath.Dir(dir) {
+		chain = append([]string{dir}, chain...)
+		if rel, err := filepath.Rel(outputDir, dir); err != nil || rel == "." {
+			break
+		}
+	}
+	matcher := ignore.New()
+	for _, dir := range chain {
+		if err := matcher.LoadDir(outputDir, dir); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to read ignore files in %s: %v", dir, err))
+		}
+	}
+
+	// Directories holding deleted files; only these may be removed, so that
+	// empty directories the archive never touched survive
+	var dirs []string
+	emptied := map[string]bool{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Error accessing %s: %v", path, err))
+			return nil
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return nil
+		}
+		slashRel := filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if matcher.Match(slashRel, true) {
+				return filepath.SkipDir
+			}
+			if err := matcher.LoadDir(outputDir, path); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("Failed to read ignore files in %s: %v", path, err))
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+
+		if keepSet[slashRel] || matcher.Match(slashRel, false) || isIgnoreFile(path) {
+			return nil
+		}
+		if tracked != nil && !tracked[slashRel] {
+			return nil
+		}
+
+		result.DeletedFiles = append(result.DeletedFiles, path)
+		if dryRun {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to delete %s: %v", path, err))
+			return nil
+		}
+		for dir := filepath.Dir(path); len(dir) > len(root); dir = filepath.Dir(dir) {
+			emptied[dir] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	// Remove directories emptied by the deletions, deepest first
+	if !dryRun {
+		sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+		for _, dir := range dirs {
+			if !emptied[dir] {
+				continue
+			}
+			if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+				_ = os.Remove(dir)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Remove deletes the listed files, given as slash-separated paths relative to
+// outputDir, and the directories they leave empty. It is how changeset archives
+// apply deletions. Paths outside scope, when set, and missing files are skipped.
+func Remove(outputDir string, paths []string, scope string, dryRun bool) (*Results, error) {
+	result := &Results{DeletedFiles: []string{}, Errors: []string{}}
+
+	prefix := ""
+	if scope != "" {
+		clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(scope)))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("mirror scope must be a relative path inside the output directory: %s", scope)
+		}
+		if clean != "." {
+			prefix = clean + "/"
+		}
+	}
+
+	for _, name := range paths {
+		clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(name)))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			result.Errors = append(result.Errors, fmt.Sprintf("Refusing to delete %s: outside the output directory", name))
+			continue
+		}
+		if !strings.HasPrefix(clean, prefix) {
+			continue
+		}
+		path := filepath.Join(outputDir, filepath.FromSlash(clean))
+		info, err := os.Lstat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		result.DeletedFiles = append(result.DeletedFiles, path)
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to delete %s: %v", path, err))
+			continue
+		}
+		for dir := filepath.Dir(path); dir != filepath.Clean(outputDir) && strings.HasPrefix(dir, filepath.Clean(outputDir)); dir = filepath.Dir(dir) {
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 || os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// isIgnoreFile reports whether path is an ignore file, which mirroring always keeps.
+func isIgnoreFile(path string) bool {
+	base := filepath.Base(path)
+	for _, name := range ignore.FileNames {
+		if base == name {
+			return true
+		}
+	}
+	return false
+}
+
+// gitTrackedFiles returns the files git tracks below dir, relative to dir, or nil
+// when dir is not inside a git work tree or git is unavailable.
+func gitTrackedFiles(dir string) map[string]bool {
+	cmd := exec.Command("git", "-C", dir, "ls-files", "-z", "--full-name", "--", ".")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	prefixCmd := exec.Command("git", "-C", dir, "rev-parse", "--show-prefix")
+	prefixOut, err := prefixCmd.Output()
+	if err != nil {
+		return nil
+	}
+	prefix := strings.TrimSpace(string(prefixOut))
+
+	tracked := map[string]bool{}
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		tracked[strings.TrimPrefix(string(name), prefix)] = true
+	}
+	return tracked
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+)
+
+// Armor delimiters wrap a canonical archive in a printable, line-wrapped envelope
+// so it survives transports that strip control characters (email, Jira, Slack).
+const (
+	ArmorBegin = "-----BEGIN LOOKATNI ARCHIVE-----"
+	ArmorEnd   = "-----END LOOKATNI ARCHIVE-----"
+
+	armorVersion   = "LookAtni Armor v1"
+	armorLineWidth = 64
+)
+
+// crc24 parameters as used by OpenPGP ASCII armor (RFC 4880, section 6.1).
+const (
+	crc24Init = 0xB704CE
+	crc24Poly = 0x1864CFB
+	crc24Mask = 0xFFFFFF
+)
+
+// Armor wraps archive content in an ASCII-armored envelope with a CRC-24 checksum.
+func Armor(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(ArmorBegin + "\n")
+	buf.WriteString("Version: " + armorVersion + "\n")
+	buf.WriteString("Encoding: base64\n")
+	buf.WriteString("Checksum: crc24\n")
+	buf.WriteString("\n")
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > armorLineWidth {
+		buf.WriteString(encoded[:armorLineWidth] + "\n")
+		encoded = encoded[armorLineWidth:]
+	}
+	if encoded != "" {
+		buf.WriteString(encoded + "\n")
+	}
+
+	sum := crc24(data)
+	buf.WriteString("=" + base64.StdEncoding.EncodeToString([]byte{byte(sum >> 16), byte(sum >> 8), byte(sum)}) + "\n")
+	buf.WriteString(ArmorEnd + "\n")
+	return buf.Bytes()
+}
+
+// IsArmored reports whether data is an armored archive envelope. A BEGIN
+// delimiter line followed by the armor Version header is an envelope wherever
+// it appears, since chat and mail preambles often hold lines that look like
+// markers; only a marker framed by the FS character or a PROJECT_INFO marker
+// before it, which no preamble holds, shows an archive quoting the envelope.
+// A BEGIN line without the header counts only before any marker line.
+func IsArmored(data []byte) bool {
+	if !bytes.Contains(data, []byte(ArmorBegin)) {
+		return false
+	}
+	dialects := metadata.Dialects()
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	marker := false
+	for i, line := range lines {
+		if strings.TrimSpace(line) == ArmorBegin {
+			return !marker || hasArmorHeader(lines[i+1:])
+		}
+		if strings.ContainsRune(line, rune(28)) {
+			return false
+		}
+		for _, d := range dialects {
+			if name, ok := d.ParseLine(line); ok {
+				if strings.TrimSpace(name) == ProjectInfoMarker {
+					return false
+				}
+				marker = true
+			}
+		}
+	}
+	return false
+}
+
+// hasArmorHeader reports whether the lines after a BEGIN delimiter open with
+// the Version header Armor writes.
+func hasArmorHeader(lines []string) bool {
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			return trimmed == "Version: "+armorVersion
+		}
+	}
+	return false
+}
+
+// Dearmor extracts and verifies the archive inside an armored envelope.
+// Text before the BEGIN line and after the END line is ignored, as are
+// carriage returns and indentation added by mail or chat clients.
+func Dearmor(data []byte) ([]byte, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	start := -1
+	end := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if start < 0 && trimmed == ArmorBegin {
+			start = i
+			continue
+		}
+		if start >= 0 && trimmed == ArmorEnd {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("armor: missing %s line", ArmorBegin)
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("armor: missing %s line", ArmorEnd)
+	}
+
+	// Skip armor headers up to the first blank line
+	i := start + 1
+	for ; i < end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			i++
+			break
+		}
+		if !strings.Contains(trimmed, ":") {
+			// No header block: the payload starts right away
+			break
+		}
+	}
+
+	var payload strings.Builder
+	checksum := ""
+	for ; i < end; i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "=") && len(trimmed) == 5 {
+			checksum = trimmed[1:]
+			continue
+		}
+		payload.WriteString(trimmed)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(payload.String())
+	if err != nil {
+		return nil, fmt.Errorf("armor: invalid base64 payload: %w", err)
+	}
+
+	if checksum == "" {
+		return nil, fmt.Errorf("armor: missing checksum line")
+	}
+	raw, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil || len(raw) != 3 {
+		return nil, fmt.Errorf("armor: malformed checksum %q", checksum)
+	}
+	want := uint32(raw[0])<<16 | uint32(raw[1])<<8 | uint32(raw[2])
+	if got := crc24(decoded); got != want {
+		return nil, fmt.Errorf("armor: checksum mismatch (expected %06X, got %06X)", want, got)
+	}
+
+	return decoded, nil
+}
+
+// ReadArchive reads a marked file from disk, transparently removing ASCII armor.
+func ReadArchive(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if IsArmored(data) {
+		return Dearmor(data)
+	}
+	return data, nil
+}
+
+// ArmorFile rewrites a marked file in place as an armored envelope.
+func ArmorFile(filePath string) (int64, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	armored := Armor(data)
+	if err := os.WriteFile(filePath, armored, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write armored archive %s: %w", filePath, err)
+	}
+	return int64(len(armored)), nil
+}
+
+// crc24 computes the OpenPGP CRC-24 checksum of data.
+func crc24(data []byte) uint32 {
+	crc := uint32(crc24Init)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc & crc24Mask
+}
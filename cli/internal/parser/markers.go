@@ -1,16 +1,22 @@
 package parser
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
 )
 
+// ProjectInfoMarker is the reserved marker name of the metadata section.
+const ProjectInfoMarker = "PROJECT_INFO"
+
 // ParsedMarker represents a single file marker found in source.
 type ParsedMarker struct {
 	Filename  string `json:"filename"`
@@ -18,6 +24,9 @@ type ParsedMarker struct {
 	StartLine int    `json:"startLine"`
 	EndLine   int    `json:"endLine"`
 	Size      int64  `json:"size"`
+	// Attrs holds the attribute placeholders of the marker line, such as
+	// "index" or "lang", keyed by placeholder name.
+	Attrs map[string]string `json:"attrs,omitempty"`
 }
 
 // ParseResults contains the results of parsing a marked file.
@@ -41,28 +50,31 @@ type ExtractOptions struct {
 	Overwrite  bool `json:"overwrite"`
 	CreateDirs bool `json:"createDirs"`
 	DryRun     bool `json:"dryRun"`
+	Merge      bool `json:"merge"`
+	// Mirror deletes files the archive no longer contains, within Scope if set.
+	Mirror bool   `json:"mirror"`
+	Scope  string `json:"scope,omitempty"`
 }
 
 // ExtractResults contains the results of file extraction.
 type ExtractResults struct {
 	Success        bool     `json:"success"`
 	ExtractedFiles []string `json:"extractedFiles"`
+	MergedFiles    []string `json:"mergedFiles,omitempty"`
+	Conflicts      []string `json:"conflicts,omitempty"`
+	DeletedFiles   []string `json:"deletedFiles,omitempty"`
 	Errors         []string `json:"errors"`
 }
 
-// MarkerParser handles parsing and extraction of file markers.
+// MarkerParser handles parsing and extraction of file markers in one dialect.
 type MarkerParser struct {
-	// ASCII 28 (File Separator) character for invisible markers
-	fsChar      string
-	markerRegex *regexp.Regexp
+	dialect metadata.Dialect
 }
 
-// NewParser creates a new MarkerParser instance.
+// NewParser creates a MarkerParser for the classic ASCII 28 (File Separator) markers.
 func NewParser() *MarkerParser {
-	fsChar := string(rune(28)) // default FS = ASCII 28
-	pattern := fmt.Sprintf(`^\/\/%s\/ (.+?) \/%s\/\/$`, regexp.QuoteMeta(fsChar), regexp.QuoteMeta(fsChar))
-	markerRegex := regexp.MustCompile(pattern)
-	return &MarkerParser{fsChar: fsChar, markerRegex: markerRegex}
+	d, _ := metadata.LookupDialect("default")
+	return NewWithDialect(d)
 }
 
 // New creates a new MarkerParser instance.
@@ -70,66 +82,77 @@ func New() *MarkerParser {
 	return NewParser()
 }
 
+// NewWithDialect creates a MarkerParser for the given dialect.
+func NewWithDialect(d metadata.Dialect) *MarkerParser {
+	return &MarkerParser{dialect: d}
+}
+
 // ParseMarkedFile parses a file containing LookAtni markers.
 func (mp *MarkerParser) ParseMarkedFile(filePath string) (*ParseResults, error) {
-	file, err := os.Open(filePath)
+	data, err := ReadArchive(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
-	defer file.Close()
 
-	return mp.ParseMarkedReader(file, filePath)
+	return mp.ParseMarkedReader(bytes.NewReader(data), filePath)
 }
 
-// ParseMarkedReader parses markers from a reader.
+// ParseMarkedReader parses markers from a reader, removing ASCII armor if present.
 func (mp *MarkerParser) ParseMarkedReader(reader io.Reader, sourceName string) (*ParseResults, error) {
-	results := &ParseResults{Errors: make([]ParseError, 0), Markers: make([]ParsedMarker, 0)}
-
-	// Read all lines first to allow FS detection
-	var lines []string
-	bufScanner := bufio.NewScanner(reader)
-	for bufScanner.Scan() {
-		lines = append(lines, bufScanner.Text())
-	}
-	if err := bufScanner.Err(); err != nil {
+	data, err := io.ReadAll(reader)
+	if err != nil {
 		return nil, fmt.Errorf("error reading %s: %w", sourceName, err)
 	}
-
-	// Detect FS char dynamically using backreference
-	generic := regexp.MustCompile(`^//([\x00-\x1F])/ (.+?) /1//$`)
-	detected := ""
-	for _, line := range lines {
-		if m := generic.FindStringSubmatch(line); m != nil {
-			detected = m[1]
-			break
+	if IsArmored(data) {
+		if data, err = Dearmor(data); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", sourceName, err)
 		}
 	}
-	if detected != "" {
-		mp.fsChar = detected
-		mp.markerRegex = regexp.MustCompile(fmt.Sprintf(`^\/\/%s\/ (.+?) \/%s\/\/$`, regexp.QuoteMeta(detected), regexp.QuoteMeta(detected)))
+	return ParseContent(data, mp.dialect), nil
+}
+
+// ParseContent splits content into the sections that follow each marker of
+// dialect d. Text before the first marker is ignored, content keeps its lines
+// without carriage returns and trailing newlines, the blocks of block dialects
+// are unwrapped, and split parts are reassembled.
+func ParseContent(data []byte, d metadata.Dialect) *ParseResults {
+	results := &ParseResults{Errors: make([]ParseError, 0), Markers: make([]ParsedMarker, 0)}
+
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
 	}
 
-	lineNumber := 0
 	var currentMarker *ParsedMarker
 	var currentContent strings.Builder
 
-	for _, line := range lines {
-		lineNumber++
-		if match := mp.markerRegex.FindStringSubmatch(line); match != nil {
+	// Lines in the block a marker opens are content whatever they look like
+	block := 0
+	for i, line := range lines {
+		lineNumber := i + 1
+		if block > 0 {
+			block--
+		} else if filename, attrs, ok := metadata.ParseMarker(d, line); ok {
 			if currentMarker != nil {
-				mp.finalizeMarker(currentMarker, &currentContent, results, lineNumber-1)
+				finalizeMarker(currentMarker, &currentContent, d, results, lineNumber-1)
 			}
-			filename := strings.TrimSpace(match[1])
+			block = metadata.BlockLines(d, lines[i+1:])
 			if filename == "" {
 				results.Errors = append(results.Errors, ParseError{Line: lineNumber, Message: "Empty filename in marker", Severity: "error"})
 				currentMarker = nil
 				currentContent.Reset()
 				continue
 			}
-			currentMarker = &ParsedMarker{Filename: filename, StartLine: lineNumber}
+			currentMarker = &ParsedMarker{Filename: filename, StartLine: lineNumber, Attrs: attrs}
 			currentContent.Reset()
 			results.TotalMarkers++
-		} else if currentMarker != nil {
+			continue
+		}
+		if currentMarker != nil {
 			if currentContent.Len() > 0 {
 				currentContent.WriteByte('\n')
 			}
@@ -138,16 +161,16 @@ func (mp *MarkerParser) ParseMarkedReader(reader io.Reader, sourceName string) (
 	}
 
 	if currentMarker != nil {
-		mp.finalizeMarker(currentMarker, &currentContent, results, lineNumber)
+		finalizeMarker(currentMarker, &currentContent, d, results, len(lines))
 	}
 
-	return results, nil
+	return results
 }
 
 // finalizeMarker completes a marker and adds it to results.
-func (mp *MarkerParser) finalizeMarker(marker *ParsedMarker, content *strings.Builder, results *ParseResults, endLine int) {
+func finalizeMarker(marker *ParsedMarker, content *strings.Builder, d metadata.Dialect, results *ParseResults, endLine int) {
 	// Remove trailing empty lines
-	finalContent := strings.TrimRight(content.String(), "\n")
+	finalContent := strings.TrimRight(metadata.UnwrapContent(d, content.String()), "\n")
 
 	marker.Content = finalContent
 	marker.EndLine = endLine
@@ -160,23 +183,55 @@ func (mp *MarkerParser) finalizeMarker(marker *ParsedMarker, content *strings.Bu
 
 // ExtractFiles extracts all markers to files in the specified directory.
 func (mp *MarkerParser) ExtractFiles(markedFilePath, outputDir string, options ExtractOptions) (*ExtractResults, error) {
+	parseResults, err := mp.ParseMarkedFile(markedFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse marked file: %w", err)
+	}
+	return ExtractMarkers(parseResults, outputDir, options), nil
+}
+
+// CheckName refuses a file name that would escape the directory it is
+// extracted to: empty and absolute names and names with ".." components.
+func CheckName(name string) error {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return fmt.Errorf("refusing unsafe path %q", name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return fmt.Errorf("refusing unsafe path %q", name)
+		}
+	}
+	return nil
+}
+
+// ExtractMarkers writes parsed markers below outputDir according to options.
+// Every marker dialect extracts through it, so options behave identically.
+func ExtractMarkers(parseResults *ParseResults, outputDir string, options ExtractOptions) *ExtractResults {
 	result := &ExtractResults{
 		Success:        true,
 		ExtractedFiles: make([]string, 0),
 		Errors:         make([]string, 0),
 	}
 
-	parseResults, err := mp.ParseMarkedFile(markedFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse marked file: %w", err)
-	}
-
 	// Add parse errors to result
 	for _, parseErr := range parseResults.Errors {
 		result.Errors = append(result.Errors, fmt.Sprintf("Line %d: %s", parseErr.Line, parseErr.Message))
 	}
+	excerpts := parseResults.ProjectInfo().Excerpts()
 
 	for _, marker := range parseResults.Markers {
+		if marker.Filename == ProjectInfoMarker {
+			continue
+		}
+		if excerpts[marker.Filename] {
+			result.Errors = append(result.Errors, fmt.Sprintf("Skipped excerpt (diff hunks, not file content): %s", marker.Filename))
+			continue
+		}
+		if err := CheckName(marker.Filename); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			result.Success = false
+			continue
+		}
 		outputPath := filepath.Join(outputDir, marker.Filename)
 
 		// Check if file exists and overwrite is disabled
@@ -216,16 +271,51 @@ func (mp *MarkerParser) ExtractFiles(markedFilePath, outputDir string, options E
 		result.ExtractedFiles = append(result.ExtractedFiles, outputPath)
 	}
 
-	return result, nil
+	return result
 }
 
 // ValidateMarkers validates markers in a file and returns detailed information.
 func (mp *MarkerParser) ValidateMarkers(filePath string, strict bool) (*ValidationResults, error) {
-	parseResults, err := mp.ParseMarkedFile(filePath)
+	data, err := ReadArchive(filePath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
+	parseResults := ParseContent(data, mp.dialect)
+
+	// Strict mode: flag malformed marker-like lines that don't parse as markers
+	var malformed []ValidationError
+	if strict {
+		malformed = MalformedMarkers(data, mp.dialect)
+	}
+	return ValidateParsed(parseResults, malformed), nil
+}
 
+// MalformedMarkers returns a strict-mode error for every line that carries
+// the marker tokens of dialect d but does not parse as a marker. The blocks
+// of block dialects are content and are not checked.
+func MalformedMarkers(data []byte, d metadata.Dialect) []ValidationError {
+	start, end := metadata.MarkerTokens(d)
+	var malformed []ValidationError
+	lines := strings.Split(string(data), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if _, ok := d.ParseLine(line); ok {
+			i += metadata.BlockLines(d, lines[i+1:])
+			continue
+		}
+		if (start != "" && strings.Contains(line, start)) || (end != "" && strings.Contains(line, end)) {
+			malformed = append(malformed, ValidationError{Line: i + 1, Message: "Malformed marker line (strict mode)", Severity: "error"})
+		}
+	}
+	return malformed
+}
+
+// ValidateParsed checks parsed markers for duplicate, empty and invalid names.
+// Strict-mode errors found by the caller are reported along with parse errors.
+func ValidateParsed(parseResults *ParseResults, malformed []ValidationError) *ValidationResults {
 	validation := &ValidationResults{
 		IsValid:            len(parseResults.Errors) == 0,
 		Errors:             make([]ValidationError, 0),
@@ -242,25 +332,7 @@ func (mp *MarkerParser) ValidateMarkers(filePath string, strict bool) (*Validati
 		validation.Errors = append(validation.Errors, ValidationError(parseErr))
 	}
 
-	// Strict mode: flag malformed marker-like lines that don't match canonical regex
-	if strict {
-		startToken := fmt.Sprintf("//%s/", mp.fsChar)
-		endToken := fmt.Sprintf("/%s//", mp.fsChar)
-		file, err := os.Open(filePath)
-		if err == nil {
-			defer file.Close()
-			scanner := bufio.NewScanner(file)
-			lineNo := 0
-			for scanner.Scan() {
-				lineNo++
-				line := scanner.Text()
-				looksLike := strings.Contains(line, startToken) || strings.Contains(line, endToken) || (strings.Contains(line, mp.fsChar) && strings.Contains(line, "//"))
-				if looksLike && !mp.markerRegex.MatchString(line) {
-					validation.Errors = append(validation.Errors, ValidationError{Line: lineNo, Message: "Malformed marker line (strict mode)", Severity: "error"})
-				}
-			}
-		}
-	}
+	validation.Errors = append(validation.Errors, malformed...)
 
 	// Check for duplicates and validation issues
 	filenameCount := make(map[string]int)
@@ -273,7 +345,7 @@ func (mp *MarkerParser) ValidateMarkers(filePath string, strict bool) (*Validati
 		}
 
 		// Validate filename
-		if !mp.isValidFilename(marker.Filename) {
+		if !IsValidFilename(marker.Filename) {
 			validation.InvalidFilenames = append(validation.InvalidFilenames, marker.Filename)
 		}
 	}
@@ -297,7 +369,7 @@ func (mp *MarkerParser) ValidateMarkers(filePath string, strict bool) (*Validati
 		validation.IsValid = false
 	}
 
-	return validation, nil
+	return validation
 }
 
 // ValidationResults contains marker validation results.
@@ -322,8 +394,8 @@ type ValidationStatistics struct {
 	EmptyMarkers int `json:"emptyMarkers"`
 }
 
-// isValidFilename checks if a filename is valid for the current OS.
-func (mp *MarkerParser) isValidFilename(filename string) bool {
+// IsValidFilename checks if a filename is valid for the current OS.
+func IsValidFilename(filename string) bool {
 	if filename == "" {
 		return false
 	}
@@ -348,17 +420,51 @@ func (mp *MarkerParser) isValidFilename(filename string) bool {
 	return true
 }
 
+// MatchesExclude reports whether a relative path matches any exclude pattern,
+// either as a glob on the base name or full path, or as a plain substring.
+func MatchesExclude(relPath string, excludePatterns []string) bool {
+	for _, pattern := range excludePatterns {
+		if matched, _ := filepath.Match(pattern, filepath.Base(relPath)); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+		if strings.Contains(relPath, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
 // GenerateResults contains the results of directory consolidation.
 type GenerateResults struct {
-	Success    bool     `json:"success"`
-	TotalFiles int      `json:"totalFiles"`
-	TotalBytes int64    `json:"totalBytes"`
-	Errors     []string `json:"errors"`
+	Success     bool           `json:"success"`
+	TotalFiles  int            `json:"totalFiles"`
+	TotalBytes  int64          `json:"totalBytes"`
+	TotalTokens int            `json:"totalTokens"`
+	Tokenizer   string         `json:"tokenizer"`
+	FileTokens  map[string]int `json:"fileTokens"`
+	// DroppedFiles lists files left out to meet a token budget.
+	DroppedFiles []string `json:"droppedFiles,omitempty"`
+	Errors       []string `json:"errors"`
+}
+
+// NewGenerateResults returns empty results counted with tokens.DefaultTokenizer.
+func NewGenerateResults() *GenerateResults {
+	return &GenerateResults{Success: true, Tokenizer: tokens.DefaultTokenizer, FileTokens: map[string]int{}, Errors: []string{}}
 }
 
 // GenerateFromDirectory consolidates a directory into a marked file.
 func (mp *MarkerParser) GenerateFromDirectory(sourceDir, outputFile string, excludePatterns []string) (*GenerateResults, error) {
-	result := &GenerateResults{Success: true, Errors: []string{}}
+	return Generate(sourceDir, outputFile, excludePatterns, mp.dialect)
+}
+
+// Generate consolidates a directory into a marked file in dialect d. Dialects
+// other than the default one are declared in YAML frontmatter, unless they are
+// undeclared.
+func Generate(sourceDir, outputFile string, excludePatterns []string, d metadata.Dialect) (*GenerateResults, error) {
+	result := NewGenerateResults()
 
 	// Check if source directory exists
 	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
@@ -367,6 +473,7 @@ func (mp *MarkerParser) GenerateFromDirectory(sourceDir, outputFile string, excl
 
 	// First pass: collect files respecting excludes
 	fileList := []string{}
+	modes := map[string]os.FileMode{}
 	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("Error accessing %s: %v", path, err))
@@ -385,19 +492,15 @@ func (mp *MarkerParser) GenerateFromDirectory(sourceDir, outputFile string, excl
 			return nil
 		}
 
-		// Check exclusion patterns (glob and substring contains)
-		for _, pattern := range excludePatterns {
-			if matched, _ := filepath.Match(pattern, filepath.Base(relPath)); matched {
-				return nil
-			}
-			if matched, _ := filepath.Match(pattern, relPath); matched {
-				return nil
-			}
-			if strings.Contains(relPath, pattern) {
-				return nil
-			}
+		if MatchesExclude(relPath, excludePatterns) {
+			return nil
+		}
+		if _, err := d.Escape(relPath); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Skipped %s: %v", relPath, err))
+			return nil
 		}
 		fileList = append(fileList, relPath)
+		modes[relPath] = info.Mode()
 		return nil
 	})
 	if err != nil {
@@ -411,47 +514,43 @@ func (mp *MarkerParser) GenerateFromDirectory(sourceDir, outputFile string, excl
 	}
 	defer outFile.Close()
 
-	fsChar := string(rune(28))
-	header := fmt.Sprintf("//%s/ PROJECT_INFO /%s//\n", fsChar, fsChar)
-	header += fmt.Sprintf("Project: %s\n", filepath.Base(sourceDir))
-	header += fmt.Sprintf("Generated: %s\n", nowISO8601())
-	header += fmt.Sprintf("Total Files: %d\n", len(fileList))
-	header += fmt.Sprintf("Source: %s\n", sourceDir)
-	header += "Generator: lookatni-cli v1.1.0\n"
-	header += "MarkerSpec: v1\n"
-	header += "FS: 28\n"
-	header += "MarkerTokens: //\\x1C/ <path> /\\x1C//\n"
-	header += "Encoding: utf-8\n\n"
+	fm, err := metadata.FrontmatterFor(d)
+	if err != nil {
+		return nil, err
+	}
+	header := string(fm)
+	info := ProjectInfoHeader(filepath.Base(sourceDir), sourceDir, len(fileList))
+	info += "MarkerSpec: v1\n"
+	info += markerSpecLines(d)
+	info += "Encoding: utf-8\n\n"
+	header += metadata.FormatMarker(d, ProjectInfoMarker, nil) + "\n" + metadata.WrapContent(d, ProjectInfoMarker, info)
 	if _, err := outFile.WriteString(header); err != nil {
 		return nil, fmt.Errorf("failed to write header: %w", err)
 	}
 	result.TotalBytes += int64(len(header))
+	result.TotalTokens += tokens.Count(header)
 
-	for _, relPath := range fileList {
+	for i, relPath := range fileList {
 		abs := filepath.Join(sourceDir, relPath)
 		content, err := os.ReadFile(abs)
 		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("Failed to read %s: %v", relPath, err))
 			continue
 		}
-		marker := fmt.Sprintf("//%s/ %s /%s//\n", fsChar, relPath, fsChar)
+		marker := metadata.FormatMarker(d, relPath, MarkerAttrs(relPath, string(content), modes[relPath], i+1)) + "\n"
 		if _, err := outFile.WriteString(marker); err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("Failed to write marker for %s: %v", relPath, err))
 			continue
 		}
-		if _, err := outFile.Write(content); err != nil {
+		body := metadata.WrapContent(d, relPath, string(content))
+		if _, err := outFile.WriteString(body); err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("Failed to write content for %s: %v", relPath, err))
 			continue
 		}
-		if len(content) > 0 && content[len(content)-1] != '\n' {
-			if _, err := outFile.WriteString("\n"); err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("Failed to write newline for %s: %v", relPath, err))
-				continue
-			}
-			result.TotalBytes++
-		}
 		result.TotalFiles++
-		result.TotalBytes += int64(len(content)) + int64(len(marker))
+		result.TotalBytes += int64(len(body)) + int64(len(marker))
+		result.FileTokens[relPath] = tokens.Count(marker + body)
+		result.TotalTokens += result.FileTokens[relPath]
 	}
 
 	if len(result.Errors) > 0 {
@@ -461,6 +560,46 @@ func (mp *MarkerParser) GenerateFromDirectory(sourceDir, outputFile string, excl
 	return result, nil
 }
 
+// MarkerAttrs returns the attribute placeholder values of a file: its size,
+// content hash and language, plus its mode and 1-based index when non-zero.
+// Size and hash ignore trailing newlines, as ParsedMarker.Size does.
+func MarkerAttrs(filename, content string, mode os.FileMode, index int) map[string]string {
+	attrs := map[string]string{
+		metadata.SizePlaceholder:   metadata.FormatSize(int64(len(strings.TrimRight(content, "\n")))),
+		metadata.SHA256Placeholder: ContentHash(content),
+		metadata.LangPlaceholder:   metadata.LanguageOf(filename),
+	}
+	if mode != 0 {
+		attrs[metadata.ModePlaceholder] = fmt.Sprintf("%04o", mode.Perm())
+	}
+	if index > 0 {
+		attrs[metadata.IndexPlaceholder] = fmt.Sprint(index)
+	}
+	return attrs
+}
+
+// markerSpecLines describes the marker syntax in PROJECT_INFO, with control
+// characters written as \xNN escapes and the FS line for separator markers.
+func markerSpecLines(d metadata.Dialect) string {
+	var tokens strings.Builder
+	fs := -1
+	for _, r := range d.FormatMarker("<path>") {
+		if r < 0x20 {
+			if fs < 0 {
+				fs = int(r)
+			}
+			fmt.Fprintf(&tokens, "\\x%02X", r)
+			continue
+		}
+		tokens.WriteRune(r)
+	}
+	lines := ""
+	if fs >= 0 {
+		lines = fmt.Sprintf("FS: %d\n", fs)
+	}
+	return lines + fmt.Sprintf("MarkerTokens: %s\n", tokens.String())
+}
+
 func nowISO8601() string {
 	return time.Now().UTC().Format(time.RFC3339)
 }
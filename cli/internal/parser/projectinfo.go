@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BaseHashKey is the PROJECT_INFO key recording the content hash of each file
+// at generation time, used as the merge base when extracting.
+const BaseHashKey = "Base-SHA256"
+
+// CommitKey records the git commit an archive was generated from.
+const CommitKey = "Commit"
+
+// Changeset archives hold only the files that changed since a base version.
+// ChangesetKey names the base, DeletedKey lists each path removed since then
+// and ExcerptKey each entry stored as diff hunks rather than whole content.
+const (
+	ChangesetKey = "Changeset"
+	DeletedKey   = "Deleted"
+	ExcerptKey   = "Excerpt"
+)
+
+// ModeKey and ModTimeKey record file permissions (octal) and modification times
+// (RFC 3339) for archives imported from formats that carry them.
+const (
+	ModeKey    = "Mode"
+	ModTimeKey = "Mtime"
+)
+
+// Generator names this tool in the PROJECT_INFO of the archives it writes.
+const Generator = "lookatni-cli v1.1.0"
+
+// ProjectInfoHeader returns the lines every written PROJECT_INFO section starts
+// with: project name, generation time, file count, source and generator. An
+// empty project or source is left out. Writers that learn the file count later
+// pass 0 and let archive.RefreshInfo fill it in.
+func ProjectInfoHeader(project, source string, files int) string {
+	var b strings.Builder
+	if project != "" {
+		fmt.Fprintf(&b, "Project: %s\n", project)
+	}
+	fmt.Fprintf(&b, "Generated: %s\n", nowISO8601())
+	fmt.Fprintf(&b, "Total Files: %d\n", files)
+	if source != "" {
+		fmt.Fprintf(&b, "Source: %s\n", source)
+	}
+	fmt.Fprintf(&b, "Generator: %s\n", Generator)
+	return b.String()
+}
+
+// ProjectInfo holds the "Key: value" lines of a PROJECT_INFO section. Keys may repeat.
+type ProjectInfo map[string][]string
+
+// ParseProjectInfo parses the content of a PROJECT_INFO section.
+func ParseProjectInfo(content string) ProjectInfo {
+	info := ProjectInfo{}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		info[key] = append(info[key], strings.TrimSpace(value))
+	}
+	return info
+}
+
+// Get returns the first value recorded for key.
+func (pi ProjectInfo) Get(key string) string {
+	if values := pi[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// BaseHashes returns the recorded base content hash of each file, keyed by path.
+func (pi ProjectInfo) BaseHashes() map[string]string {
+	return pi.PathValues(BaseHashKey)
+}
+
+// Excerpts returns the entries stored as diff hunks, which are not extracted.
+func (pi ProjectInfo) Excerpts() map[string]bool {
+	excerpts := map[string]bool{}
+	for _, name := range pi[ExcerptKey] {
+		excerpts[name] = true
+	}
+	return excerpts
+}
+
+// PathValues returns the values of "Key: value path" lines, keyed by path.
+func (pi ProjectInfo) PathValues(key string) map[string]string {
+	values := map[string]string{}
+	for _, line := range pi[key] {
+		value, path, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(path)] = value
+	}
+	return values
+}
+
+// ProjectInfo returns the parsed PROJECT_INFO section, or an empty ProjectInfo if absent.
+func (r *ParseResults) ProjectInfo() ProjectInfo {
+	for _, marker := range r.Markers {
+		if marker.Filename == ProjectInfoMarker {
+			return ParseProjectInfo(marker.Content)
+		}
+	}
+	return ProjectInfo{}
+}
+
+// ContentHash returns the hex SHA-256 of content as it appears in a parsed marker,
+// i.e. with trailing newlines trimmed, so files and archive entries compare equal.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(strings.TrimRight(content, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatBaseHashes renders one BaseHashKey line per file, sorted by path.
+func FormatBaseHashes(hashes map[string]string) string {
+	return FormatPathValues(BaseHashKey, hashes)
+}
+
+// FormatPathValues renders one "Key: value path" line per path, sorted by path.
+func FormatPathValues(key string, values map[string]string) string {
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "%s: %s %s\n", key, values[path], path)
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+// Package sfx writes self-extracting archives: POSIX sh scripts that recreate
+// a file tree without lookatni. Text files are stored in quoted heredocs and
+// binary files as base64 decoded with base64 -d; every file gets its mode.
+package sfx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// File is one file of a self-extracting script.
+type File struct {
+	// Name is the slash-separated path relative to the target directory.
+	Name    string
+	Content []byte
+	Mode    os.FileMode
+}
+
+// header is the start of every script; the usage lines are printed by --help.
+const header = `#!/bin/sh
+# Usage: sh %[1]s [--dry-run] [--target dir]
+# Recreates %[2]d files of %[3]s below dir, or the current directory.
+# Self-extracting archive written by lookatni-cli; needs sh, mkdir, cat, chmod
+# and, for binary files, base64.
+set -eu
+
+target=.
+dry_run=false
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--dry-run) dry_run=true ;;
+	--target)
+		[ $# -ge 2 ] || { echo "--target requires a directory" >&2; exit 2; }
+		target=$2
+		shift
+		;;
+	--target=*) target=${1#--target=} ;;
+	-h | --help) sed -n '2,3p' "$0"; exit 0 ;;
+	*) echo "unknown argument: $1" >&2; exit 2 ;;
+	esac
+	shift
+done
+
+# lookatni_safe path: refuses paths that would escape the target.
+lookatni_safe() {
+	case "$1" in
+	/* | ../* | */../* | */.. | ..) echo "refusing unsafe path: $1" >&2; exit 1 ;;
+	esac
+}
+
+# lookatni_file path mode encoding: writes standard input to path. Text
+# without a final newline is marked "text-n", as the heredoc adds one.
+lookatni_file() {
+	lookatni_safe "$1"
+	if [ "$dry_run" = true ]; then
+		echo "$2 $1"
+		cat >/dev/null
+		return
+	fi
+	file=$target/$1
+	mkdir -p -- "$(dirname -- "$file")"
+	case "$3" in
+	base64) base64 -d >"$file" ;;
+	text-n) printf '%%s' "$(cat)" >"$file" ;;
+	*) cat >"$file" ;;
+	esac
+	chmod "$2" "$file"
+}
+
+`
+
+// Write renders files as a script recreating them; project names them in the
+// script's usage. It refuses absolute paths and paths with ".." components.
+func Write(w io.Writer, script, project string, files []File) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, header, script, len(files), project)
+	// Check every path before writing any file
+	b.WriteString("for name in")
+	for _, f := range files {
+		if err := parser.CheckName(f.Name); err != nil {
+			return err
+		}
+		b.WriteString(" \\\n\t" + quote(f.Name))
+	}
+	b.WriteString("; do\n\tlookatni_safe \"$name\"\ndone\n\n")
+	for _, f := range files {
+		encoding, body := encode(f.Content)
+		delim := delimiter(body)
+		fmt.Fprintf(&b, "lookatni_file %s %04o %s <<'%s'\n%s%s\n", quote(f.Name), f.Mode.Perm(), encoding, delim, body, delim)
+	}
+	b.WriteString("\n[ \"$dry_run\" = true ] || echo \"Extracted " + fmt.Sprint(len(files)) + " files into $target\"\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// ConvertFile replaces the archive at path with a script recreating its files.
+// Content and modes are read from sourceDir, where the archive was generated
+// from, so that trailing newlines survive; files missing there are written from
+// the archive with mode 0644. It returns the size of the script.
+func ConvertFile(path, sourceDir string) (int64, error) {
+	a, err := archive.Load(path)
+	if err != nil {
+		return 0, err
+	}
+	var files []File
+	for _, e := range a.Files() {
+		f := File{Name: e.Name, Content: []byte(e.Body()), Mode: 0o644}
+		source := filepath.Join(sourceDir, filepath.FromSlash(e.Name))
+		if info, err := os.Stat(source); err == nil && info.Mode().IsRegular() {
+			if f.Content, err = os.ReadFile(source); err != nil {
+				return 0, fmt.Errorf("failed to read %s: %w", source, err)
+			}
+			f.Mode = info.Mode()
+		}
+		files = append(files, f)
+	}
+
+	var b bytes.Buffer
+	if err := Write(&b, filepath.Base(path), filepath.Base(filepath.Clean(sourceDir)), files); err != nil {
+		return 0, err
+	}
+	if err := archive.WriteFileAtomic(path, b.Bytes()); err != nil {
+		return 0, err
+	}
+	return int64(b.Len()), os.Chmod(path, 0o755)
+}
+
+// encode returns the heredoc body of content, ending in a newline, and how the
+// script decodes it.
+func encode(content []byte) (string, string) {
+	if bytes.IndexByte(content, 0) >= 0 || !utf8.Valid(content) {
+		encoded := base64.StdEncoding.EncodeToString(content)
+		var b strings.Builder
+		for len(encoded) > 76 {
+			b.WriteString(encoded[:76] + "\n")
+			encoded = encoded[76:]
+		}
+		b.WriteString(encoded + "\n")
+		return "base64", b.String()
+	}
+	if len(content) == 0 {
+		return "text", ""
+	}
+	if content[len(content)-1] != '\n' {
+		return "text-n", string(content) + "\n"
+	}
+	return "text", string(content)
+}
+
+// delimiter returns a heredoc delimiter that no line of body equals.
+func delimiter(body string) string {
+	delim := "LOOKATNI_EOF"
+	for n := 1; strings.Contains("\n"+body, "\n"+delim+"\n"); n++ {
+		delim = fmt.Sprintf("LOOKATNI_EOF_%d", n)
+	}
+	return delim
+}
+
+// quote returns s as a single-quoted shell word.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
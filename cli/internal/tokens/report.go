@@ -0,0 +1,123 @@
+package tokens
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileCount holds the size of one file.
+type FileCount struct {
+	Path   string `json:"path"`
+	Bytes  int    `json:"bytes"`
+	Lines  int    `json:"lines"`
+	Tokens int    `json:"tokens"`
+}
+
+// ExtensionCount aggregates the files sharing an extension.
+type ExtensionCount struct {
+	Extension string `json:"extension"`
+	Files     int    `json:"files"`
+	Bytes     int    `json:"bytes"`
+	Tokens    int    `json:"tokens"`
+}
+
+// Report summarizes the size of a set of files under one tokenizer.
+type Report struct {
+	Tokenizer   string      `json:"tokenizer"`
+	Files       []FileCount `json:"files"`
+	TotalFiles  int         `json:"totalFiles"`
+	TotalBytes  int         `json:"totalBytes"`
+	TotalLines  int         `json:"totalLines"`
+	TotalTokens int         `json:"totalTokens"`
+}
+
+// NewReport counts every file in files, keyed by path, sorted by path.
+func NewReport(t Tokenizer, files map[string]string) *Report {
+	r := &Report{Tokenizer: t.Name(), Files: make([]FileCount, 0, len(files))}
+	for path, content := range files {
+		fc := FileCount{Path: path, Bytes: len(content), Tokens: t.Count(content)}
+		if content != "" {
+			fc.Lines = strings.Count(content, "\n") + 1
+		}
+		r.Files = append(r.Files, fc)
+		r.TotalFiles++
+		r.TotalBytes += fc.Bytes
+		r.TotalLines += fc.Lines
+		r.TotalTokens += fc.Tokens
+	}
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+	return r
+}
+
+// Largest returns up to n files with the most tokens.
+func (r *Report) Largest(n int) []FileCount {
+	files := append([]FileCount(nil), r.Files...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Tokens > files[j].Tokens })
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files
+}
+
+// ByExtension aggregates the report by file extension, most tokens first.
+func (r *Report) ByExtension() []ExtensionCount {
+	index := map[string]int{}
+	var exts []ExtensionCount
+	for _, f := range r.Files {
+		ext := strings.ToLower(filepath.Ext(f.Path))
+		if ext == "" {
+			ext = "(none)"
+		}
+		i, ok := index[ext]
+		if !ok {
+			i = len(exts)
+			index[ext] = i
+			exts = append(exts, ExtensionCount{Extension: ext})
+		}
+		exts[i].Files++
+		exts[i].Bytes += f.Bytes
+		exts[i].Tokens += f.Tokens
+	}
+	sort.SliceStable(exts, func(i, j int) bool { return exts[i].Tokens > exts[j].Tokens })
+	return exts
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteList writes one line per file: tokens, bytes, lines and path.
+func (r *Report) WriteList(w io.Writer) error {
+	fmt.Fprintf(w, "%8s %10s %7s  %s\n", "TOKENS", "BYTES", "LINES", "PATH")
+	for _, f := range r.Files {
+		fmt.Fprintf(w, "%8d %10d %7d  %s\n", f.Tokens, f.Bytes, f.Lines, f.Path)
+	}
+	_, err := fmt.Fprintf(w, "%8d %10d %7d  total (%d files, %s)\n", r.TotalTokens, r.TotalBytes, r.TotalLines, r.TotalFiles, r.Tokenizer)
+	return err
+}
+
+// WriteStats writes totals, a per-extension breakdown and the largest files.
+func (r *Report) WriteStats(w io.Writer) error {
+	fmt.Fprintf(w, "Files:     %d\n", r.TotalFiles)
+	fmt.Fprintf(w, "Bytes:     %d\n", r.TotalBytes)
+	fmt.Fprintf(w, "Lines:     %d\n", r.TotalLines)
+	fmt.Fprintf(w, "Tokens:    ~%d (%s)\n", r.TotalTokens, r.Tokenizer)
+
+	fmt.Fprintf(w, "\nBy extension:\n")
+	for _, e := range r.ByExtension() {
+		fmt.Fprintf(w, "  %-12s %5d files %10d bytes %8d tokens\n", e.Extension, e.Files, e.Bytes, e.Tokens)
+	}
+
+	fmt.Fprintf(w, "\nLargest files:\n")
+	for _, f := range r.Largest(10) {
+		fmt.Fprintf(w, "  %8d tokens  %s\n", f.Tokens, f.Path)
+	}
+	return nil
+}
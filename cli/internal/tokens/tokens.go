@@ -0,0 +1,214 @@
+// Package tokens estimates how many LLM tokens a piece of text will cost.
+package tokens
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer names accepted by Get.
+const (
+	CL100K = "cl100k"
+	Chars4 = "chars4"
+)
+
+// DefaultTokenizer is used when no tokenizer is requested.
+const DefaultTokenizer = CL100K
+
+// Tokenizer counts the tokens a model would see for a piece of text.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+var tokenizers = map[string]Tokenizer{
+	CL100K: cl100k{},
+	Chars4: chars4{},
+}
+
+// Get returns the tokenizer registered under name; "" selects DefaultTokenizer.
+func Get(name string) (Tokenizer, error) {
+	if name == "" {
+		name = DefaultTokenizer
+	}
+	t, ok := tokenizers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return t, nil
+}
+
+// Names lists the available tokenizers.
+func Names() []string {
+	names := make([]string, 0, len(tokenizers))
+	for name := range tokenizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Count estimates tokens for text with DefaultTokenizer.
+func Count(text string) int {
+	return tokenizers[DefaultTokenizer].Count(text)
+}
+
+// chars4 is the classic one token per four characters rule of thumb.
+type chars4 struct{}
+
+func (chars4) Name() string { return Chars4 }
+
+func (chars4) Count(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// cl100k approximates the cl100k_base BPE used by GPT-4 class models. Text is
+// pre-tokenized exactly as cl100k does; each piece is then costed from its
+// shape instead of the merge table, which keeps the binary small and is close
+// enough for budgeting source code and English prose.
+type cl100k struct{}
+
+func (cl100k) Name() string { return CL100K }
+
+func (cl100k) Count(text string) int {
+	total := 0
+	for _, piece := range pretokenize(text) {
+		total += pieceCost(piece)
+	}
+	return total
+}
+
+// pieceCost estimates how many BPE tokens one pre-tokenized piece merges into.
+func pieceCost(piece []rune) int {
+	letters, ascii := 0, true
+	for _, r := range piece {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+		if r > unicode.MaxASCII {
+			ascii = false
+		}
+	}
+	switch {
+	case allSpace(piece):
+		// Runs of spaces and newlines have dedicated tokens
+		return 1
+	case !ascii:
+		// Non-Latin scripts average close to one token per character
+		return max(1, len(piece)-len(piece)/4)
+	case letters > 0:
+		// Common words are single tokens; long identifiers split every ~4 chars
+		if len(piece) <= 7 {
+			return 1
+		}
+		return (len(piece) + 3) / 4
+	case unicode.IsNumber(piece[0]):
+		return 1
+	default:
+		// Punctuation runs merge in pairs
+		return (len(piece) + 1) / 2
+	}
+}
+
+// pretokenize splits text like the cl100k regex:
+//
+//	'(?i:[sdmt]|ll|ve|re)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func pretokenize(text string) [][]rune {
+	rs := []rune(text)
+	var pieces [][]rune
+	for i := 0; i < len(rs); {
+		n := matchPiece(rs, i)
+		pieces = append(pieces, rs[i:i+n])
+		i += n
+	}
+	return pieces
+}
+
+// matchPiece returns the length of the piece starting at rs[i].
+func matchPiece(rs []rune, i int) int {
+	r := rs[i]
+	rest := len(rs) - i
+
+	// Contractions
+	if r == '\'' && rest > 1 {
+		for _, c := range []string{"ll", "ve", "re", "s", "d", "m", "t"} {
+			if rest > len(c) && strings.EqualFold(string(rs[i+1:i+1+len(c)]), c) {
+				return 1 + len(c)
+			}
+		}
+	}
+
+	// Optional non-letter prefix followed by letters
+	start := i
+	if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\r' && r != '\n' && rest > 1 && unicode.IsLetter(rs[i+1]) {
+		start = i + 1
+	}
+	if unicode.IsLetter(rs[start]) {
+		j := start
+		for j < len(rs) && unicode.IsLetter(rs[j]) {
+			j++
+		}
+		return j - i
+	}
+
+	// Up to three digits
+	if unicode.IsNumber(r) {
+		j := i
+		for j < len(rs) && j-i < 3 && unicode.IsNumber(rs[j]) {
+			j++
+		}
+		return j - i
+	}
+
+	// Optional space, punctuation run, trailing newlines
+	j := i
+	if r == ' ' && rest > 1 && isPunct(rs[i+1]) {
+		j++
+	}
+	if isPunct(rs[j]) {
+		for j < len(rs) && isPunct(rs[j]) {
+			j++
+		}
+		for j < len(rs) && (rs[j] == '\r' || rs[j] == '\n') {
+			j++
+		}
+		return j - i
+	}
+
+	// Whitespace: up to the last newline, else leave one space for the next word
+	j = i
+	lastNewline := -1
+	for j < len(rs) && isSpace(rs[j]) {
+		if rs[j] == '\r' || rs[j] == '\n' {
+			lastNewline = j
+		}
+		j++
+	}
+	if lastNewline >= 0 {
+		return lastNewline + 1 - i
+	}
+	if j < len(rs) && j-i > 1 {
+		return j - i - 1
+	}
+	return max(1, j-i)
+}
+
+func isSpace(r rune) bool {
+	return unicode.IsSpace(r)
+}
+
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func allSpace(rs []rune) bool {
+	for _, r := range rs {
+		if !isSpace(r) {
+			return false
+		}
+	}
+	return true
+}
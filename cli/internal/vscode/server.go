@@ -8,6 +8,9 @@ import (
 	"strconv"
 
 	l "github.com/kubex-ecosystem/logz"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/adaptive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/merge"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/mirror"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/module/logger"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
 	"github.com/kubex-ecosystem/lookatni-file-markers/internal/transpiler"
@@ -18,6 +21,7 @@ type Server struct {
 	logger     logger.GLog[l.Logger]
 	port       int
 	parser     *parser.MarkerParser
+	reader     *adaptive.AdaptiveParser
 	transpiler *transpiler.Transpiler
 }
 
@@ -49,6 +53,7 @@ func NewServer(log logger.GLog[l.Logger], port int) *Server {
 		logger:     log,
 		port:       port,
 		parser:     parser.New(),
+		reader:     adaptive.New(),
 		transpiler: transpiler.New(htmlTemplate),
 	}
 }
@@ -97,6 +102,8 @@ type GenerateRequest struct {
 	SourceDir       string   `json:"sourceDir"`
 	OutputFile      string   `json:"outputFile"`
 	ExcludePatterns []string `json:"excludePatterns"`
+	Armor           bool     `json:"armor"`
+	RecordBase      bool     `json:"recordBase,omitempty"`
 }
 
 // APIResponse represents a standard API response.
@@ -121,12 +128,33 @@ func (s *Server) handleExtract(w http.ResponseWriter, r *http.Request) {
 
 	s.logger.Log("debug", "Extract request: %s -> %s", req.MarkedFile, req.OutputDir)
 
-	result, err := s.parser.ExtractFiles(req.MarkedFile, req.OutputDir, req.Options)
+	var result *parser.ExtractResults
+	var err error
+	if req.Options.Merge {
+		result, err = merge.ExtractWithMerge(req.MarkedFile, req.OutputDir, req.Options)
+	} else {
+		result, err = s.reader.ExtractFiles(req.MarkedFile, req.OutputDir, req.Options)
+	}
 	if err != nil {
 		s.sendError(w, fmt.Sprintf("Extraction failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if req.Options.Mirror {
+		parsed, _, err := s.reader.ParseMarkedFile(req.MarkedFile)
+		if err != nil {
+			s.sendError(w, fmt.Sprintf("Mirror failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pruned, err := mirror.Apply(req.OutputDir, parsed, result, req.Options.Scope, req.Options.DryRun)
+		if err != nil {
+			s.sendError(w, fmt.Sprintf("Mirror failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		result.DeletedFiles = pruned.DeletedFiles
+		result.Errors = append(result.Errors, pruned.Errors...)
+	}
+
 	s.sendSuccess(w, result)
 }
 
@@ -145,7 +173,7 @@ func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
 
 	s.logger.Log("debug", "Validate request: %s", req.MarkedFile)
 
-    result, err := s.parser.ValidateMarkers(req.MarkedFile, req.Strict)
+    result, err := s.reader.ValidateMarkers(req.MarkedFile, req.Strict)
 	if err != nil {
 		s.sendError(w, fmt.Sprintf("Validation failed: %v", err), http.StatusInternalServerError)
 		return
@@ -175,6 +203,19 @@ func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.RecordBase {
+		if _, err := merge.RecordBases(req.OutputFile); err != nil {
+			s.logger.Log("warn", fmt.Sprintf("Failed to record merge bases: %v", err))
+		}
+	}
+
+	if req.Armor {
+		if result.TotalBytes, err = parser.ArmorFile(req.OutputFile); err != nil {
+			s.sendError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	s.sendSuccess(w, result)
 }
 
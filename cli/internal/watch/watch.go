@@ -0,0 +1,133 @@
+// Package watch triggers debounced rebuilds when files below a directory change.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/ignore"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// DefaultDebounce is how long the tree must stay quiet before a rebuild starts.
+const DefaultDebounce = 300 * time.Millisecond
+
+// Options configures a Watcher.
+type Options struct {
+	Debounce        time.Duration
+	ExcludePatterns []string
+	// SkipPaths are files whose changes never trigger a rebuild, such as the
+	// archive being written and its cache. The temporary files they are
+	// replaced through are skipped with them.
+	SkipPaths []string
+}
+
+// Watch blocks until ctx is done, calling rebuild once changes below sourceDir
+// have settled. Excluded paths and version control directories are not watched.
+// Errors returned by rebuild are passed to onError and do not stop watching.
+func Watch(ctx context.Context, sourceDir string, opts Options, rebuild func() error, onError func(error)) error {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	var skip []string
+	for _, p := range opts.SkipPaths {
+		if abs, err := filepath.Abs(p); err == nil {
+			skip = append(skip, abs)
+		}
+	}
+	skipped := func(name string) bool {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return false
+		}
+		for _, p := range skip {
+			if abs == p || archive.IsTempFile(abs, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	matcher := ignore.New()
+	excluded := func(path string, isDir bool) bool {
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil || rel == "." {
+			return false
+		}
+		return matcher.Match(rel, isDir) || parser.MatchesExclude(rel, opts.ExcludePatterns)
+	}
+	addTree := func(root string) error {
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if excluded(path, true) {
+				return filepath.SkipDir
+			}
+			return watcher.Add(path)
+		})
+	}
+	if err := addTree(sourceDir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", sourceDir, err)
+	}
+
+	timer := time.NewTimer(opts.Debounce)
+	timer.Stop()
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if skipped(event.Name) {
+				continue
+			}
+			info, statErr := os.Stat(event.Name)
+			isDir := statErr == nil && info.IsDir()
+			if excluded(event.Name, isDir) {
+				continue
+			}
+			if event.Has(fsnotify.Create) && isDir {
+				if err := addTree(event.Name); err != nil && onError != nil {
+					onError(fmt.Errorf("failed to watch %s: %w", event.Name, err))
+				}
+			}
+			pending = true
+			timer.Reset(opts.Debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if onError != nil {
+				onError(err)
+			}
+
+		case <-timer.C:
+			if !pending {
+				continue
+			}
+			pending = false
+			if err := rebuild(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
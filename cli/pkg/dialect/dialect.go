@@ -0,0 +1,51 @@
+// Package dialect lets programs outside this module add marker dialects. A
+// dialect registered here is the same one the CLI commands see: detection,
+// parsing, generation, validation and conversion all accept it, so a program
+// that registers its dialects and then runs the commands of cmd/cli gets a
+// lookatni that reads and writes them.
+package dialect
+
+import "github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+
+// Dialect is a marker syntax.
+type Dialect = metadata.Dialect
+
+// AttributeDialect is implemented by dialects whose markers carry attribute
+// placeholders such as {size} or {index} besides the filename.
+type AttributeDialect = metadata.AttributeDialect
+
+// BlockDialect is implemented by dialects that enclose each file's content in
+// a block after its marker line.
+type BlockDialect = metadata.BlockDialect
+
+// UndeclaredDialect is implemented by dialects whose archives are written
+// without frontmatter.
+type UndeclaredDialect = metadata.UndeclaredDialect
+
+// MarkerConfig describes a dialect in frontmatter.
+type MarkerConfig = metadata.MarkerConfig
+
+// Detection is the dialect chosen for an archive by Detect.
+type Detection = metadata.Detection
+
+// Register makes d available by name to every command. It panics if the name
+// is empty or already registered.
+func Register(d Dialect) {
+	metadata.RegisterDialect(d)
+}
+
+// Lookup returns the registered dialect called name.
+func Lookup(name string) (Dialect, bool) {
+	return metadata.LookupDialect(name)
+}
+
+// List returns the registered dialects, default first and the rest by name.
+func List() []Dialect {
+	return metadata.Dialects()
+}
+
+// Detect chooses the dialect of archive content from its frontmatter or, without
+// it, from the marker lines it holds.
+func Detect(content []byte) (Detection, error) {
+	return metadata.Detect(content)
+}
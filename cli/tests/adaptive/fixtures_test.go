@@ -0,0 +1,119 @@
+// Package adaptive contains tests for the adaptive package.
+package adaptive
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	ad "github.com/kubex-ecosystem/lookatni-file-markers/internal/adaptive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// validFixtures maps each fixture in spec/fixtures/valid to its files.
+var validFixtures = map[string]map[string]string{
+	"simple-two-files.lkt":     {"README.md": "# Sample Project\n\nHello from LookAtni.", "src/index.js": "console.log('ok');"},
+	"html-frontmatter.lkt":     {"README.md": "# Hello", "src/index.js": "console.log('ok')"},
+	"markdown-frontmatter.lkt": {"README.md": "# Hello", "docs/guide.md": "Guide here"},
+	"code-frontmatter.lkt":     {"main.go": "package main", "lib/util.go": "package lib"},
+}
+
+const fixturesDir = "../../../spec/fixtures/valid"
+
+func TestValidFixturesParse(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join(fixturesDir, "*.lkt"))
+	if err != nil || len(names) != len(validFixtures) {
+		t.Fatalf("fixtures = %v (%v), want %d", names, err, len(validFixtures))
+	}
+	for _, name := range names {
+		want := validFixtures[filepath.Base(name)]
+		results, _, err := ad.New().ParseMarkedFile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := map[string]string{}
+		for _, m := range results.Markers {
+			got[m.Filename] = m.Content
+		}
+		if len(got) != len(want) || len(results.Errors) != 0 {
+			t.Errorf("%s: markers %v, errors %v", name, got, results.Errors)
+		}
+		for file, content := range want {
+			if got[file] != content {
+				t.Errorf("%s: %s = %q, want %q", name, file, got[file], content)
+			}
+		}
+
+		v, err := ad.New().ValidateMarkers(name, true)
+		if err != nil || !v.IsValid || len(v.Errors) != 0 {
+			t.Errorf("%s: validation %+v %v", name, v, err)
+		}
+	}
+}
+
+func TestValidFixturesExtractOptions(t *testing.T) {
+	for fixture, files := range validFixtures {
+		markedFile := filepath.Join(fixturesDir, fixture)
+		extract := func(dir string, options parser.ExtractOptions) *parser.ExtractResults {
+			t.Helper()
+			res, err := ad.New().ExtractFiles(markedFile, dir, options)
+			if err != nil {
+				t.Fatalf("%s: %v", fixture, err)
+			}
+			return res
+		}
+
+		// Dry runs write nothing
+		dir := t.TempDir()
+		if res := extract(dir, parser.ExtractOptions{DryRun: true, CreateDirs: true}); len(res.ExtractedFiles) != len(files) {
+			t.Errorf("%s dry run: %v", fixture, res.ExtractedFiles)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%s dry run wrote files", fixture)
+		}
+
+		res := extract(dir, parser.ExtractOptions{CreateDirs: true})
+		if len(res.ExtractedFiles) != len(files) || len(res.Errors) != 0 {
+			t.Fatalf("%s: %+v", fixture, res)
+		}
+		for file, content := range files {
+			data, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil || string(data) != content {
+				t.Errorf("%s: %s = %q (%v)", fixture, file, data, err)
+			}
+		}
+
+		// Existing files are kept unless Overwrite is set
+		var first string
+		for file := range files {
+			first = file
+			os.WriteFile(filepath.Join(dir, file), []byte("local"), 0o644)
+		}
+		res = extract(dir, parser.ExtractOptions{CreateDirs: true})
+		if len(res.ExtractedFiles) != 0 || len(res.Errors) != len(files) || !strings.Contains(res.Errors[0], "use --overwrite") {
+			t.Errorf("%s without overwrite: %+v", fixture, res)
+		}
+		res = extract(dir, parser.ExtractOptions{Overwrite: true, CreateDirs: true})
+		if data, _ := os.ReadFile(filepath.Join(dir, first)); len(res.ExtractedFiles) != len(files) || string(data) != files[first] {
+			t.Errorf("%s with overwrite: %+v", fixture, res)
+		}
+
+		// Directories that cannot be created are reported, not ignored
+		blocked := t.TempDir()
+		var nested []string
+		for file := range files {
+			if d := filepath.Dir(file); d != "." {
+				nested = append(nested, file)
+				os.WriteFile(filepath.Join(blocked, d), []byte("not a dir"), 0o644)
+			}
+		}
+		res = extract(blocked, parser.ExtractOptions{CreateDirs: true})
+		if len(nested) > 0 && (res.Success || !slices.ContainsFunc(res.Errors, func(e string) bool {
+			return strings.Contains(e, "Failed to create directory")
+		})) {
+			t.Errorf("%s with blocked directory: %+v", fixture, res)
+		}
+	}
+}
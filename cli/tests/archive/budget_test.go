@@ -0,0 +1,45 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+)
+
+func TestApplyBudgetPrefersPriorityThenEntryPoints(t *testing.T) {
+	body := strings.Repeat("some words here\n", 200)
+	var b strings.Builder
+	for _, name := range []string{"docs/guide.md", "src/util.go", "src/main.go", "src/core.go"} {
+		b.WriteString("//\x1C/ " + name + " /\x1C//\n" + body)
+	}
+	path := filepath.Join(t.TempDir(), "out.lkt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := ar.ApplyBudget(path, ar.BudgetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	perFile := full.FileTokens["src/core.go"]
+
+	result, err := ar.ApplyBudget(path, ar.BudgetOptions{MaxTokens: 2*perFile + perFile/2, Priority: []string{"core.go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalTokens > 2*perFile+perFile/2 {
+		t.Errorf("archive has %d tokens, over the budget", result.TotalTokens)
+	}
+	if _, ok := result.FileTokens["src/core.go"]; !ok {
+		t.Error("priority file was dropped")
+	}
+	if _, ok := result.FileTokens["src/main.go"]; !ok {
+		t.Error("entry point was dropped")
+	}
+	if strings.Join(result.Dropped, ",") != "docs/guide.md,src/util.go" {
+		t.Errorf("dropped = %v", result.Dropped)
+	}
+}
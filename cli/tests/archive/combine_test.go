@@ -0,0 +1,160 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/adaptive"
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/mirror"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestCombineConflictPolicies(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a := write("a.lkt", "//\x1C/ x.txt /\x1C//\none\n//\x1C/ same.txt /\x1C//\nsame\n")
+	// b uses another dialect, declared in frontmatter
+	b := write("b.lkt", "---\nlookatni:\n  pattern: \"<!-- FILE: {filename} -->\"\n  start: \"<!-- FILE: \"\n  end: \" -->\"\n  format: html\n---\n"+
+		"<!-- FILE: x.txt -->\ntwo\n<!-- FILE: same.txt -->\nsame\n")
+	inputs := []ar.CombineInput{{Path: a}, {Path: b}}
+	out := filepath.Join(dir, "out.lkt")
+
+	if _, err := ar.Combine(inputs, out, ar.FailOnConflict); err == nil {
+		t.Fatal("expected fail policy to reject the conflicting x.txt")
+	}
+
+	contents := func() map[string]string {
+		combined, err := ar.Load(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, e := range combined.Files() {
+			got[e.Name] = e.Content()
+		}
+		return got
+	}
+
+	if _, err := ar.Combine(inputs, out, ar.LastWins); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(); got["x.txt"] != "two" || len(got) != 2 {
+		t.Errorf("last-wins produced %v", got)
+	}
+
+	result, err := ar.Combine(inputs, out, ar.KeepBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(); got["x.txt"] != "one" || got["x~2.txt"] != "two" || got["same.txt"] != "same" {
+		t.Errorf("keep-both-renamed produced %v", got)
+	}
+	if result.Renamed["x.txt"] != "x~2.txt" {
+		t.Errorf("renamed = %v", result.Renamed)
+	}
+
+	inputs[1].Prefix = "lib/"
+	if _, err := ar.Combine(inputs, out, ar.FailOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(); got["lib/x.txt"] != "two" || len(got) != 4 {
+		t.Errorf("prefixed merge produced %v", got)
+	}
+}
+
+func TestCombineWritesOneHeader(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []ar.CombineInput
+	for _, name := range []string{"a", "b"} {
+		p := filepath.Join(dir, name+".lkt")
+		content := "//\x1C/ PROJECT_INFO /\x1C//\nProject: " + name + "\nGenerator: lookatni-cli v0.9." + name + "\n//\x1C/ " + name + ".txt /\x1C//\n" + name + "\n"
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, ar.CombineInput{Path: p})
+	}
+	out := filepath.Join(dir, "out.lkt")
+	if _, err := ar.Combine(inputs, out, ar.FailOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	combined, err := ar.Load(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := combined.Info()
+	if !slices.Equal(info["Generator"], []string{parser.Generator}) || !slices.Equal(info["Project"], []string{"a", "b"}) || info.Get("Total Files") != "2" {
+		t.Errorf("unexpected PROJECT_INFO: %v", info)
+	}
+}
+
+func TestCombinePrefixesChangesetPaths(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a := write("a.lkt", "//\x1C/ PROJECT_INFO /\x1C//\nChangeset: v1\nDeleted: old.txt\n\n//\x1C/ x.txt /\x1C//\nx\n")
+	b := write("b.lkt", "//\x1C/ PROJECT_INFO /\x1C//\nChangeset: v1\nDeleted: main.go\nExcerpt: big.go\nMode: 0755 run.sh\n\n"+
+		"//\x1C/ run.sh /\x1C//\n#!/bin/sh\n//\x1C/ big.go /\x1C//\n@@ -1 +1 @@\n-a\n+b\n")
+	out := filepath.Join(dir, "out.lkt")
+	if _, err := ar.Combine([]ar.CombineInput{{Path: a}, {Path: b, Prefix: "svc/"}}, out, ar.FailOnConflict); err != nil {
+		t.Fatal(err)
+	}
+	combined, err := ar.Load(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := combined.Info()
+	if got := info[parser.DeletedKey]; !slices.Equal(got, []string{"old.txt", "svc/main.go"}) {
+		t.Errorf("Deleted = %v", got)
+	}
+	if got := info[parser.ExcerptKey]; !slices.Equal(got, []string{"svc/big.go"}) {
+		t.Errorf("Excerpt = %v", got)
+	}
+	if got := info.PathValues(parser.ModeKey); len(got) != 1 || got["svc/run.sh"] != "0755" {
+		t.Errorf("Mode = %v", got)
+	}
+
+	// Mirroring deletes the prefixed path and extracts no excerpt as a file
+	tree := t.TempDir()
+	for _, name := range []string{"main.go", "svc/main.go", "svc/keep.go"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(tree, name)), 0o755)
+		os.WriteFile(filepath.Join(tree, name), []byte("x"), 0o644)
+	}
+	parsed, _, err := adaptive.New().ParseMarkedFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted := parser.ExtractMarkers(parsed, tree, parser.ExtractOptions{CreateDirs: true, Overwrite: true})
+	if _, err := mirror.Apply(tree, parsed, extracted, "", false); err != nil {
+		t.Fatal(err)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(tree, name))
+		return err == nil
+	}
+	if exists("svc/main.go") || !exists("main.go") || !exists("svc/keep.go") {
+		t.Error("mirror deleted the wrong files")
+	}
+	if exists("svc/big.go") || !exists("svc/run.sh") {
+		t.Error("excerpt extracted as a file")
+	}
+
+	// A changeset among full archives is refused
+	full := write("full.lkt", "//\x1C/ y.txt /\x1C//\ny\n")
+	if _, err := ar.Combine([]ar.CombineInput{{Path: full}, {Path: b, Prefix: "svc/"}}, out, ar.FailOnConflict); err == nil {
+		t.Error("combined a changeset with a full archive")
+	}
+}
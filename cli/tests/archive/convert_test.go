@@ -0,0 +1,47 @@
+package archive
+
+import (
+	"errors"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+)
+
+func TestConvertRoundTripAndCollisions(t *testing.T) {
+	original := "//\x1C/ a.md /\x1C//\n# Title\n\nbody\n//\x1C/ b.go /\x1C//\npackage b\n"
+	a, err := ar.Parse([]byte(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	presets := metadata.GetPresetConfigs()
+
+	md, err := a.Convert(presets["markdown"].Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ar.Parse(md.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reparsed.Files()) != 2 || reparsed.Files()[0].Content() != "# Title\n\nbody" {
+		t.Fatalf("markdown conversion did not round-trip:\n%s", md.Bytes())
+	}
+	back, err := reparsed.Convert(presets["default"].Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(back.Bytes()) != original {
+		t.Errorf("converting back changed the archive:\n%q", back.Bytes())
+	}
+
+	clash, err := ar.Parse([]byte("//\x1C/ page.html /\x1C//\n<p>\n<!-- FILE: other -->\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = clash.Convert(presets["html"].Config)
+	var collision *ar.CollisionError
+	if !errors.As(err, &collision) || collision.Collisions[0].Line != 2 {
+		t.Errorf("expected a collision on page.html:2, got %v", err)
+	}
+}
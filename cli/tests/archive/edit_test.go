@@ -0,0 +1,37 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+)
+
+func TestEditsPreserveUntouchedEntries(t *testing.T) {
+	input := "<!-- FILE: a.txt -->\nalpha\n\n<!-- FILE: dir/b.txt -->\nbeta\n<!-- FILE: dir/c.txt -->\ngamma\n<!-- FILE: z.txt -->\nzeta\n"
+	a, err := ar.Parse([]byte("---\nlookatni:\n  pattern: \"<!-- FILE: {filename} -->\"\n  start: \"<!-- FILE: \"\n  end: \" -->\"\n---\n" + input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if added, err := a.Set("./a.txt", "ALPHA"); err != nil || added {
+		t.Fatalf("Set replaced=%v err=%v", !added, err)
+	}
+	if _, err := a.Set("../escape.txt", "x"); err == nil {
+		t.Error("expected a path escaping the root to be rejected")
+	}
+	if moves, err := a.Move("dir", "lib"); err != nil || len(moves) != 2 {
+		t.Fatalf("Move: %v %v", moves, err)
+	}
+	if _, err := a.Move("a.txt", "z.txt"); err == nil {
+		t.Error("expected moving onto an existing entry to fail")
+	}
+	if removed := a.Remove("lib/c.txt"); len(removed) != 1 {
+		t.Fatalf("Remove: %v", removed)
+	}
+
+	want := "<!-- FILE: a.txt -->\nALPHA\n<!-- FILE: lib/b.txt -->\nbeta\n<!-- FILE: z.txt -->\nzeta\n"
+	if got := string(a.Bytes()); !strings.HasSuffix(got, want) || !strings.HasPrefix(got, "---\n") {
+		t.Errorf("unexpected archive:\n%s", got)
+	}
+}
@@ -0,0 +1,51 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	md "github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+)
+
+func TestScannerMatchesParse(t *testing.T) {
+	fenced, _ := md.LookupDialect("fenced")
+	fm, _ := md.FrontmatterFor(fenced)
+	quoted := "### not/a/file.go\n\n```go\nx\n```"
+	archives := map[string]string{
+		"default": "intro\n//\x1C/ PROJECT_INFO /\x1C//\nTotal Files: 2\n\n//\x1C/ a.txt /\x1C//\none\r\n\n//\x1C/ b/c.txt /\x1C//\ntwo",
+		"fenced": string(fm) + "preamble\n" +
+			"### a.md\n\n" + md.WrapContent(fenced, "a.md", quoted) + "\ncommentary\n" +
+			"### b.go\n\n" + md.WrapContent(fenced, "b.go", "package b") +
+			"### empty.txt\n### c.txt\n\n" + md.WrapContent(fenced, "c.txt", "c"),
+	}
+	for name, content := range archives {
+		want, err := ar.Parse([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc, err := ar.NewScanner(strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []ar.Entry
+		for sc.Scan() {
+			got = append(got, sc.Entry())
+		}
+		if sc.Err() != nil || sc.Preamble != want.Preamble || sc.Dialect.Name() != want.Dialect.Name() {
+			t.Errorf("%s: preamble %q, dialect %s, err %v", name, sc.Preamble, sc.Dialect.Name(), sc.Err())
+		}
+		if len(got) != len(want.Entries) {
+			t.Fatalf("%s: %d entries, want %d", name, len(got), len(want.Entries))
+		}
+		for i := range got {
+			if got[i].Name != want.Entries[i].Name || got[i].Raw != want.Entries[i].Raw || got[i].Content() != want.Entries[i].Content() {
+				t.Errorf("%s: entry %d = %q, want %q", name, i, got[i].Raw, want.Entries[i].Raw)
+			}
+		}
+	}
+
+	if _, err := ar.NewScanner(strings.NewReader("-----BEGIN LOOKATNI ARCHIVE-----\nVersion: LookAtni Armor v1\n")); err != ar.ErrArmored {
+		t.Errorf("armored archive scanned: %v", err)
+	}
+}
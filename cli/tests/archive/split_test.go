@@ -0,0 +1,76 @@
+// Package archive contains tests for the archive package.
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestSplitAndJoinChunkSet(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("a line of text\n", 200)
+	content := "//\x1C/ small.txt /\x1C//\nhello\n//\x1C/ big.txt /\x1C//\n" + big + "//\x1C/ x (part 1/2) /\x1C//\nnot a part\n"
+	path := filepath.Join(dir, "out.lkt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ar.Split(path, ar.SplitLimits{MaxBytes: 1024})
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(result.SplitFiles) != 1 || result.SplitFiles[0] != "big.txt" {
+		t.Fatalf("expected big.txt to be split, got %v", result.SplitFiles)
+	}
+	for _, chunk := range result.Chunks {
+		info, err := os.Stat(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 1024 {
+			t.Errorf("%s is %d bytes, over the limit", chunk, info.Size())
+		}
+		// Parts are marked with the file's own name
+		c, err := ar.Load(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range c.Files() {
+			if e.Name != "small.txt" && e.Name != "big.txt" && e.Name != "x (part 1/2)" {
+				t.Errorf("%s holds an entry named %q", chunk, e.Name)
+			}
+		}
+	}
+
+	chunks, ok, err := ar.ResolveChunkSet(path)
+	if err != nil || !ok {
+		t.Fatalf("ResolveChunkSet: ok=%v err=%v", ok, err)
+	}
+	joined, err := ar.JoinChunks(chunks)
+	if err != nil {
+		t.Fatalf("JoinChunks: %v", err)
+	}
+	parsed, err := parser.New().ParseMarkedReader(strings.NewReader(string(joined)), "joined")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, m := range parsed.Markers {
+		got[m.Filename] = m.Content
+	}
+	if got["small.txt"] != "hello" {
+		t.Errorf("small.txt = %q", got["small.txt"])
+	}
+	if got["big.txt"] != strings.TrimRight(big, "\n") {
+		t.Errorf("big.txt was not reassembled (%d bytes)", len(got["big.txt"]))
+	}
+	if got["x (part 1/2)"] != "not a part" || len(parsed.Markers) != 4 {
+		t.Errorf("unexpected files: %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestUpdateRewritesChangedEntries(t *testing.T) {
+	src := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("keep.txt", "keep")
+	write("edit.txt", "before")
+	write("dir/gone.txt", "gone")
+	out := filepath.Join(t.TempDir(), "out.lkt.txt")
+	if _, err := parser.New().GenerateFromDirectory(src, out, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first update hashes every file and records them in the cache
+	result, err := ar.Update(out, src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Changed() || result.Unchanged != 3 || result.Rehashed != 3 {
+		t.Fatalf("first update: %+v", result)
+	}
+
+	write("edit.txt", "after")
+	write("new/added.txt", "added")
+	os.Remove(filepath.Join(src, "dir", "gone.txt"))
+	result, err = ar.Update(out, src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Modified, []string{"edit.txt"}) || !slices.Equal(result.Added, []string{"new/added.txt"}) ||
+		!slices.Equal(result.Removed, []string{"dir/gone.txt"}) || result.Unchanged != 1 || result.Rehashed != 2 {
+		t.Fatalf("second update: %+v", result)
+	}
+	a, err := ar.Load(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := a.Positions()
+	if len(a.Files()) != 3 || a.Entries[positions["edit.txt"]].Content() != "after" || a.Entries[positions["new/added.txt"]].Content() != "added" {
+		t.Errorf("unexpected entries: %+v", a.Entries)
+	}
+	if _, ok := positions["dir/gone.txt"]; ok {
+		t.Error("removed file kept")
+	}
+
+	// A file with the cached size and mtime is trusted without rereading it;
+	// a new mtime invalidates its cache entry
+	stat, _ := os.Stat(filepath.Join(src, "keep.txt"))
+	write("keep.txt", "KEEP")
+	os.Chtimes(filepath.Join(src, "keep.txt"), stat.ModTime(), stat.ModTime())
+	if result, err = ar.Update(out, src, nil); err != nil || result.Changed() || result.Rehashed != 0 {
+		t.Fatalf("update with stale file: %+v %v", result, err)
+	}
+	later := stat.ModTime().Add(time.Second)
+	os.Chtimes(filepath.Join(src, "keep.txt"), later, later)
+	if result, err = ar.Update(out, src, nil); err != nil || !slices.Equal(result.Modified, []string{"keep.txt"}) || result.Rehashed != 1 {
+		t.Fatalf("update after touch: %+v %v", result, err)
+	}
+
+	// An unreadable cache costs a full rehash, not a wrong archive
+	os.WriteFile(out+ar.CacheSuffix, []byte("{"), 0o644)
+	if result, err = ar.Update(out, src, nil); err != nil || result.Changed() || result.Rehashed != 3 {
+		t.Fatalf("update with broken cache: %+v %v", result, err)
+	}
+}
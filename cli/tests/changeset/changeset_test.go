@@ -0,0 +1,101 @@
+// Package changeset contains tests for the changeset package.
+package changeset
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	cs "github.com/kubex-ecosystem/lookatni-file-markers/internal/changeset"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/mirror"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBetweenDirs(t *testing.T) {
+	root := t.TempDir()
+	oldDir, newDir := filepath.Join(root, "old"), filepath.Join(root, "new")
+	var big []string
+	for i := range 50 {
+		big = append(big, strings.Repeat("x", i))
+	}
+	writeFiles(t, oldDir, map[string]string{
+		"same.txt":     "same\n",
+		"big.txt":      strings.Join(big, "\n") + "\n",
+		"lib/gone.txt": "gone\n",
+		"lib/old.txt":  "moved\n",
+	})
+	big[25] = "changed"
+	writeFiles(t, newDir, map[string]string{
+		"same.txt": "same\n",
+		"big.txt":  strings.Join(big, "\n") + "\n",
+		"new.txt":  "moved\n",
+		"add.txt":  "added\n",
+	})
+
+	out := filepath.Join(root, "cs.lkt")
+	res, err := cs.BetweenDirs(oldDir, newDir, nil, out, cs.Options{Config: metadata.GetDefaultConfig()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"lib/gone.txt", "lib/old.txt"}; !slices.Equal(res.Deleted, want) {
+		t.Errorf("deleted = %v, want %v", res.Deleted, want)
+	}
+	a, err := ar.Load(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range a.Files() {
+		names = append(names, e.Name)
+	}
+	if want := []string{"add.txt", "big.txt", "new.txt"}; !slices.Equal(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+	info := a.Info()
+	if info.Get(parser.ChangesetKey) != oldDir || len(info[parser.DeletedKey]) != 2 {
+		t.Errorf("unexpected PROJECT_INFO: %v", info)
+	}
+
+	// Applying the deletions and the entries over the old tree yields the new one
+	if _, err := mirror.Remove(oldDir, info[parser.DeletedKey], "", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(oldDir, "lib")); !os.IsNotExist(err) {
+		t.Errorf("emptied directory was kept: %v", err)
+	}
+
+	// Excerpts keep only the hunks of large modified files
+	res, err = cs.BetweenDirs(newDir, newDir, nil, out, cs.Options{Excerpts: true, Context: 1, Config: metadata.GetDefaultConfig()})
+	if err != nil || res.TotalFiles != 0 || len(res.Deleted) != 0 {
+		t.Fatalf("identical dirs: %+v %v", res, err)
+	}
+	writeFiles(t, oldDir, map[string]string{"big.txt": strings.Replace(strings.Join(big, "\n"), "changed", "before", 1) + "\n"})
+	res, err = cs.BetweenDirs(oldDir, newDir, nil, out, cs.Options{Excerpts: true, Context: 1, Config: metadata.GetDefaultConfig()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Excerpts, []string{"big.txt"}) {
+		t.Fatalf("excerpts = %v", res.Excerpts)
+	}
+	a, _ = ar.Load(out)
+	if got, want := a.Entries[a.Index("big.txt")].Content(), "@@ -25,3 +25,3 @@\n "+big[24]+"\n-before\n+changed\n "+big[26]; got != want {
+		t.Errorf("excerpt = %q, want %q", got, want)
+	}
+}
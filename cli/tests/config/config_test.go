@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cf "github.com/kubex-ecosystem/lookatni-file-markers/internal/config"
+	md "github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+)
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLayeredConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	write(t, filepath.Join(home, "lookatni", "config.yaml"), `
+presets:
+  review:
+    description: Headings for review
+    pattern: "## {filename}"
+defaults:
+  exclude: [vendor]
+  tokenizer: chars4
+profiles:
+  backup:
+    armor: true
+`)
+	project := t.TempDir()
+	write(t, filepath.Join(project, ".lookatni.yaml"), `
+presets:
+  review:
+    pattern: "### REVIEW {filename}"
+defaults:
+  output: project.lkt
+profiles:
+  llm-review:
+    preset: review
+    token-budget: 1000
+`)
+	sub := filepath.Join(project, "a", "b")
+	os.MkdirAll(sub, 0o755)
+
+	c, err := cf.Load(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Files) != 2 {
+		t.Fatalf("loaded %v", c.Files)
+	}
+
+	// The project preset replaces the user one of the same name
+	review := c.Presets["review"]
+	if review.Pattern != "### REVIEW {filename}" || review.Source != filepath.Join(project, ".lookatni.yaml") {
+		t.Errorf("review preset: %+v", review)
+	}
+
+	s, err := c.Settings("llm-review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Preset != "review" || s.TokenBudget != 1000 || s.Output != "project.lkt" || s.Tokenizer != "chars4" || s.Exclude[0] != "vendor" || s.Armor != nil {
+		t.Errorf("llm-review settings: %+v", s)
+	}
+	if s, _ := c.Settings("backup"); !cf.Flag(s.Armor) {
+		t.Errorf("backup settings: %+v", s)
+	}
+	if _, err := c.Settings("missing"); err == nil {
+		t.Error("unknown profile was accepted")
+	}
+
+	// Registered presets are dialects like the built-in ones, and registering
+	// the same config again is harmless
+	if err := c.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Register(); err != nil {
+		t.Fatal(err)
+	}
+	d, ok := md.LookupDialect("review")
+	if !ok || d.FormatMarker("x.go") != "### REVIEW x.go" {
+		t.Fatalf("review dialect: %v", d)
+	}
+
+	var out strings.Builder
+	c.List().WriteText(&out)
+	if !strings.Contains(out.String(), "### REVIEW example.go  ["+filepath.Join(project, ".lookatni.yaml")+"]") ||
+		!strings.Contains(out.String(), "[built-in]") || !strings.Contains(out.String(), "llm-review") {
+		t.Errorf("listing:\n%s", out.String())
+	}
+}
+
+func TestConfigRejectsBadPresets(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"builtin.yaml": "presets:\n  html:\n    pattern: \"<{filename}>\"\n",
+		"nofile.yaml":  "presets:\n  broken:\n    pattern: \"== {index} ==\"\n",
+		"invalid.yaml": "presets: [\n",
+	} {
+		path := filepath.Join(dir, name)
+		write(t, path, content)
+		if _, err := cf.LoadFiles(path); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+	if c, err := cf.LoadFiles(filepath.Join(dir, "missing.yaml")); err != nil || len(c.Files) != 0 {
+		t.Errorf("missing file: %v %v", c, err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package dialect contains tests for the dialect package.
+package dialect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	dl "github.com/kubex-ecosystem/lookatni-file-markers/pkg/dialect"
+)
+
+// barDialect reads "|| name ||" markers.
+type barDialect struct{}
+
+func (barDialect) Name() string { return "bars" }
+
+func (barDialect) Config() dl.MarkerConfig {
+	return dl.MarkerConfig{Version: "2.0", Pattern: "|| {filename} ||"}
+}
+
+func (d barDialect) Detect(content []byte) int {
+	n := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		if _, ok := d.ParseLine(line); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (barDialect) ParseLine(line string) (string, bool) {
+	name, ok := strings.CutPrefix(line, "|| ")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(name, " ||")
+}
+
+func (barDialect) FormatMarker(filename string) string { return "|| " + filename + " ||" }
+
+func (barDialect) Escape(filename string) (string, error) { return filename, nil }
+
+func TestRegisteredDialectReachesCommands(t *testing.T) {
+	dl.Register(barDialect{})
+	if d, ok := dl.Lookup("bars"); !ok || d.Name() != "bars" {
+		t.Fatal("registered dialect not found")
+	}
+	found := false
+	for _, d := range dl.List() {
+		found = found || d.Name() == "bars"
+	}
+	if !found {
+		t.Error("registered dialect not listed")
+	}
+
+	content := []byte("|| a.txt ||\nalpha\n|| b/c.txt ||\nbeta\n")
+	d, err := dl.Detect(content)
+	if err != nil || d.Preset != "bars" || d.Markers != 2 {
+		t.Fatalf("detect: %+v %v", d, err)
+	}
+	parsed := parser.ParseContent(content, d.Dialect)
+	if len(parsed.Markers) != 2 || parsed.Markers[1].Filename != "b/c.txt" || parsed.Markers[1].Content != "beta" {
+		t.Errorf("parse: %+v", parsed.Markers)
+	}
+}
@@ -0,0 +1,106 @@
+// Package diff contains tests for the diff package.
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	df "github.com/kubex-ecosystem/lookatni-file-markers/internal/diff"
+)
+
+func TestCompareDetectsAllChangeKinds(t *testing.T) {
+	oldSrc := df.Source{
+		"keep.txt":     "same",
+		"edit.txt":     "one\ntwo\nthree",
+		"gone.txt":     "bye",
+		"old/name.txt": "moved content",
+	}
+	newSrc := df.Source{
+		"keep.txt":     "same",
+		"edit.txt":     "one\n2\nthree",
+		"fresh.txt":    "hi",
+		"new/name.txt": "moved content",
+	}
+
+	res := df.Compare("a", oldSrc, "b", newSrc, df.DefaultContext)
+	want := df.Summary{Added: 1, Removed: 1, Modified: 1, Renamed: 1, Unchanged: 1}
+	if res.Summary != want {
+		t.Fatalf("summary = %+v, want %+v", res.Summary, want)
+	}
+	for _, c := range res.Changes {
+		if c.Kind == df.Renamed && (c.OldPath != "old/name.txt" || c.Path != "new/name.txt") {
+			t.Fatalf("unexpected rename: %+v", c)
+		}
+		if c.Kind == df.Modified && (c.Additions != 1 || c.Deletions != 1) {
+			t.Fatalf("unexpected line counts for modified entry: %+v", c)
+		}
+	}
+}
+
+func TestUnifiedHunks(t *testing.T) {
+	patch, adds, dels := df.Unified("a/f", "b/f", "a\nb\nc\nd", "a\nB\nc\nd\ne", 1)
+	want := "--- a/f\n+++ b/f\n@@ -1,4 +1,5 @@\n a\n-b\n+B\n c\n d\n+e"
+	if patch != want {
+		t.Fatalf("patch mismatch:\n%s\nwant:\n%s", patch, want)
+	}
+	if adds != 2 || dels != 1 {
+		t.Fatalf("adds=%d dels=%d, want 2 and 1", adds, dels)
+	}
+}
+
+func TestLineMatchesFindsLongestCommonLines(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 2000; round++ {
+		a, b := randomLines(rng), randomLines(rng)
+		matches := df.LineMatches(a, b)
+		for i, m := range matches {
+			if a[m.A] != b[m.B] || (i > 0 && (m.A <= matches[i-1].A || m.B <= matches[i-1].B)) {
+				t.Fatalf("bad matches %v for %q and %q", matches, a, b)
+			}
+		}
+		if want := lcs(a, b); len(matches) != want {
+			t.Fatalf("%d matches for %q and %q, want %d", len(matches), a, b, want)
+		}
+	}
+}
+
+func TestUnifiedRewritesLargeFiles(t *testing.T) {
+	// Without common lines the edit distance is the sum of both lengths; the
+	// search must not keep memory proportional to its square
+	var oldText, newText strings.Builder
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&oldText, "old %d\n", i)
+		fmt.Fprintf(&newText, "new %d\n", i)
+	}
+	_, adds, dels := df.Unified("a/f", "b/f", oldText.String(), newText.String(), 3)
+	if adds != 50000 || dels != 50000 {
+		t.Fatalf("adds=%d dels=%d, want 50000 each", adds, dels)
+	}
+}
+
+func randomLines(rng *rand.Rand) []string {
+	lines := make([]string, rng.Intn(12))
+	for i := range lines {
+		lines[i] = string(rune('a' + rng.Intn(3)))
+	}
+	return lines
+}
+
+func lcs(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+	return table[0][0]
+}
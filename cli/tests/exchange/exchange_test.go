@@ -0,0 +1,106 @@
+// Package exchange contains tests for the exchange package.
+package exchange
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	ex "github.com/kubex-ecosystem/lookatni-file-markers/internal/exchange"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "in.lkt")
+	content := "//\x1C/ PROJECT_INFO /\x1C//\nGenerated: 2024-05-06T07:08:09Z\n" +
+		"Mode: 0755 bin/run.sh\nMtime: 2021-01-01T00:00:00Z bin/run.sh\n" +
+		"//\x1C/ bin/run.sh /\x1C//\n#!/bin/sh\necho hi\n//\x1C/ notes.txt /\x1C//\nline\n\n"
+	if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"out.tar", "out.tar.gz", "out.zip"} {
+		packed := filepath.Join(dir, name)
+		if res, err := ex.ExportFile(source, packed); err != nil || res.Files != 2 {
+			t.Fatalf("export %s: %v %v", name, res, err)
+		}
+		imported := filepath.Join(dir, name+".lkt")
+		if res, err := ex.ImportFile(packed, imported); err != nil || res.Files != 2 {
+			t.Fatalf("import %s: %v %v", name, res, err)
+		}
+
+		a, err := ar.Load(imported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := a.Info()
+		if mode := info.PathValues(parser.ModeKey)["bin/run.sh"]; mode != "0755" {
+			t.Errorf("%s: mode = %q", name, mode)
+		}
+		if mtime := info.PathValues(parser.ModTimeKey)["notes.txt"]; mtime != "2024-05-06T07:08:09Z" {
+			t.Errorf("%s: notes.txt mtime = %q", name, mtime)
+		}
+		if i := a.Index("notes.txt"); i < 0 || a.Entries[i].Raw != "//\x1C/ notes.txt /\x1C//\nline\n\n" {
+			t.Errorf("%s: notes.txt not preserved byte for byte", name)
+		}
+
+		// Imported archives list modes after the files; exporting them again
+		// still applies them
+		if a.Index("PROJECT_INFO") != len(a.Entries)-1 {
+			t.Errorf("%s: PROJECT_INFO is not last", name)
+		}
+		again := filepath.Join(dir, "again."+name)
+		if _, err := ex.ExportFile(imported, again); err != nil {
+			t.Fatal(err)
+		}
+		reimported := filepath.Join(dir, "again."+name+".lkt")
+		if _, err := ex.ImportFile(again, reimported); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := ar.Load(reimported); b.Info().PathValues(parser.ModeKey)["bin/run.sh"] != "0755" {
+			t.Errorf("%s: mode lost exporting an imported archive", name)
+		}
+	}
+}
+
+func TestUnsafeNamesAreSkipped(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "in.lkt")
+	content := "//\x1C/ ../evil.txt /\x1C//\nx\n//\x1C/ /etc/evil /\x1C//\nx\n//\x1C/ ok.txt /\x1C//\nfine\n"
+	if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	packed := filepath.Join(dir, "out.tar")
+	res, err := ex.ExportFile(source, packed)
+	if err != nil || res.Files != 1 || len(res.Skipped) != 2 {
+		t.Fatalf("export: %+v %v", res, err)
+	}
+
+	// Containers written by other tools are checked on import
+	f, err := os.Create(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	for _, name := range []string{"../evil.txt", "a/../../evil.txt", "ok.txt", "ok.txt"} {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: 1})
+		tw.Write([]byte("x"))
+	}
+	tw.Close()
+	f.Close()
+	imported := filepath.Join(dir, "imported.lkt")
+	res, err = ex.ImportFile(packed, imported)
+	if err != nil || res.Files != 1 || len(res.Skipped) != 3 {
+		t.Fatalf("import: %+v %v", res, err)
+	}
+	a, err := ar.Load(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Files()) != 1 || a.Files()[0].Name != "ok.txt" || a.Info().Get("Total Files") != "1" {
+		t.Errorf("unexpected archive:\n%s", a.Bytes())
+	}
+}
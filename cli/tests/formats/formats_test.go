@@ -0,0 +1,148 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	fm "github.com/kubex-ecosystem/lookatni-file-markers/internal/formats"
+	md "github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+var files = []parser.ParsedMarker{
+	{Filename: "PROJECT_INFO", Content: "Project: demo"},
+	{Filename: "main.go", Content: "package main\n\nfunc main() {}", Attrs: map[string]string{"mode": "0755"}},
+	{Filename: "notes/cdata.xml", Content: "<![CDATA[ nested ]]> and ]]> again"},
+	{Filename: "dos.txt", Content: "one\r\ntwo"},
+	{Filename: "blob.bin", Content: "\x00\x01\xff"},
+}
+
+func TestRoundTripEveryFormat(t *testing.T) {
+	for _, format := range fm.Names() {
+		data, err := fm.Encode(files, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got := fm.Detect(data); got != format {
+			t.Fatalf("Detect(%s) = %q", format, got)
+		}
+		results, err := fm.Decode(data, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(results.Markers) != len(files) {
+			t.Fatalf("%s: got %d files, want %d", format, len(results.Markers), len(files))
+		}
+		for i, m := range results.Markers {
+			if m.Filename != files[i].Filename || m.Content != files[i].Content {
+				t.Errorf("%s: file %d = %q %q, want %q %q", format, i, m.Filename, m.Content, files[i].Filename, files[i].Content)
+			}
+		}
+		if results.Markers[1].Attrs["mode"] != "0755" {
+			t.Errorf("%s: mode attribute lost: %v", format, results.Markers[1].Attrs)
+		}
+	}
+}
+
+func TestXMLEncodesOnlyWhatItMust(t *testing.T) {
+	data, _ := fm.Encode(files, fm.XML)
+	out := string(data)
+	if !strings.Contains(out, "]]]]><![CDATA[>") {
+		t.Errorf("CDATA terminator in content not split:\n%s", out)
+	}
+	if strings.Count(out, `encoding="base64"`) != 2 {
+		t.Errorf("want base64 for the CRLF and binary files only:\n%s", out)
+	}
+}
+
+func TestReadsDocumentsWithoutRoot(t *testing.T) {
+	data := []byte(`<document index="1">
+<source>a.py</source>
+<document_content>
+print("a < b")
+</document_content>
+</document>
+<document index="2"><source>b.md</source><document_content># B</document_content></document>
+`)
+	if fm.Detect(data) != fm.XML {
+		t.Fatal("XML documents not detected")
+	}
+	results, err := fm.Decode(data, fm.XML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Markers) != 2 || results.Markers[0].Content != `print("a < b")` || results.Markers[1].Content != "# B" {
+		t.Errorf("unexpected files: %+v", results.Markers)
+	}
+}
+
+func TestDetectLeavesMarkedFilesAlone(t *testing.T) {
+	for _, data := range []string{"", "//\x1c/ a.go /\x1c//\npackage a\n", "<!-- FILE: a.md -->\n# A\n", "[//]: # (FILE: a.md)\n"} {
+		if got := fm.Detect([]byte(data)); got != "" {
+			t.Errorf("Detect(%q) = %q", data, got)
+		}
+	}
+}
+
+func TestFromMarkersRebuildsArchive(t *testing.T) {
+	d, _ := md.LookupDialect("html")
+	a, err := ar.FromMarkers(d.Config(), files[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Files()) != 2 || a.Entries[0].Name != "PROJECT_INFO" || a.Entries[2].Content() != files[2].Content {
+		t.Errorf("unexpected archive: %+v", a.Entries)
+	}
+
+	_, err = ar.FromMarkers(d.Config(), []parser.ParsedMarker{{Filename: "a.md", Content: "<!-- FILE: b.md -->"}})
+	if _, ok := err.(*ar.CollisionError); !ok {
+		t.Errorf("want a collision error, got %v", err)
+	}
+}
+
+func TestReadsPackerOutputs(t *testing.T) {
+	rule := strings.Repeat("=", 48)
+	outputs := map[string]string{
+		fm.Repomix: "This file is a merged representation of the entire codebase.\n\n<directory_structure>\nsrc/\n  a.ts\n</directory_structure>\n\n<files>\n" +
+			"<file path=\"src/a.ts\">\nconst s = \"</file>\" && 1;\n</file>\n\n<file path=\"b.md\">\n# B\n</file>\n\n</files>\n",
+		fm.RepomixMarkdown: "# Directory Structure\n```\nsrc/\n```\n\n# Files\n\n## File: src/a.ts\n```typescript\nconst s = \"</file>\" && 1;\n```\n\n## File: b.md\n````markdown\n# B\n````\n",
+		fm.RepomixPlain: strings.Repeat("=", 64) + "\nFiles\n" + strings.Repeat("=", 64) + "\n\n================\nFile: src/a.ts\n================\nconst s = \"</file>\" && 1;\n\n" +
+			"================\nFile: b.md\n================\n# B\n\n",
+		fm.Gitingest: "Directory structure:\n└── demo/\n    └── b.md\n\n" + rule + "\nFILE: src/a.ts\n" + rule + "\nconst s = \"</file>\" && 1;\n\n\n" +
+			rule + "\nFILE: b.md\n" + rule + "\n# B\n\n\n",
+		fm.FilesToPrompt:         "src/a.ts\n---\nconst s = \"</file>\" && 1;\n\n---\nb.md\n---\n# B\n\n---\n",
+		fm.FilesToPromptMarkdown: "src/a.ts\n```typescript\nconst s = \"</file>\" && 1;\n```\nb.md\n```markdown\n# B\n```\n",
+	}
+	for format, out := range outputs {
+		if got := fm.Detect([]byte(out)); got != format {
+			t.Errorf("Detect = %q, want %q", got, format)
+			continue
+		}
+		results, err := fm.Decode([]byte(out), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(results.Markers) != 2 || results.Markers[0].Filename != "src/a.ts" || results.Markers[0].Content != `const s = "</file>" && 1;` ||
+			results.Markers[1].Filename != "b.md" || results.Markers[1].Content != "# B" {
+			t.Errorf("%s: unexpected files: %+v", format, results.Markers)
+		}
+	}
+
+	// A lookatni archive holding packer output stays an archive
+	archive := "//\x1c/ PROJECT_INFO /\x1c//\nProject: demo\n//\x1c/ digest.txt /\x1c//\n" + outputs[fm.Gitingest]
+	if got := fm.Detect([]byte(archive)); got != "" {
+		t.Errorf("archive detected as %q", got)
+	}
+}
+
+func TestDecodeRefusesEscapingNames(t *testing.T) {
+	data := []byte(`[{"filename": "../evil.txt", "content": "x"}, {"filename": "/etc/passwd", "content": "x"}, {"filename": "ok.txt", "content": "y"}]`)
+	results, err := fm.Decode(data, fm.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Errors) != 2 || len(results.Markers) != 1 || results.Markers[0].Filename != "ok.txt" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
@@ -0,0 +1,93 @@
+// Package gitsrc contains tests for the gitsrc package.
+package gitsrc
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	gs "github.com/kubex-ecosystem/lookatni-file-markers/internal/gitsrc"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestGenerateFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo := t.TempDir()
+	files := map[string]string{
+		"main.go":        "package main\n",
+		"docs/a.md":      "docs\n",
+		"run.sh":         "#!/bin/sh\n",
+		".gitattributes": "docs export-ignore\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Chmod(filepath.Join(repo, "run.sh"), 0o755)
+	git(t, repo, "init", "-q")
+	git(t, repo, "add", ".")
+	git(t, repo, "commit", "-qm", "first")
+	commit := git(t, repo, "rev-parse", "HEAD")
+	commit = commit[:len(commit)-1]
+
+	// Untracked and modified files: only the latter belongs to --git
+	os.WriteFile(filepath.Join(repo, "untracked.txt"), []byte("x\n"), 0o644)
+	os.WriteFile(filepath.Join(repo, "main.go"), []byte("package changed\n"), 0o644)
+
+	cases := []struct {
+		rev  string
+		main string
+	}{
+		{"", "package changed"},
+		{"HEAD", "package main"},
+	}
+	for _, c := range cases {
+		out := filepath.Join(t.TempDir(), "out.lkt")
+		if _, err := gs.Generate(repo, c.rev, out, metadata.GetDefaultConfig()); err != nil {
+			t.Fatalf("rev %q: %v", c.rev, err)
+		}
+		a, err := ar.Load(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range a.Files() {
+			names = append(names, e.Name)
+		}
+		if len(names) != 3 || a.Index("docs/a.md") >= 0 || a.Index("untracked.txt") >= 0 {
+			t.Errorf("rev %q: unexpected files %v", c.rev, names)
+		}
+		if got := a.Entries[a.Index("main.go")].Content(); got != c.main {
+			t.Errorf("rev %q: main.go = %q, want %q", c.rev, got, c.main)
+		}
+		info := a.Info()
+		if info.Get(parser.CommitKey) != commit {
+			t.Errorf("rev %q: commit = %q, want %q", c.rev, info.Get(parser.CommitKey), commit)
+		}
+		if mode := info.PathValues(parser.ModeKey)["run.sh"]; mode != "0755" {
+			t.Errorf("rev %q: run.sh mode = %q", c.rev, mode)
+		}
+	}
+
+	if _, err := gs.Generate(repo, "no-such-rev", filepath.Join(t.TempDir(), "x.lkt"), metadata.GetDefaultConfig()); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+}
@@ -0,0 +1,193 @@
+// Package merge contains tests for the merge package.
+package merge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	mg "github.com/kubex-ecosystem/lookatni-file-markers/internal/merge"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestMerge3CombinesNonOverlappingChanges(t *testing.T) {
+	base := "a\nb\nc\nd\ne"
+	local := "a\nB\nc\nd\ne"
+	remote := "a\nb\nc\nd\nE\nf"
+
+	merged, conflicts := mg.Merge3(base, local, remote, mg.DefaultLabels)
+	if conflicts != 0 {
+		t.Fatalf("expected clean merge, got %d conflicts:\n%s", conflicts, merged)
+	}
+	if want := "a\nB\nc\nd\nE\nf"; merged != want {
+		t.Fatalf("merged = %q, want %q", merged, want)
+	}
+}
+
+func TestMerge3ReportsOverlappingChanges(t *testing.T) {
+	merged, conflicts := mg.Merge3("x\ny\nz", "x\nlocal\nz", "x\nremote\nz", mg.DefaultLabels)
+	if conflicts != 1 {
+		t.Fatalf("expected 1 conflict, got %d", conflicts)
+	}
+	want := "x\n<<<<<<< local\nlocal\n||||||| base\ny\n=======\nremote\n>>>>>>> archive\nz"
+	if merged != want {
+		t.Fatalf("merged =\n%s\nwant:\n%s", merged, want)
+	}
+}
+
+// setupTree generates an archive of files, records its bases and extracts it
+// into a working tree. It returns the archive path and the tree.
+func setupTree(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	t.Setenv("LOOKATNI_CACHE_DIR", t.TempDir())
+	src := t.TempDir()
+	for name, content := range files {
+		os.WriteFile(filepath.Join(src, name), []byte(content), 0o644)
+	}
+	archive := filepath.Join(t.TempDir(), "out.lkt.txt")
+	if _, err := parser.New().GenerateFromDirectory(src, archive, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.RecordBases(archive); err != nil {
+		t.Fatal(err)
+	}
+	tree := t.TempDir()
+	if _, err := mg.ExtractWithMerge(archive, tree, parser.ExtractOptions{CreateDirs: true}); err != nil {
+		t.Fatal(err)
+	}
+	return archive, tree
+}
+
+// edit replaces old with new in the file at path.
+func edit(t *testing.T, path, old, new string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), old) {
+		t.Fatalf("%s does not contain %q", path, old)
+	}
+	os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0o644)
+}
+
+func TestExtractWithMerge(t *testing.T) {
+	archive, tree := setupTree(t, map[string]string{
+		"local.txt":  "l1\nlocal-2\nl3\n",
+		"remote.txt": "r1\nremote-2\nr3\n",
+		"both.txt":   "both-1\nb2\nb3\nb4\nboth-5\n",
+	})
+	edit(t, filepath.Join(tree, "local.txt"), "local-2", "LOCAL-2")
+	edit(t, archive, "remote-2", "REMOTE-2")
+	edit(t, filepath.Join(tree, "both.txt"), "both-1", "BOTH-1")
+	edit(t, archive, "both-5", "BOTH-5")
+
+	out, err := mg.ExtractWithMerge(archive, tree, parser.ExtractOptions{CreateDirs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Errors) != 0 || len(out.Conflicts) != 0 {
+		t.Fatalf("errors %v, conflicts %v", out.Errors, out.Conflicts)
+	}
+	want := map[string]string{
+		"local.txt":  "l1\nLOCAL-2\nl3",
+		"remote.txt": "r1\nREMOTE-2\nr3",
+		"both.txt":   "BOTH-1\nb2\nb3\nb4\nBOTH-5",
+	}
+	for name, content := range want {
+		if got, _ := os.ReadFile(filepath.Join(tree, name)); string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestExtractWithMergeWarnsWithoutBase(t *testing.T) {
+	archive, tree := setupTree(t, map[string]string{"f.txt": "one\ntwo\nthree\n"})
+	edit(t, filepath.Join(tree, "f.txt"), "one", "ONE")
+	edit(t, archive, "three", "THREE")
+
+	// A store pruned since generation no longer has the base
+	store, _ := mg.DefaultBaseStore()
+	if n, err := store.Prune(-time.Hour); err != nil || n != 1 {
+		t.Fatalf("Prune = %d, %v", n, err)
+	}
+
+	out, err := mg.ExtractWithMerge(archive, tree, parser.ExtractOptions{CreateDirs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Errors) != 1 || !strings.Contains(out.Errors[0], "merging without common ancestor") {
+		t.Errorf("want a missing base warning, got %v", out.Errors)
+	}
+	if len(out.Conflicts) != 1 {
+		t.Errorf("want the whole file as one conflict, got %v", out.Conflicts)
+	}
+}
+
+func TestRecordBasesKeepsRecentObjects(t *testing.T) {
+	t.Setenv("LOOKATNI_CACHE_DIR", t.TempDir())
+	store, _ := mg.DefaultBaseStore()
+	old, _ := store.Put("old")
+	recent, _ := store.Put("recent")
+	past := time.Now().Add(-2 * mg.MaxBaseAge)
+	os.Chtimes(filepath.Join(store.Dir, old[:2], old[2:]), past, past)
+
+	if n, err := store.Prune(mg.MaxBaseAge); err != nil || n != 1 {
+		t.Fatalf("Prune = %d, %v", n, err)
+	}
+	if _, ok := store.Get(old); ok {
+		t.Error("old object kept")
+	}
+	if _, ok := store.Get(recent); !ok {
+		t.Error("recent object pruned")
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir, old[:2])); !os.IsNotExist(err) {
+		t.Error("emptied shard directory kept")
+	}
+}
+
+func TestBasesAreRecordedOnlyWhenAsked(t *testing.T) {
+	t.Setenv("LOOKATNI_CACHE_DIR", t.TempDir())
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("secret"), 0o644)
+	out := filepath.Join(t.TempDir(), "out.lkt.txt")
+	if _, err := parser.New().GenerateFromDirectory(src, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := mg.DefaultBaseStore()
+	hash := parser.ContentHash("secret")
+
+	// Archives that do not record bases get no hash lines and store nothing
+	if data, _ := os.ReadFile(out); strings.Contains(string(data), parser.BaseHashKey) {
+		t.Fatalf("generated archive records bases:\n%s", data)
+	}
+	if n, err := mg.UpdateBases(out); err != nil || n != 0 {
+		t.Fatalf("UpdateBases = %d, %v", n, err)
+	}
+	if _, ok := store.Get(hash); ok {
+		t.Fatal("base stored without being asked")
+	}
+
+	if n, err := mg.RecordBases(out); err != nil || n != 1 {
+		t.Fatalf("RecordBases = %d, %v", n, err)
+	}
+	data, _ := os.ReadFile(out)
+	if !strings.Contains(string(data), parser.BaseHashKey+": "+hash+" a.txt") {
+		t.Errorf("no base hash line:\n%s", data)
+	}
+	if _, ok := store.Get(hash); !ok {
+		t.Error("base not stored")
+	}
+
+	// Once recording, updates keep storing bases
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("changed"), 0o644)
+	if _, err := ar.Update(out, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := mg.UpdateBases(out); err != nil || n != 1 {
+		t.Fatalf("UpdateBases after update = %d, %v", n, err)
+	}
+	if _, ok := store.Get(parser.ContentHash("changed")); !ok {
+		t.Error("updated base not stored")
+	}
+}
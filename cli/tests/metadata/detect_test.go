@@ -0,0 +1,107 @@
+// Package metadata contains tests for the metadata package.
+package metadata
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	md "github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+)
+
+func archiveIn(preset string, names ...string) string {
+	d, _ := md.LookupDialect(preset)
+	var b strings.Builder
+	for _, name := range append([]string{"PROJECT_INFO"}, names...) {
+		b.WriteString(d.FormatMarker(name) + "\n" + md.WrapContent(d, name, "content of "+name))
+	}
+	return b.String()
+}
+
+func TestDetectSniffsEveryPreset(t *testing.T) {
+	for _, preset := range md.PresetNames() {
+		d, err := md.Detect([]byte(archiveIn(preset, "a.go", "b/c.md")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Preset != preset || d.Source != md.FromContent || d.Markers != 3 {
+			t.Errorf("%s: got %+v", preset, d)
+		}
+		if d.Confidence < 0.8 || d.Confidence > 1 {
+			t.Errorf("%s: confidence %.2f", preset, d.Confidence)
+		}
+	}
+}
+
+func TestDetectFrontmatterAndFallback(t *testing.T) {
+	html := md.GetPresetConfigs()["html"].Config
+	fm, err := md.GenerateFrontmatter(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Frontmatter wins even when the content looks like another dialect
+	d, err := md.Detect(append(fm, archiveIn("code", "a.go")...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Preset != "html" || d.Source != md.FromFrontmatter || d.Confidence != 1 {
+		t.Errorf("frontmatter: got %+v", d)
+	}
+
+	d, _ = md.Detect([]byte("just some text\n"))
+	if d.Preset != "default" || d.Source != md.FromDefault || d.Confidence != 0 {
+		t.Errorf("fallback: got %+v", d)
+	}
+
+	// A markdown archive quoting one html marker is still markdown, less surely
+	mixed := archiveIn("markdown", "a.md", "b.md") + html.FormatMarker("quoted.html") + "\n"
+	d, _ = md.Detect([]byte(mixed))
+	if d.Preset != "markdown" || d.Confidence >= 0.9 {
+		t.Errorf("mixed: got %+v", d)
+	}
+}
+
+func TestDetectIgnoresQuotedMarkers(t *testing.T) {
+	// A README full of fenced examples inside a default archive: FS markers
+	// are decisive even without PROJECT_INFO
+	var readme strings.Builder
+	for i := range 6 {
+		fmt.Fprintf(&readme, "### example%d.py\n\n```python\nprint(%d)\n```\n\n", i, i)
+	}
+	def, _ := md.LookupDialect("default")
+	content := def.FormatMarker("a.go") + "\npackage a\n" + def.FormatMarker("README.md") + "\n" + readme.String()
+	d, _ := md.Detect([]byte(content))
+	if d.Preset != "default" || d.Source != md.FromContent || d.Markers != 2 {
+		t.Errorf("readme in default archive: got %+v", d)
+	}
+
+	// FS markers quoted inside fenced blocks are not the archive's own
+	fenced, _ := md.LookupDialect("fenced")
+	var b strings.Builder
+	for _, name := range []string{"a.lkt", "b.lkt"} {
+		b.WriteString(fenced.FormatMarker(name) + "\n\n" + md.WrapContent(fenced, name, content) + "\n")
+	}
+	d, _ = md.Detect([]byte(b.String()))
+	if d.Preset != "fenced" || d.Markers != 2 || d.Scores["default"] != 0 {
+		t.Errorf("fenced archive quoting a default one: got %+v", d)
+	}
+}
+
+func TestDetectFallsBackWhenAmbiguous(t *testing.T) {
+	html, _ := md.LookupDialect("html")
+	txtar, _ := md.LookupDialect("txtar")
+	var b strings.Builder
+	for i := range 4 {
+		fmt.Fprintf(&b, "%s\nx\n", html.FormatMarker(fmt.Sprintf("h%d.html", i)))
+		if i < 3 {
+			fmt.Fprintf(&b, "%s\ny\n", txtar.FormatMarker(fmt.Sprintf("t%d.txt", i)))
+		}
+	}
+	d, _ := md.Detect([]byte(b.String()))
+	if d.Preset != "default" || d.Source != md.FromDefault || d.Warning == "" || d.Confidence >= md.MinConfidence {
+		t.Errorf("ambiguous markers: got %+v", d)
+	}
+	if d.Scores["html"] != 4 || d.Scores["txtar"] != 3 {
+		t.Errorf("scores: %v", d.Scores)
+	}
+}
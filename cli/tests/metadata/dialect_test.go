@@ -0,0 +1,401 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ar "github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	md "github.com/kubex-ecosystem/lookatni-file-markers/internal/metadata"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+// headDialect reads the "==> name <==" headers printed by head(1) for several files.
+type headDialect struct{}
+
+func (headDialect) Name() string { return "head" }
+
+func (headDialect) Config() md.MarkerConfig {
+	return md.MarkerConfig{Version: "2.0", Pattern: "==> {filename} <=="}
+}
+
+func (d headDialect) Detect(content []byte) int {
+	n := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		if _, ok := d.ParseLine(line); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func (headDialect) ParseLine(line string) (string, bool) {
+	name, ok := strings.CutPrefix(line, "==> ")
+	if !ok {
+		return "", false
+	}
+	name, ok = strings.CutSuffix(name, " <==")
+	return name, ok
+}
+
+func (headDialect) FormatMarker(filename string) string { return "==> " + filename + " <==" }
+
+func (headDialect) Escape(filename string) (string, error) {
+	if strings.ContainsAny(filename, "\r\n") {
+		return "", fmt.Errorf("filename cannot be represented: %q", filename)
+	}
+	return filename, nil
+}
+
+func TestRegisteredDialectWorksEverywhere(t *testing.T) {
+	md.RegisterDialect(headDialect{})
+	if d, ok := md.LookupDialect("head"); !ok || d.Name() != "head" {
+		t.Fatal("registered dialect not found")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("registering a name twice did not panic")
+			}
+		}()
+		md.RegisterDialect(headDialect{})
+	}()
+
+	content := []byte("==> a.txt <==\nalpha\n\n==> dir/b.txt <==\nbeta\n")
+	d, err := md.Detect(content)
+	if err != nil || d.Preset != "head" || d.Markers != 2 {
+		t.Fatalf("detect: %+v %v", d, err)
+	}
+
+	parsed := parser.ParseContent(content, d.Dialect)
+	if len(parsed.Markers) != 2 || parsed.Markers[1].Filename != "dir/b.txt" || parsed.Markers[1].Content != "beta" {
+		t.Fatalf("parse: %+v", parsed.Markers)
+	}
+	if v := parser.ValidateParsed(parsed, parser.MalformedMarkers([]byte("==> broken\n"), d.Dialect)); len(v.Errors) != 1 {
+		t.Errorf("malformed header was accepted: %+v", v)
+	}
+
+	// Convert to another dialect and back, and generate from a directory
+	a, err := ar.Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := a.Convert(md.GetPresetConfigs()["html"].Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := html.Convert(d.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, rest, _ := md.ParseFrontmatter(back.Bytes()); string(rest) != string(content) {
+		t.Fatalf("round trip: %q", back.Bytes())
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "x.go"), []byte("package x\n"), 0o644)
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if _, err := parser.Generate(dir, out, nil, d.Dialect); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if !strings.Contains(string(data), "\n==> x.go <==\npackage x\n") {
+		t.Errorf("generated:\n%s", data)
+	}
+}
+
+func TestConfigDialectEscape(t *testing.T) {
+	html, _ := md.LookupDialect("html")
+	if _, err := html.Escape("ok/name.html"); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"two\nlines", " padded"} {
+		if _, err := html.Escape(name); err == nil {
+			t.Errorf("%q was accepted", name)
+		}
+	}
+}
+
+func TestLanguageMarkers(t *testing.T) {
+	lang, ok := md.LookupDialect("lang")
+	if !ok {
+		t.Fatal("lang dialect is not registered")
+	}
+	files := map[string]string{
+		"main.go":     "package main",
+		"app/x.py":    "# FILE: quoted.go\nprint(1)",
+		"db/q.sql":    "select 1;",
+		"web/a.html":  "<p>hi</p>",
+		"style.css":   "p {}",
+		"Makefile":    "all:",
+		"README.md":   "# Title",
+		"plain.noext": "text",
+	}
+	want := map[string]string{
+		"main.go":    "// FILE: main.go",
+		"app/x.py":   "# FILE: app/x.py",
+		"db/q.sql":   "-- FILE: db/q.sql",
+		"web/a.html": "<!-- FILE: web/a.html -->",
+		"style.css":  "/* FILE: style.css */",
+		"Makefile":   "# FILE: Makefile",
+	}
+	for name, marker := range want {
+		if got := lang.FormatMarker(name); got != marker {
+			t.Errorf("marker for %s = %q, want %q", name, got, marker)
+		}
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o644)
+	}
+	out := filepath.Join(t.TempDir(), "out.lkt")
+	if _, err := parser.Generate(dir, out, nil, lang); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+
+	// The frontmatter names the dialect again, and a marker of another
+	// language quoted inside the Python file stays content
+	d, err := md.Detect(data)
+	if err != nil || d.Preset != "lang" {
+		t.Fatalf("detect: %+v %v", d, err)
+	}
+	_, rest, _ := md.ParseFrontmatter(data)
+	parsed := parser.ParseContent(rest, d.Dialect)
+	got := map[string]string{}
+	for _, m := range parsed.Markers {
+		got[m.Filename] = m.Content
+	}
+	for name, content := range files {
+		if got[name] != content {
+			t.Errorf("%s = %q, want %q", name, got[name], content)
+		}
+	}
+
+	// Without frontmatter the set is still sniffed as one dialect
+	sniffed, _ := md.Detect(rest)
+	if sniffed.Preset != "lang" || sniffed.Confidence < 0.8 {
+		t.Errorf("sniffed: %+v", sniffed)
+	}
+}
+
+func TestAttributePlaceholdersRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("x", 1228) + "\n"
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hi\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte(big), 0o644)
+	os.WriteFile(filepath.Join(dir, "run.sh"), []byte("echo\n"), 0o755)
+
+	custom, ok := md.LookupDialect("custom")
+	if !ok {
+		t.Fatal("custom preset is not registered")
+	}
+	withHash, err := md.NewDialect("", md.MarkerConfig{Pattern: "== {filename} {mode} {sha256} =="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []md.Dialect{custom, withHash} {
+		out := filepath.Join(t.TempDir(), "out.lkt")
+		if _, err := parser.Generate(dir, out, nil, d); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(out)
+		_, rest, _ := md.ParseFrontmatter(data)
+		parsed := parser.ParseContent(rest, d)
+		if len(parsed.Markers) != 4 {
+			t.Fatalf("parsed %d markers:\n%s", len(parsed.Markers), data)
+		}
+		m := parsed.Markers[2]
+		if d == custom {
+			if !strings.Contains(string(data), "\n### [2] main.go (go, 1.2KB)\n") {
+				t.Errorf("generated:\n%s", data)
+			}
+			if m.Filename != "main.go" || m.Attrs["index"] != "2" || m.Attrs["lang"] != "go" || m.Attrs["size"] != "1.2KB" {
+				t.Errorf("custom attrs: %+v", m)
+			}
+			continue
+		}
+		if m.Attrs["sha256"] != parser.ContentHash(big) || m.Attrs["mode"] != "0644" || parsed.Markers[3].Attrs["mode"] != "0755" {
+			t.Errorf("hash and mode attrs: %+v %+v", m.Attrs, parsed.Markers[3].Attrs)
+		}
+	}
+
+	// Edited archives number new entries after the existing ones
+	a, err := ar.New(custom.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Set("one.py", "print(1)")
+	a.Set("two.md", "# Two")
+	if got := a.Entries[1].Raw; got != "### [2] two.md (markdown, 5B)\n# Two\n" {
+		t.Errorf("new entry = %q", got)
+	}
+}
+
+func TestPlaceholdersFilledOnlyWhenUsed(t *testing.T) {
+	custom, _ := md.LookupDialect("custom")
+	canonical, _ := md.LookupDialect("default")
+	if !md.UsesPlaceholder(custom, "a.go", md.IndexPlaceholder) || md.UsesPlaceholder(custom, "a.go", md.SHA256Placeholder) {
+		t.Error("custom placeholders misreported")
+	}
+	if md.UsesAttributes(canonical, "a.go") {
+		t.Error("default dialect reported as using attributes")
+	}
+
+	// Bulk rewrites number files by position, PROJECT_INFO aside
+	src, err := ar.Parse([]byte("//\x1C/ PROJECT_INFO /\x1C//\nTotal Files: 3\n\n//\x1C/ a.txt /\x1C//\na\n//\x1C/ b.txt /\x1C//\nb\n//\x1C/ c.txt /\x1C//\nc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := src.Convert(custom.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom.lkt")
+	if err := out.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "d.txt"), []byte("d\n"), 0o644)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(name[:1]+"\n"), 0o644)
+	}
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed\n"), 0o644)
+	if _, err := ar.Update(path, dir, []string{"*.lkt"}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := ar.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Files()) != 4 {
+		t.Fatalf("updated archive holds %d files", len(updated.Files()))
+	}
+	for i, e := range updated.Files() {
+		if want := fmt.Sprintf("### [%d] %s ", i+1, e.Name); !strings.HasPrefix(e.Raw, want) {
+			t.Errorf("entry %d = %q, want prefix %q", i, e.Raw, want)
+		}
+	}
+}
+
+func TestHandWrittenFrontmatter(t *testing.T) {
+	// Unquoted values are not valid YAML; every key still reads as usual
+	content := "---\nlookatni:\n  dialect: fenced\n  pattern: <!-- FILE: {filename} -->\n  languages:\n    - pattern: # FILE: {filename}\n      extensions: [py, sh]\n---\nrest"
+	meta, rest, err := md.ParseFrontmatter([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := meta.LookAtni
+	if mc.Dialect != "fenced" || mc.Pattern != "<!-- FILE: {filename} -->" || string(rest) != "rest" {
+		t.Errorf("frontmatter = %+v, rest %q", mc, rest)
+	}
+	if len(mc.Languages) != 1 || mc.Languages[0].Pattern != "# FILE: {filename}" || strings.Join(mc.Languages[0].Extensions, ",") != "py,sh" {
+		t.Errorf("languages = %+v", mc.Languages)
+	}
+}
+
+func TestFencedMarkdown(t *testing.T) {
+	fenced, ok := md.LookupDialect("fenced")
+	if !ok {
+		t.Fatal("fenced dialect is not registered")
+	}
+	files := map[string]string{
+		"main.go":      "package main",
+		"README.md":    "# Title\n\n### Install\n\n```sh\nmake\n```",
+		"docs/four.md": "````\nnested\n````",
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o644)
+	}
+	out := filepath.Join(t.TempDir(), "out.md")
+	if _, err := parser.Generate(dir, out, nil, fenced); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	for _, want := range []string{"\n### main.go\n```go\npackage main\n```\n", "\n### README.md\n````markdown\n", "\n### docs/four.md\n`````markdown\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in:\n%s", want, data)
+		}
+	}
+
+	// Headings inside the fences stay content, with and without frontmatter
+	_, rest, _ := md.ParseFrontmatter(data)
+	for _, content := range [][]byte{data, rest} {
+		d, err := md.Detect(content)
+		if err != nil || d.Preset != "fenced" || d.Markers != 4 {
+			t.Fatalf("detect: %+v %v", d, err)
+		}
+		a, err := ar.Parse(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a.Files()) != len(files) {
+			t.Fatalf("archive entries: %+v", a.Entries)
+		}
+		for _, e := range a.Files() {
+			if e.Content() != files[e.Name] {
+				t.Errorf("%s = %q", e.Name, e.Content())
+			}
+		}
+		if v := parser.ValidateParsed(parser.ParseContent(rest, d.Dialect), parser.MalformedMarkers(rest, d.Dialect)); len(v.Errors) != 0 {
+			t.Errorf("validate: %+v", v.Errors)
+		}
+	}
+
+	// Converting away and back keeps the content
+	a, _ := ar.Parse(data)
+	plain, err := a.Convert(md.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := plain.Convert(fenced.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parser.ParseContent(back.Bytes(), fenced); len(got.Markers) != 4 || got.Markers[2].Content != files[got.Markers[2].Filename] {
+		t.Errorf("round trip:\n%s", back.Bytes())
+	}
+
+	// Tilde fences, indented fences and backticked paths as chat models write them
+	chat := "Here you go:\n\n### `a b.py`\n\n~~~python\nprint(1)\n```\n~~~\n\nSome commentary.\n\n### c.txt\n  ```\n  two\n   three\n  ```\n"
+	parsed := parser.ParseContent([]byte(chat), fenced)
+	if len(parsed.Markers) != 2 || parsed.Markers[0].Filename != "a b.py" || parsed.Markers[0].Content != "print(1)\n```" || parsed.Markers[1].Content != "two\n three" {
+		t.Errorf("chat: %+v", parsed.Markers)
+	}
+}
+
+func TestTxtar(t *testing.T) {
+	txtar, ok := md.LookupDialect("txtar")
+	if !ok {
+		t.Fatal("txtar dialect is not registered")
+	}
+	// A script test as the Go toolchain writes them; the comment is the script
+	script := "exec go test ./...\nstdout ok\n\n-- go.mod --\nmodule example.com/m\n-- m_test.go --\npackage m\n-- empty --\n"
+	d, err := md.Detect([]byte(script))
+	if err != nil || d.Preset != "txtar" || d.Markers != 3 {
+		t.Fatalf("detect: %+v %v", d, err)
+	}
+	for line, want := range map[string]string{"-- a b.go --": "a b.go", "--  spaced  --": "spaced", "-- --": "", "--a--": "", "-- FILE: q.sql": ""} {
+		if name, _ := txtar.ParseLine(line); name != want {
+			t.Errorf("ParseLine(%q) = %q, want %q", line, name, want)
+		}
+	}
+
+	a, err := ar.ParseWithDialect([]byte(script), txtar).Convert(md.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := a.Convert(txtar.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(back.Bytes()) != script {
+		t.Errorf("round trip added frontmatter or changed content:\n%s", back.Bytes())
+	}
+}
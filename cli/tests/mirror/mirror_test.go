@@ -0,0 +1,68 @@
+// Package mirror contains tests for the mirror package.
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	mr "github.com/kubex-ecosystem/lookatni-file-markers/internal/mirror"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestPruneRemovesOnlyDirectoriesItEmptied(t *testing.T) {
+	out := t.TempDir()
+	for _, name := range []string{"keep.txt", "stale/a.txt", "stale/deep/b.txt", "mixed/keep.txt", "mixed/c.txt"} {
+		path := filepath.Join(out, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(name), 0o644)
+	}
+	os.MkdirAll(filepath.Join(out, "empty", "nested"), 0o755)
+
+	result, err := mr.Prune(out+string(filepath.Separator), []string{"keep.txt", "mixed/keep.txt"}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.DeletedFiles) != 3 || len(result.Errors) != 0 {
+		t.Fatalf("deleted %v, errors %v", result.DeletedFiles, result.Errors)
+	}
+	for _, gone := range []string{"stale", "mixed/c.txt"} {
+		if _, err := os.Stat(filepath.Join(out, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s kept", gone)
+		}
+	}
+	for _, kept := range []string{"keep.txt", "mixed/keep.txt", "empty/nested"} {
+		if _, err := os.Stat(filepath.Join(out, kept)); err != nil {
+			t.Errorf("%s removed", kept)
+		}
+	}
+}
+
+func TestApplyRefusesUnsafeArchives(t *testing.T) {
+	out := t.TempDir()
+	os.WriteFile(filepath.Join(out, "local.txt"), []byte("mine"), 0o644)
+	info := parser.ParsedMarker{Filename: parser.ProjectInfoMarker, Content: "Project: demo"}
+	file := parser.ParsedMarker{Filename: "a.txt", Content: "a"}
+
+	cases := map[string]struct {
+		parsed    *parser.ParseResults
+		extracted *parser.ExtractResults
+	}{
+		"no files":          {&parser.ParseResults{Markers: []parser.ParsedMarker{info}}, &parser.ExtractResults{Success: true}},
+		"parse errors":      {&parser.ParseResults{Markers: []parser.ParsedMarker{info, file}, Errors: []parser.ParseError{{Line: 3, Message: "Empty filename in marker"}}}, &parser.ExtractResults{Success: true}},
+		"failed extraction": {&parser.ParseResults{Markers: []parser.ParsedMarker{info, file}}, &parser.ExtractResults{Success: false}},
+	}
+	for name, c := range cases {
+		if _, err := mr.Apply(out, c.parsed, c.extracted, "", false); err == nil {
+			t.Errorf("%s: mirror not refused", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "local.txt")); err != nil {
+		t.Fatal("a refused mirror deleted files")
+	}
+
+	result, err := mr.Apply(out, &parser.ParseResults{Markers: []parser.ParsedMarker{info, file}}, &parser.ExtractResults{Success: true}, "", false)
+	if err != nil || len(result.DeletedFiles) != 1 {
+		t.Fatalf("Apply = %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	prs "github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestArmorRoundTrip(t *testing.T) {
+	fs := string(rune(28))
+	archive := "//" + fs + "/ a.txt /" + fs + "//\nhello\n//" + fs + "/ dir/b.txt /" + fs + "//\nworld\n"
+
+	armored := prs.Armor([]byte(archive))
+	if strings.ContainsRune(string(armored), rune(28)) {
+		t.Fatalf("armored output still contains control characters")
+	}
+
+	// Simulate a mail client adding CRLF line endings and surrounding prose
+	mangled := "Hi, archive below.\r\n\r\n" + strings.ReplaceAll(string(armored), "\n", "\r\n") + "\r\nThanks!\r\n"
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	if err := os.WriteFile(path, []byte(mangled), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	res, err := prs.New().ParseMarkedFile(path)
+	if err != nil {
+		t.Fatalf("ParseMarkedFile error: %v", err)
+	}
+	if len(res.Markers) != 2 || res.Markers[1].Filename != "dir/b.txt" || res.Markers[1].Content != "world" {
+		t.Fatalf("unexpected markers after de-armoring: %+v", res.Markers)
+	}
+}
+
+func TestDearmorRejectsCorruptPayload(t *testing.T) {
+	armored := string(prs.Armor([]byte("some archive content")))
+	lines := strings.Split(armored, "\n")
+	for i, line := range lines {
+		if i > 0 && line != "" && !strings.Contains(line, ":") && !strings.HasPrefix(line, "=") && !strings.HasPrefix(line, "-----") {
+			lines[i] = "A" + line[1:]
+			break
+		}
+	}
+	if _, err := prs.Dearmor([]byte(strings.Join(lines, "\n"))); err == nil {
+		t.Fatalf("expected checksum error for corrupted payload")
+	}
+}
+
+func TestArchiveQuotingArmorIsPlain(t *testing.T) {
+	fs := string(rune(28))
+	// An archive of a document that shows the armor layout, as the spec does
+	archive := "//" + fs + "/ spec.md /" + fs + "//\nArmored archives look like:\n\n    " + prs.ArmorBegin + "\n    ...\n    " + prs.ArmorEnd + "\n" +
+		"//" + fs + "/ armor.go /" + fs + "//\nconst begin = \"" + prs.ArmorBegin + "\"\n"
+	if prs.IsArmored([]byte(archive)) {
+		t.Fatal("archive quoting the armor delimiters taken for armor")
+	}
+	path := filepath.Join(t.TempDir(), "spec.lkt")
+	os.WriteFile(path, []byte(archive), 0o644)
+	res, err := prs.New().ParseMarkedFile(path)
+	if err != nil {
+		t.Fatalf("ParseMarkedFile error: %v", err)
+	}
+	if len(res.Markers) != 2 || !strings.Contains(res.Markers[0].Content, prs.ArmorEnd) {
+		t.Fatalf("unexpected markers: %+v", res.Markers)
+	}
+
+	// Prose before the envelope does not hide it
+	if !prs.IsArmored([]byte("Hi,\n\n  " + string(prs.Armor([]byte(archive))))) {
+		t.Error("indented armor after prose not recognized")
+	}
+}
+
+func TestArmorAfterMarkerLikePreamble(t *testing.T) {
+	fs := string(rune(28))
+	archive := "//" + fs + "/ a.txt /" + fs + "//\nhello\n"
+	armored := string(prs.Armor([]byte(archive)))
+	for _, preamble := range []string{"-- see below --", "// FILE: notes", "<!-- FILE: hi -->"} {
+		data := []byte("Hi team,\n" + preamble + "\n\n" + armored)
+		if !prs.IsArmored(data) {
+			t.Errorf("armor after %q not recognized", preamble)
+		}
+		path := filepath.Join(t.TempDir(), "mail.txt")
+		os.WriteFile(path, data, 0o644)
+		res, err := prs.New().ParseMarkedFile(path)
+		if err != nil || len(res.Markers) != 1 || res.Markers[0].Filename != "a.txt" {
+			t.Errorf("after %q: %+v %v", preamble, res, err)
+		}
+	}
+
+	// A PROJECT_INFO marker before the envelope still marks a quoting archive
+	quoting := "<!-- FILE: PROJECT_INFO -->\n<!-- FILE: mail.txt -->\n" + armored
+	if prs.IsArmored([]byte(quoting)) {
+		t.Error("archive quoting a full envelope taken for armor")
+	}
+}
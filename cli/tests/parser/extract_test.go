@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	prs "github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+)
+
+func TestExtractRefusesEscapingNames(t *testing.T) {
+	root := t.TempDir()
+	out := filepath.Join(root, "out")
+	results := &prs.ParseResults{Markers: []prs.ParsedMarker{
+		{Filename: "../evil.txt", Content: "x"},
+		{Filename: "a/../../evil.txt", Content: "x"},
+		{Filename: filepath.Join(root, "abs.txt"), Content: "x"},
+		{Filename: "ok/a..b.txt", Content: "fine"},
+	}}
+
+	extracted := prs.ExtractMarkers(results, out, prs.ExtractOptions{CreateDirs: true})
+	if extracted.Success || len(extracted.Errors) != 3 || len(extracted.ExtractedFiles) != 1 {
+		t.Fatalf("unexpected result: %+v", extracted)
+	}
+	for _, e := range extracted.Errors {
+		if !strings.Contains(e, "refusing unsafe path") {
+			t.Errorf("unexpected error %q", e)
+		}
+	}
+	for _, name := range []string{"evil.txt", "abs.txt"} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("%s written outside the output directory", name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sfx
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	sx "github.com/kubex-ecosystem/lookatni-file-markers/internal/sfx"
+)
+
+func TestScriptRecreatesTree(t *testing.T) {
+	if _, err := exec.LookPath("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("no base64")
+	}
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"no-newline.txt":     {"quoted $HOME `date` $(id) \\n", 0o644},
+		"blank-lines.txt":    {"two\n\n", 0o600},
+		"bin/run":            {"#!/bin/sh\necho hi\n", 0o755},
+		"blob.bin":           {"\x00\x01\xfe\xff" + strings.Repeat("z", 100), 0o644},
+		"odd dir/it's.md":    {"LOOKATNI_EOF\nLOOKATNI_EOF_1\n", 0o644},
+		"empty":              {"", 0o644},
+		"crlf/windows.txt":   {"a\r\nb\r\n", 0o644},
+		"-dash/leading.conf": {"x=1", 0o640},
+	}
+	src := t.TempDir()
+	for name, f := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		os.Chmod(path, f.mode)
+	}
+
+	script := filepath.Join(t.TempDir(), "out.sh")
+	if _, err := parser.New().GenerateFromDirectory(src, script, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sx.ConvertFile(script, src); err != nil {
+		t.Fatal(err)
+	}
+
+	// --dry-run lists the files and writes nothing
+	target := filepath.Join(t.TempDir(), "target")
+	out, err := exec.Command("/bin/sh", script, "--dry-run", "--target", target).CombinedOutput()
+	if err != nil {
+		t.Fatalf("dry run: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "0755 bin/run") || strings.Count(string(out), "\n") != len(files) {
+		t.Errorf("dry run output:\n%s", out)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s", target)
+	}
+
+	if out, err := exec.Command("/bin/sh", script, "--target", target).CombinedOutput(); err != nil {
+		t.Fatalf("extract: %v\n%s", err, out)
+	}
+	for name, f := range files {
+		path := filepath.Join(target, filepath.FromSlash(name))
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(got, []byte(f.content)) {
+			t.Errorf("%s = %q, want %q", name, got, f.content)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != f.mode {
+			t.Errorf("%s mode = %o, want %o", name, info.Mode().Perm(), f.mode)
+		}
+	}
+}
+
+func TestScriptRefusesEscapingPaths(t *testing.T) {
+	for _, name := range []string{"../evil", "a/../../evil", "/etc/passwd"} {
+		if err := sx.Write(&bytes.Buffer{}, "out.sh", "demo", []sx.File{{Name: name}}); err == nil {
+			t.Errorf("Write accepted %q", name)
+		}
+	}
+
+	// The script checks every path itself before writing any file
+	var b bytes.Buffer
+	if err := sx.Write(&b, "out.sh", "demo", []sx.File{{Name: "first.txt", Content: []byte("x\n"), Mode: 0o644}, {Name: "second.txt", Mode: 0o644}}); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "out.sh")
+	os.WriteFile(script, bytes.Replace(b.Bytes(), []byte("'second.txt'"), []byte("'../second.txt'"), -1), 0o755)
+	target := t.TempDir()
+	out, err := exec.Command("/bin/sh", script, "--target", target).CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "refusing unsafe path: ../second.txt") {
+		t.Fatalf("want refusal, got %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(target, "first.txt")); !os.IsNotExist(err) {
+		t.Error("files were written before the unsafe path was refused")
+	}
+}
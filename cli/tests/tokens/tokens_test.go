@@ -0,0 +1,42 @@
+// Package tokens contains tests for the tokens package.
+package tokens
+
+import (
+	"testing"
+
+	tk "github.com/kubex-ecosystem/lookatni-file-markers/internal/tokens"
+)
+
+func TestTokenizerCounts(t *testing.T) {
+	cl100k, err := tk.Get("cl100k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chars4, err := tk.Get("chars4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text           string
+		cl100k, chars4 int
+	}{
+		{"", 0, 0},
+		{"hello world", 2, 3},
+		{"Hello, world!\n", 4, 4},
+		{"func main() {}", 5, 4},
+		{"it's 2024", 5, 3},
+	}
+	for _, c := range cases {
+		if got := cl100k.Count(c.text); got != c.cl100k {
+			t.Errorf("cl100k(%q) = %d, want %d", c.text, got, c.cl100k)
+		}
+		if got := chars4.Count(c.text); got != c.chars4 {
+			t.Errorf("chars4(%q) = %d, want %d", c.text, got, c.chars4)
+		}
+	}
+
+	if _, err := tk.Get("bogus"); err == nil {
+		t.Error("expected an error for an unknown tokenizer")
+	}
+}
@@ -0,0 +1,109 @@
+// Package watch contains tests for the watch package.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/archive"
+	"github.com/kubex-ecosystem/lookatni-file-markers/internal/parser"
+	wt "github.com/kubex-ecosystem/lookatni-file-markers/internal/watch"
+)
+
+const debounce = 50 * time.Millisecond
+
+// start watches src, updating out on every rebuild, and returns a channel
+// receiving the result of each rebuild.
+func start(t *testing.T, src, out string, exclude []string) <-chan *archive.UpdateResults {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	rebuilds := make(chan *archive.UpdateResults, 16)
+	opts := wt.Options{Debounce: debounce, ExcludePatterns: exclude, SkipPaths: []string{out, out + archive.CacheSuffix}}
+	rebuild := func() error {
+		result, err := archive.Update(out, src, exclude)
+		if err == nil {
+			rebuilds <- result
+		}
+		return err
+	}
+	go wt.Watch(ctx, src, opts, rebuild, func(err error) { t.Error(err) })
+	// Let the watcher register the tree before anything changes
+	time.Sleep(4 * debounce)
+	return rebuilds
+}
+
+// quiet fails when a rebuild happens within a few debounce periods.
+func quiet(t *testing.T, rebuilds <-chan *archive.UpdateResults, after string) {
+	t.Helper()
+	select {
+	case result := <-rebuilds:
+		t.Fatalf("rebuild after %s: %+v", after, result)
+	case <-time.After(10 * debounce):
+	}
+}
+
+func TestWatchRebuildsOnceWithArchiveInTree(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("one"), 0o644)
+	out := filepath.Join(src, "out.lkt.txt")
+	if _, err := parser.New().GenerateFromDirectory(src, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Update(out, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	rebuilds := start(t, src, out, nil)
+
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("two"), 0o644)
+	select {
+	case result := <-rebuilds:
+		if len(result.Modified) != 1 || result.Modified[0] != "a.txt" {
+			t.Fatalf("unexpected rebuild: %+v", result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no rebuild after a change")
+	}
+	// Writing the archive and its cache must not trigger further rebuilds
+	quiet(t, rebuilds, "the archive was written")
+}
+
+func TestWatchIgnoresExcludedFiles(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("one"), 0o644)
+	out := filepath.Join(t.TempDir(), "out.lkt.txt")
+	if _, err := parser.New().GenerateFromDirectory(src, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	rebuilds := start(t, src, out, []string{"*.log"})
+
+	os.WriteFile(filepath.Join(src, "debug.log"), []byte("noise"), 0o644)
+	quiet(t, rebuilds, "an excluded file changed")
+}
+
+func TestUpdateLeavesUnchangedCacheAlone(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("one"), 0o644)
+	out := filepath.Join(t.TempDir(), "out.lkt.txt")
+	if _, err := parser.New().GenerateFromDirectory(src, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Update(out, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(out + archive.CacheSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := before.ModTime().Add(-time.Hour)
+	os.Chtimes(out+archive.CacheSuffix, past, past)
+	if _, err := archive.Update(out, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.Stat(out + archive.CacheSuffix); !after.ModTime().Equal(past) {
+		t.Error("unchanged cache was rewritten")
+	}
+}